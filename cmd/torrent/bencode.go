@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/stupoid/torrent/internal/bencode"
+)
+
+const bencodeUsage = `usage: torrent bencode <mode> [file]
+
+Reads a single value from file, or stdin if file is omitted or "-".
+
+modes:
+  json      convert bencode to indented JSON
+  fromjson  convert JSON produced by the json mode back to bencode
+  pretty    print bencode in a readable form, shortening binary strings
+`
+
+func runBencode(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("bencode", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	compact := fs.Bool("compact", false, "write JSON on a single line")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), bencodeUsage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		return fmt.Errorf("bencode: expected a mode and at most one file")
+	}
+
+	input, err := readInput(fs.Arg(1), stdin)
+	if err != nil {
+		return err
+	}
+
+	switch fs.Arg(0) {
+	case "json":
+		output, err := bencode.ToJSON(input)
+		if err != nil {
+			return err
+		}
+		if !*compact {
+			var buf bytes.Buffer
+			if err := json.Indent(&buf, output, "", "  "); err != nil {
+				return err
+			}
+			output = buf.Bytes()
+		}
+		_, err = fmt.Fprintf(stdout, "%s\n", output)
+		return err
+	case "fromjson":
+		output, err := bencode.FromJSON(input)
+		if err != nil {
+			return err
+		}
+		_, err = stdout.Write(output)
+		return err
+	case "pretty":
+		return bencode.PrettyPrint(stdout, input)
+	default:
+		fs.Usage()
+		return fmt.Errorf("bencode: unknown mode %q", fs.Arg(0))
+	}
+}
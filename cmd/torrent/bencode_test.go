@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBencodeCommand(t *testing.T) {
+	tests := []struct {
+		args     []string
+		stdin    string
+		expected string
+	}{
+		{[]string{"bencode", "-compact", "json"}, "d1:ai1e1:b2:\xff\x00e", `{"a":1,"b":{"$bytes":"/wA="}}` + "\n"},
+		{[]string{"bencode", "json", "-"}, "l1:xe", "[\n  \"x\"\n]\n"},
+		{[]string{"bencode", "fromjson"}, `{"a":1,"b":{"$bytes":"/wA="}}`, "d1:ai1e1:b2:\xff\x00e"},
+		{[]string{"bencode", "pretty"}, "d1:ai1ee", "{\n  \"a\": 1\n}\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(strings.Join(test.args, " "), func(t *testing.T) {
+			var stdout bytes.Buffer
+			if err := run(test.args, strings.NewReader(test.stdin), &stdout, io.Discard); err != nil {
+				t.Fatal(err)
+			}
+			if stdout.String() != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, stdout.String())
+			}
+		})
+	}
+}
+
+func TestBencodeCommandFile(t *testing.T) {
+	data, err := os.ReadFile("../../test/test.torrent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var jsonOut bytes.Buffer
+	if err := run([]string{"bencode", "json", "../../test/test.torrent"}, nil, &jsonOut, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	var back bytes.Buffer
+	if err := run([]string{"bencode", "fromjson"}, &jsonOut, &back, io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(back.Bytes(), data) {
+		t.Error("expected json and fromjson to round trip the torrent")
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	tests := [][]string{
+		nil,
+		{"nope"},
+		{"bencode"},
+		{"bencode", "yaml"},
+		{"bencode", "json", "a", "b"},
+	}
+
+	for _, args := range tests {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			if err := run(args, strings.NewReader("i1e"), io.Discard, io.Discard); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
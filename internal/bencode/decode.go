@@ -0,0 +1,265 @@
+package bencode
+
+import (
+	"bytes"
+	"io"
+)
+
+// Decode decodes a single bencoded value from data without going through a
+// bufio.Reader. It returns the same tree as Decoder.Decode, except that byte
+// strings are returned as []byte sub-slices of data rather than copied into
+// strings. Callers must not modify data while the result is in use. Errors are
+// reported as *SyntaxError.
+func Decode(data []byte) (interface{}, error) {
+	return DefaultLimits.Decode(data)
+}
+
+// Decode is like the package-level Decode, but applies l instead of
+// DefaultLimits.
+func (l Limits) Decode(data []byte) (interface{}, error) {
+	d := newDecodeState(data, l)
+	if err := d.lim.checkBytes(int64(len(data))); err != nil {
+		return nil, d.error(int(d.lim.limits.MaxBytes), err)
+	}
+	value, err := d.value()
+	if err != nil {
+		return nil, err
+	}
+	if d.off != len(d.data) {
+		return nil, d.error(d.off, ErrTrailingData)
+	}
+	return value, nil
+}
+
+// Validate checks that data is a single valid bencoded value, returning the
+// *SyntaxError describing the first problem if not.
+func Validate(data []byte) error {
+	return DefaultLimits.Validate(data)
+}
+
+// Validate is like the package-level Validate, but applies l instead of
+// DefaultLimits.
+func (l Limits) Validate(data []byte) error {
+	d := newDecodeState(data, l)
+	if err := d.lim.checkBytes(int64(len(data))); err != nil {
+		return d.error(int(d.lim.limits.MaxBytes), err)
+	}
+	if err := d.skip(); err != nil {
+		return err
+	}
+	if d.off != len(d.data) {
+		return d.error(d.off, ErrTrailingData)
+	}
+	return nil
+}
+
+// decodeState decodes bencoded values directly from a byte slice. When
+// strings is set, byte strings in generic values are copied into strings
+// instead of being returned as sub-slices of data.
+type decodeState struct {
+	data    []byte
+	off     int
+	strings bool
+	lim     limiter
+	path    path
+}
+
+func newDecodeState(data []byte, limits Limits) *decodeState {
+	return &decodeState{data: data, lim: limiter{limits: limits}}
+}
+
+func (d *decodeState) error(offset int, err error) error {
+	return &SyntaxError{Offset: int64(offset), Path: d.path.String(), Err: err}
+}
+
+// truncated reports that the input ended before the current value did.
+func (d *decodeState) truncated(err error) error {
+	if len(d.data) == 0 {
+		return d.error(d.off, wrapCause(err, io.EOF))
+	}
+	return d.error(len(d.data), wrapCause(err, io.ErrUnexpectedEOF))
+}
+
+// open consumes the leading byte of a list or dictionary.
+func (d *decodeState) open() error {
+	if err := d.lim.element(); err != nil {
+		return d.error(d.off, err)
+	}
+	if err := d.lim.enter(); err != nil {
+		return d.error(d.off, err)
+	}
+	d.off++
+	return nil
+}
+
+// more reports whether the current list or dictionary has more items,
+// consuming its ending byte if not.
+func (d *decodeState) more() (bool, error) {
+	if d.off >= len(d.data) {
+		return false, d.truncated(ErrInvalidEndingByte)
+	}
+	if d.data[d.off] == 'e' {
+		d.off++
+		return false, nil
+	}
+	return true, nil
+}
+
+func (d *decodeState) value() (interface{}, error) {
+	if d.off >= len(d.data) {
+		return nil, d.truncated(ErrInvalidLeadingByte)
+	}
+	switch d.data[d.off] {
+	case 'i':
+		return d.int()
+	case 'l':
+		return d.list()
+	case 'd':
+		return d.dict()
+	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		s, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		if d.strings {
+			return string(s), nil
+		}
+		return s, nil
+	default:
+		return nil, d.error(d.off, ErrInvalidLeadingByte)
+	}
+}
+
+func (d *decodeState) string() ([]byte, error) {
+	if err := d.lim.element(); err != nil {
+		return nil, d.error(d.off, err)
+	}
+	colon := bytes.IndexByte(d.data[d.off:], ':')
+	if colon < 0 {
+		return nil, d.truncated(ErrReadLengthFailed)
+	}
+	length, ok := parseUint(d.data[d.off : d.off+colon])
+	if !ok {
+		return nil, d.error(d.off, ErrInvalidLengthFormat)
+	}
+	if err := d.lim.checkString(int64(length)); err != nil {
+		return nil, d.error(d.off, err)
+	}
+	start := d.off + colon + 1
+	if uint64(len(d.data)-start) < length {
+		return nil, d.truncated(ErrReadValueFailed)
+	}
+	end := start + int(length)
+	d.off = end
+	return d.data[start:end:end], nil
+}
+
+func (d *decodeState) int() (int64, error) {
+	if err := d.lim.element(); err != nil {
+		return 0, d.error(d.off, err)
+	}
+	end := bytes.IndexByte(d.data[d.off:], 'e')
+	if end < 0 {
+		return 0, d.truncated(ErrInvalidEndingByte)
+	}
+	value, ok := parseInt(d.data[d.off+1 : d.off+end])
+	if !ok {
+		return 0, d.error(d.off+1, ErrReadValueFailed)
+	}
+	d.off += end + 1
+	return value, nil
+}
+
+func (d *decodeState) list() ([]interface{}, error) {
+	if err := d.open(); err != nil {
+		return nil, err
+	}
+	defer d.lim.leave()
+	list := make([]interface{}, 0)
+	for {
+		more, err := d.more()
+		if err != nil {
+			return nil, err
+		}
+		if !more {
+			return list, nil
+		}
+		d.path.pushIndex(len(list))
+		value, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		d.path.pop()
+		list = append(list, value)
+	}
+}
+
+func (d *decodeState) dict() (map[string]interface{}, error) {
+	if err := d.open(); err != nil {
+		return nil, err
+	}
+	defer d.lim.leave()
+	dict := make(map[string]interface{})
+	for {
+		more, err := d.more()
+		if err != nil {
+			return nil, err
+		}
+		if !more {
+			return dict, nil
+		}
+		key, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		d.path.pushRawKey(key)
+		value, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		d.path.pop()
+		dict[string(key)] = value
+	}
+}
+
+// parseUint parses a non-empty run of decimal digits without allocating.
+func parseUint(b []byte) (uint64, bool) {
+	if len(b) == 0 {
+		return 0, false
+	}
+	var n uint64
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		if n > (1<<63-1)/10 {
+			return 0, false
+		}
+		n = n*10 + uint64(c-'0')
+		if n > 1<<63-1 {
+			return 0, false
+		}
+	}
+	return n, true
+}
+
+// parseInt parses an optionally signed decimal integer without allocating,
+// accepting the same inputs as strconv.ParseInt in base 10.
+func parseInt(b []byte) (int64, bool) {
+	neg := false
+	if len(b) > 0 && (b[0] == '-' || b[0] == '+') {
+		neg = b[0] == '-'
+		b = b[1:]
+	}
+	n, ok := parseUint(b)
+	if !ok {
+		if neg && string(b) == "9223372036854775808" {
+			return -1 << 63, true
+		}
+		return 0, false
+	}
+	if neg {
+		return -int64(n), true
+	}
+	return int64(n), true
+}
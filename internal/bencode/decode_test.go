@@ -0,0 +1,110 @@
+package bencode
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeBytes(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    interface{}
+		expectedErr error
+	}{
+		{"4:spam", []byte("spam"), nil},
+		{"0:", []byte{}, nil},
+		{"i123e", int64(123), nil},
+		{"i-456e", int64(-456), nil},
+		{"i-9223372036854775808e", int64(-1 << 63), nil},
+		{"le", []interface{}{}, nil},
+		{"li123e4:spame", []interface{}{int64(123), []byte("spam")}, nil},
+		{"de", map[string]interface{}{}, nil},
+		{
+			"d3:bar4:spam3:fool1:ad1:bi1eeee",
+			map[string]interface{}{
+				"bar": []byte("spam"),
+				"foo": []interface{}{[]byte("a"), map[string]interface{}{"b": int64(1)}},
+			},
+			nil,
+		},
+		{"", nil, ErrInvalidLeadingByte},                       // Error case: empty input
+		{"x", nil, ErrInvalidLeadingByte},                      // Error case: unknown leading byte
+		{"4:sp", nil, ErrReadValueFailed},                      // Error case: length mismatch
+		{"4spam", nil, ErrReadLengthFailed},                    // Error case: missing colon
+		{"99999999999999999999:", nil, ErrInvalidLengthFormat}, // Error case: length overflow
+		{"i123", nil, ErrInvalidEndingByte},                    // Error case: missing ending 'e'
+		{"i12a3e", nil, ErrReadValueFailed},                    // Error case: invalid integer format
+		{"i9223372036854775808e", nil, ErrReadValueFailed},     // Error case: integer overflow
+		{"li123ei456e", nil, ErrInvalidEndingByte},             // Error case: unterminated list
+		{"d3:bar4:spam", nil, ErrInvalidEndingByte},            // Error case: unterminated dict
+		{"di1e4:spame", nil, ErrInvalidLengthFormat},           // Error case: non-string key
+		{"i1ei2e", nil, ErrTrailingData},                       // Error case: trailing data
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			t.Parallel()
+			result, err := Decode([]byte(test.input))
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("expected error %v, got %v for input %q", test.expectedErr, err, test.input)
+			}
+			if !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("expected %v, got %v for input %q", test.expected, result, test.input)
+			}
+		})
+	}
+}
+
+func TestDecodeBytesZeroCopy(t *testing.T) {
+	data := []byte("l4:spame")
+	result, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spam := result.([]interface{})[0].([]byte)
+	if &spam[0] != &data[3] {
+		t.Errorf("expected string to alias the input")
+	}
+	if cap(spam) != len(spam) {
+		t.Errorf("expected capacity %d, got %d", len(spam), cap(spam))
+	}
+}
+
+func benchmarkData(b *testing.B) []byte {
+	wd, _ := os.Getwd()
+	data, err := os.ReadFile(filepath.Join(wd, "..", "..", "test", "test.torrent"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return data
+}
+
+func BenchmarkDecode(b *testing.B) {
+	data := benchmarkData(b)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Decode(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecoder(b *testing.B) {
+	data := benchmarkData(b)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		decoder := NewDecoder(bufio.NewReader(bytes.NewReader(data)))
+		if _, err := decoder.Decode(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -3,8 +3,7 @@ package bencode
 import (
 	"bufio"
 	"errors"
-	"io"
-	"strconv"
+	"strings"
 )
 
 // Custom error types
@@ -17,133 +16,199 @@ var (
 	ErrReadValueFailed     = errors.New("failed to read value")
 )
 
-func Decode(data []byte) (interface{}, error) {
-	return nil, nil
-}
+// Errors reported for non-canonical input, by the Decoder in strict mode and
+// by the Writer.
+var (
+	ErrNonCanonicalInt    = errors.New("non-canonical integer")
+	ErrNonCanonicalLength = errors.New("non-canonical string length")
+	ErrUnsortedKeys       = errors.New("unsorted dictionary keys")
+	ErrDuplicateKey       = errors.New("duplicate dictionary key")
+)
 
+// Decoder reads bencoded values from a stream, either whole with Decode or
+// piece by piece with Token. Every error it returns is a *SyntaxError
+// carrying the offset and key path of the failure.
 type Decoder struct {
-	r *bufio.Reader
+	r       *bufio.Reader
+	off     int64
+	strict  bool
+	collect bool
+	found   []error
+	lim     limiter
+	stack   []frame
 }
 
 func NewDecoder(r *bufio.Reader) *Decoder {
-	return &Decoder{r: r}
+	return &Decoder{r: r, lim: limiter{limits: DefaultLimits}}
 }
 
 func (d *Decoder) Reset(r *bufio.Reader) {
 	d.r = r
+	d.off = 0
+	d.lim.reset()
+	d.stack = d.stack[:0]
 }
 
-func (d Decoder) Decode() (interface{}, error) {
-	leading, err := d.r.Peek(1)
-	if err != nil {
-		return nil, ErrInvalidLeadingByte
-	}
-	switch leading[0] {
-	case 'i':
-		return d.DecodeInt()
-	case 'l':
-		return d.DecodeList()
-	case 'd':
-		return d.DecodeDict()
-	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
-		return d.DecodeString()
-	default:
-		return nil, ErrInvalidLeadingByte
-	}
+// SetLimits replaces the decoder's resource limits, which default to
+// DefaultLimits. Exceeding a limit is reported as a *SyntaxError wrapping
+// ErrStringTooLong, ErrMaxDepthExceeded, ErrTooManyElements or
+// ErrInputTooLarge. MaxBytes counts every byte read since the last Reset.
+func (d *Decoder) SetLimits(limits Limits) {
+	d.lim.limits = limits
 }
 
-func (d Decoder) DecodeString() (string, error) {
-	lenStr, err := d.r.ReadString(':')
-	if err != nil {
-		return "", ErrReadLengthFailed
-	}
-	length, err := strconv.Atoi(lenStr[:len(lenStr)-1])
+// DisallowNonCanonical makes the decoder reject input that is not in canonical
+// form: integers with leading zeros, a plus sign or negative zero, string
+// lengths with leading zeros or a sign, and dictionaries whose keys are
+// unsorted or duplicated.
+func (d *Decoder) DisallowNonCanonical() {
+	d.strict = true
+}
+
+// InputOffset returns the number of bytes consumed from the reader so far.
+func (d *Decoder) InputOffset() int64 {
+	return d.off
+}
+
+// expect checks that the next value starts with one of the given bytes.
+func (d *Decoder) expect(leading string) error {
+	next, err := d.r.Peek(1)
 	if err != nil {
-		return "", ErrInvalidLengthFormat
+		return d.readFailed(ErrReadLeadingFailed, err)
 	}
-	value := make([]byte, length)
-	if _, err := io.ReadFull(d.r, value); err != nil {
-		return "", ErrReadValueFailed
+	if !strings.ContainsRune(leading, rune(next[0])) {
+		return d.error(d.off, ErrInvalidLeadingByte)
 	}
-	return string(value), nil
+	return nil
 }
 
-func (d Decoder) DecodeInt() (int64, error) {
-	leading, err := d.r.ReadByte()
+func (d *Decoder) Decode() (interface{}, error) {
+	tok, err := d.Token()
 	if err != nil {
-		return 0, ErrReadLeadingFailed
+		return nil, err
 	}
-	if leading != 'i' {
-		return 0, ErrInvalidLeadingByte
+	return d.value(tok)
+}
+
+func (d *Decoder) value(tok Token) (interface{}, error) {
+	switch tok.Kind {
+	case TokenInt:
+		return tok.Int, nil
+	case TokenString:
+		return tok.String, nil
+	case TokenListStart:
+		return d.list()
+	case TokenDictStart:
+		return d.dict()
+	default:
+		return nil, d.error(d.off-1, ErrInvalidLeadingByte)
 	}
-	valueString, err := d.r.ReadString('e')
-	if err != nil {
-		if err == io.EOF {
-			return 0, ErrInvalidEndingByte
+}
+
+func (d *Decoder) DecodeString() (string, error) {
+	if top := d.top(); top == nil || !top.dict || top.n%2 == 1 {
+		if err := d.expect("0123456789"); err != nil {
+			return "", err
 		}
-		return 0, ErrReadValueFailed
 	}
-	value, err := strconv.ParseInt(valueString[:len(valueString)-1], 10, 64)
+	tok, err := d.Token()
 	if err != nil {
-		return 0, ErrReadValueFailed
+		return "", err
 	}
-	return value, nil
+	return tok.String, nil
 }
 
-func (d Decoder) DecodeList() ([]interface{}, error) {
-	leading, err := d.r.ReadByte()
+func (d *Decoder) DecodeInt() (int64, error) {
+	if err := d.expect("i"); err != nil {
+		return 0, err
+	}
+	tok, err := d.Token()
 	if err != nil {
-		return nil, ErrReadLeadingFailed
+		return 0, err
+	}
+	return tok.Int, nil
+}
+
+func (d *Decoder) DecodeList() ([]interface{}, error) {
+	if err := d.expect("l"); err != nil {
+		return nil, err
+	}
+	if _, err := d.Token(); err != nil {
+		return nil, err
+	}
+	return d.list()
+}
+
+func (d *Decoder) DecodeDict() (map[string]interface{}, error) {
+	if err := d.expect("d"); err != nil {
+		return nil, err
 	}
-	if leading != 'l' {
-		return nil, ErrInvalidLeadingByte
+	if _, err := d.Token(); err != nil {
+		return nil, err
 	}
+	return d.dict()
+}
+
+// list decodes the rest of a list whose ListStart token has been read.
+func (d *Decoder) list() ([]interface{}, error) {
 	list := make([]interface{}, 0)
 	for {
-		next, err := d.r.Peek(1)
+		tok, err := d.Token()
 		if err != nil {
-			return nil, ErrInvalidEndingByte
+			return nil, err
 		}
-		if next[0] == 'e' {
-			d.r.ReadByte()
-			break
+		if tok.Kind == TokenEnd {
+			return list, nil
 		}
-		value, err := d.Decode()
+		value, err := d.value(tok)
 		if err != nil {
 			return nil, err
 		}
 		list = append(list, value)
 	}
-	return list, nil
 }
 
-func (d Decoder) DecodeDict() (map[string]interface{}, error) {
-	leading, err := d.r.ReadByte()
-	if err != nil {
-		return nil, ErrReadLeadingFailed
-	}
-	if leading != 'd' {
-		return nil, ErrInvalidLeadingByte
-	}
+// dict decodes the rest of a dictionary whose DictStart token has been read.
+func (d *Decoder) dict() (map[string]interface{}, error) {
 	dict := make(map[string]interface{})
 	for {
-		next, err := d.r.Peek(1)
+		tok, err := d.Token()
 		if err != nil {
-			return nil, ErrInvalidEndingByte
+			return nil, err
 		}
-		if next[0] == 'e' {
-			d.r.ReadByte()
-			break
+		if tok.Kind == TokenEnd {
+			return dict, nil
 		}
-		key, err := d.DecodeString()
-		if err != nil {
+		key := tok.String
+		if tok, err = d.Token(); err != nil {
 			return nil, err
 		}
-		value, err := d.Decode()
+		value, err := d.value(tok)
 		if err != nil {
 			return nil, err
 		}
 		dict[key] = value
 	}
-	return dict, nil
+}
+
+// isCanonicalUint reports whether s is a run of digits without leading zeros.
+func isCanonicalUint(s string) bool {
+	if s == "" || (s[0] == '0' && len(s) > 1) {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isCanonicalInt reports whether s is an integer in canonical form: no plus
+// sign, no leading zeros and no negative zero.
+func isCanonicalInt(s string) bool {
+	if len(s) > 0 && s[0] == '-' {
+		return s != "-0" && isCanonicalUint(s[1:])
+	}
+	return isCanonicalUint(s)
 }
@@ -2,6 +2,7 @@ package bencode
 
 import (
 	"bufio"
+	"errors"
 	"reflect"
 	"strings"
 	"testing"
@@ -29,7 +30,7 @@ func TestDecodeString(t *testing.T) {
 			decoder := NewDecoder(reader)
 			result, err := decoder.DecodeString()
 
-			if err != test.expectedErr {
+			if !errors.Is(err, test.expectedErr) {
 				t.Errorf("expected error %v, got %v for input %q", test.expectedErr, err, test.input)
 			}
 			if result != test.expected {
@@ -60,7 +61,7 @@ func TestDecodeInt(t *testing.T) {
 			decoder := NewDecoder(reader)
 			result, err := decoder.DecodeInt()
 
-			if err != test.expectedErr {
+			if !errors.Is(err, test.expectedErr) {
 				t.Errorf("expected error %v, got %v for input %q", test.expectedErr, err, test.input)
 			}
 			if result != test.expected {
@@ -91,7 +92,7 @@ func TestDecodeList(t *testing.T) {
 			decoder := NewDecoder(reader)
 			result, err := decoder.DecodeList()
 
-			if err != test.expectedErr {
+			if !errors.Is(err, test.expectedErr) {
 				t.Errorf("expected error %v, got %v for input %q", test.expectedErr, err, test.input)
 			}
 			if !reflect.DeepEqual(result, test.expected) {
@@ -123,7 +124,7 @@ func TestDecodeDict(t *testing.T) {
 			decoder := NewDecoder(reader)
 			result, err := decoder.DecodeDict()
 
-			if err != test.expectedErr {
+			if !errors.Is(err, test.expectedErr) {
 				t.Errorf("expected error %v, got %v for input %q", test.expectedErr, err, test.input)
 			}
 			if !reflect.DeepEqual(result, test.expected) {
@@ -166,7 +167,7 @@ func TestDecode(t *testing.T) {
 			reader := bufio.NewReader(strings.NewReader(test.input))
 			decoder := NewDecoder(reader)
 			result, err := decoder.Decode()
-			if err != test.expectedErr {
+			if !errors.Is(err, test.expectedErr) {
 				t.Fatalf("expected error %v, got %v for input %q", test.expectedErr, err, test.input)
 			}
 			if !reflect.DeepEqual(result, test.expected) {
@@ -175,3 +176,107 @@ func TestDecode(t *testing.T) {
 		})
 	}
 }
+
+func TestDecodeStrict(t *testing.T) {
+	tests := []struct {
+		input          string
+		expected       interface{}
+		expectedErr    error
+		expectedOffset int64
+	}{
+		{"d1:ai0e1:bi-1e1:c0:e", map[string]interface{}{"a": int64(0), "b": int64(-1), "c": ""}, nil, 0},
+		{"i-0e", nil, ErrNonCanonicalInt, 1},              // Error case: negative zero
+		{"i03e", nil, ErrNonCanonicalInt, 1},              // Error case: leading zero
+		{"i+3e", nil, ErrNonCanonicalInt, 1},              // Error case: plus sign
+		{"l4:spami-03ee", nil, ErrNonCanonicalInt, 8},     // Error case: nested leading zero
+		{"03:abc", nil, ErrNonCanonicalLength, 0},         // Error case: length with leading zero
+		{"d01:ai1ee", nil, ErrNonCanonicalLength, 1},      // Error case: key length with leading zero
+		{"d1:bi1e1:ai2ee", nil, ErrUnsortedKeys, 7},       // Error case: unsorted keys
+		{"d1:ai1e1:ai2ee", nil, ErrDuplicateKey, 7},       // Error case: duplicate keys
+		{"d1:ad1:bi1e1:ai1eee", nil, ErrUnsortedKeys, 11}, // Error case: nested unsorted keys
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			t.Parallel()
+			decoder := NewDecoder(bufio.NewReader(strings.NewReader(test.input)))
+			decoder.DisallowNonCanonical()
+			result, err := decoder.Decode()
+
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("expected error %v, got %v for input %q", test.expectedErr, err, test.input)
+			}
+			if err != nil {
+				var syntaxErr *SyntaxError
+				if !errors.As(err, &syntaxErr) {
+					t.Fatalf("expected *SyntaxError, got %T for input %q", err, test.input)
+				}
+				if syntaxErr.Offset != test.expectedOffset {
+					t.Errorf("expected offset %d, got %d for input %q", test.expectedOffset, syntaxErr.Offset, test.input)
+				}
+				return
+			}
+			if !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("expected %v, got %v for input %q", test.expected, result, test.input)
+			}
+		})
+	}
+}
+
+func TestDecodeNonStrict(t *testing.T) {
+	decoder := NewDecoder(bufio.NewReader(strings.NewReader("d1:bi03e1:bi-0ee")))
+	result, err := decoder.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"b": int64(0)}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+	if decoder.InputOffset() != 16 {
+		t.Errorf("expected offset %d, got %d", 16, decoder.InputOffset())
+	}
+}
+
+func TestNonCanonical(t *testing.T) {
+	type finding struct {
+		path string
+		err  error
+	}
+	tests := []struct {
+		input       string
+		expected    []finding
+		expectedErr error
+	}{
+		{"d1:ai0e1:bli1eee", nil, nil},
+		{"d1:bi03e1:ad02:cci-0e1:bi1eee", []finding{
+			{"b", ErrNonCanonicalInt},
+			{"a", ErrUnsortedKeys},
+			{"a", ErrNonCanonicalLength},
+			{"a.cc", ErrNonCanonicalInt},
+			{"a.b", ErrUnsortedKeys},
+		}, nil},
+		{"li+1ei2ee", []finding{{"[0]", ErrNonCanonicalInt}}, nil},
+		{"d1:ai1e1:ai2e", []finding{{"a", ErrDuplicateKey}}, ErrInvalidEndingByte},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			t.Parallel()
+			decoder := NewDecoder(bufio.NewReader(strings.NewReader(test.input)))
+			found, err := decoder.NonCanonical()
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("expected error %v, got %v", test.expectedErr, err)
+			}
+			if len(found) != len(test.expected) {
+				t.Fatalf("expected %d findings, got %v", len(test.expected), found)
+			}
+			for i, expected := range test.expected {
+				var syntaxErr *SyntaxError
+				if !errors.As(found[i], &syntaxErr) || !errors.Is(found[i], expected.err) || syntaxErr.Path != expected.path {
+					t.Errorf("finding %d: expected %v at %q, got %v", i, expected.err, expected.path, found[i])
+				}
+			}
+		})
+	}
+}
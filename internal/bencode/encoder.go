@@ -3,11 +3,13 @@ package bencode
 import (
 	"fmt"
 	"io"
+	"reflect"
 	"sort"
 )
 
 var (
-	ErrInvalidType = fmt.Errorf("invalid type")
+	ErrInvalidType            = fmt.Errorf("invalid type")
+	ErrInvalidMarshalerOutput = fmt.Errorf("invalid marshaler output")
 )
 
 type Encoder struct {
@@ -24,6 +26,8 @@ func (e *Encoder) Reset(w io.Writer) {
 
 func (e *Encoder) Encode(v interface{}) error {
 	switch i := v.(type) {
+	case Marshaler:
+		return e.encodeMarshaler(i)
 	case string:
 		return e.EncodeString(i)
 	case int64:
@@ -32,10 +36,11 @@ func (e *Encoder) Encode(v interface{}) error {
 		return e.EncodeList(i)
 	case map[string]interface{}:
 		return e.EncodeDict(i)
+	case []byte:
+		return e.encodeBytes(i)
 	default:
-		return ErrInvalidType
+		return e.encodeValue(reflect.ValueOf(v))
 	}
-	return nil
 }
 
 func (e *Encoder) EncodeString(v string) error {
@@ -43,6 +48,14 @@ func (e *Encoder) EncodeString(v string) error {
 	return err
 }
 
+func (e *Encoder) encodeBytes(v []byte) error {
+	if _, err := fmt.Fprintf(e.w, "%d:", len(v)); err != nil {
+		return err
+	}
+	_, err := e.w.Write(v)
+	return err
+}
+
 func (e *Encoder) EncodeInt(v int64) error {
 	_, err := fmt.Fprintf(e.w, "i%de", v)
 	return err
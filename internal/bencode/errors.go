@@ -0,0 +1,101 @@
+package bencode
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SyntaxError describes a problem found at a position in the input. Err wraps
+// one of the package's sentinel errors, and the underlying I/O error when
+// there is one, so both can be matched with errors.Is.
+type SyntaxError struct {
+	Offset int64  // offset of the offending byte
+	Path   string // key path of the offending value, such as info.files[3].path[1]
+	Err    error
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("%v at offset %d", e.Err, e.Offset)
+	}
+	return fmt.Sprintf("%v at offset %d in %s", e.Err, e.Offset, e.Path)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// wrapCause attaches the underlying cause to a sentinel error.
+func wrapCause(err, cause error) error {
+	if cause == nil {
+		return err
+	}
+	return fmt.Errorf("%w: %w", err, cause)
+}
+
+// pathElem is a dictionary key or a list index. raw holds keys that are still
+// sub-slices of the input, so that tracking the path while decoding a byte
+// slice does not allocate.
+type pathElem struct {
+	isKey bool
+	key   string
+	raw   []byte
+	index int
+}
+
+type path []pathElem
+
+func (p *path) pushKey(key string) {
+	*p = append(*p, pathElem{isKey: true, key: key})
+}
+
+func (p *path) pushRawKey(key []byte) {
+	*p = append(*p, pathElem{isKey: true, raw: key})
+}
+
+func (p *path) pushIndex(index int) {
+	*p = append(*p, pathElem{index: index})
+}
+
+func (p *path) pop() {
+	*p = (*p)[:len(*p)-1]
+}
+
+func (p path) String() string {
+	var b strings.Builder
+	for i, elem := range p {
+		if !elem.isKey {
+			b.WriteByte('[')
+			b.WriteString(strconv.Itoa(elem.index))
+			b.WriteByte(']')
+			continue
+		}
+		key := elem.key
+		if elem.raw != nil {
+			key = string(elem.raw)
+		}
+		if !isPlainKey(key) {
+			b.WriteString("[" + strconv.Quote(key) + "]")
+			continue
+		}
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(key)
+	}
+	return b.String()
+}
+
+// isPlainKey reports whether key can be written in a path without quoting.
+func isPlainKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, r := range key {
+		if r == '.' || r == '[' || r == ']' || r == '"' || !strconv.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+package bencode
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestSyntaxErrorPath(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedErr    error
+		expectedPath   string
+		expectedOffset int64
+	}{
+		{"d4:infod5:filesldededed4:pathl1:ai1xeeeeee", ErrReadValueFailed, "info.files[3].path[1]", 34},
+		{"d4:infod12:piece length4:spamee", nil, "", 0},
+		{"d4:infod12:piece lengthi1", ErrInvalidEndingByte, "info.piece length", 25},
+		{"d3:a.bl", ErrInvalidEndingByte, `["a.b"]`, 7},
+		{"li1eli2ex", ErrInvalidLeadingByte, "[1][1]", 8},
+		{"x", ErrInvalidLeadingByte, "", 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			t.Parallel()
+			decoder := NewDecoder(bufio.NewReader(strings.NewReader(test.input)))
+			_, decoderErr := decoder.Decode()
+			_, decodeErr := Decode([]byte(test.input))
+
+			for _, err := range []error{decoderErr, decodeErr} {
+				if !errors.Is(err, test.expectedErr) {
+					t.Fatalf("expected error %v, got %v", test.expectedErr, err)
+				}
+				if err == nil {
+					continue
+				}
+				var syntaxErr *SyntaxError
+				if !errors.As(err, &syntaxErr) {
+					t.Fatalf("expected *SyntaxError, got %T", err)
+				}
+				if syntaxErr.Path != test.expectedPath {
+					t.Errorf("expected path %q, got %q", test.expectedPath, syntaxErr.Path)
+				}
+				if syntaxErr.Offset != test.expectedOffset {
+					t.Errorf("expected offset %d, got %d", test.expectedOffset, syntaxErr.Offset)
+				}
+			}
+		})
+	}
+}
+
+func TestSyntaxErrorWrapsIO(t *testing.T) {
+	t.Run("unexpected EOF", func(t *testing.T) {
+		decoder := NewDecoder(bufio.NewReader(strings.NewReader("l4:sp")))
+		_, err := decoder.Decode()
+		if !errors.Is(err, ErrReadValueFailed) || !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("expected %v wrapping %v, got %v", ErrReadValueFailed, io.ErrUnexpectedEOF, err)
+		}
+	})
+
+	t.Run("EOF between values", func(t *testing.T) {
+		decoder := NewDecoder(bufio.NewReader(strings.NewReader("i1e")))
+		if _, err := decoder.Decode(); err != nil {
+			t.Fatal(err)
+		}
+		_, err := decoder.Decode()
+		if !errors.Is(err, io.EOF) {
+			t.Errorf("expected %v, got %v", io.EOF, err)
+		}
+	})
+
+	t.Run("reader error", func(t *testing.T) {
+		readErr := errors.New("connection reset")
+		r := io.MultiReader(strings.NewReader("d3:foo"), iotest.ErrReader(readErr))
+		decoder := NewDecoder(bufio.NewReader(r))
+		_, err := decoder.Decode()
+		if !errors.Is(err, readErr) {
+			t.Fatalf("expected %v, got %v", readErr, err)
+		}
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) || syntaxErr.Path != "foo" || syntaxErr.Offset != 6 {
+			t.Errorf("expected error at offset 6 in foo, got %v", err)
+		}
+	})
+}
+
+func TestUnmarshalErrorPath(t *testing.T) {
+	var v struct {
+		Info struct {
+			Files []struct {
+				Path []string `bencode:"path"`
+			} `bencode:"files"`
+		} `bencode:"info"`
+	}
+	err := Unmarshal([]byte("d4:infod5:filesld4:pathl1:aeed4:pathl1:ai1eeeeee"), &v)
+	if !errors.Is(err, ErrUnmarshalType) {
+		t.Fatalf("expected error %v, got %v", ErrUnmarshalType, err)
+	}
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("expected *SyntaxError, got %T", err)
+	}
+	if expected := "info.files[1].path[1]"; syntaxErr.Path != expected {
+		t.Errorf("expected path %q, got %q", expected, syntaxErr.Path)
+	}
+	if syntaxErr.Offset != 40 {
+		t.Errorf("expected offset %d, got %d", 40, syntaxErr.Offset)
+	}
+}
@@ -0,0 +1,110 @@
+package bencode
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+var fuzzSeeds = []string{
+	"i42e",
+	"i-0e",
+	"4:spam",
+	"le",
+	"li1e4:spame",
+	"de",
+	"d3:bar4:spam3:fooi42ee",
+	"d1:bi1e1:ai2ee",
+	"d4:infod6:lengthi1e4:name1:a12:piece lengthi16384e6:pieces0:ee",
+	"9999999999:",
+	"lllllllllle",
+}
+
+// FuzzDecoder checks that anything the Decoder accepts re-encodes to a value
+// that decodes the same way, both through the Decoder and Decode.
+func FuzzDecoder(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		decoder := NewDecoder(bufio.NewReader(bytes.NewReader(data)))
+		v, err := decoder.Decode()
+		if err != nil {
+			return
+		}
+		encoded, err := Marshal(v)
+		if err != nil {
+			t.Fatalf("failed to encode %v: %v", v, err)
+		}
+
+		decoder = NewDecoder(bufio.NewReader(bytes.NewReader(encoded)))
+		decoder.DisallowNonCanonical()
+		again, err := decoder.Decode()
+		if err != nil {
+			t.Fatalf("failed to decode %q: %v", encoded, err)
+		}
+		if !reflect.DeepEqual(v, again) {
+			t.Fatalf("expected %v, got %v", v, again)
+		}
+
+		var fromBytes interface{}
+		if err := Unmarshal(encoded, &fromBytes); err != nil {
+			t.Fatalf("failed to unmarshal %q: %v", encoded, err)
+		}
+		if !reflect.DeepEqual(v, fromBytes) {
+			t.Fatalf("expected %v, got %v", v, fromBytes)
+		}
+	})
+}
+
+// FuzzDecode checks that Decode never panics and round-trips what it accepts.
+func FuzzDecode(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		v, err := Decode(data)
+		if err != nil {
+			return
+		}
+		encoded, err := Marshal(v)
+		if err != nil {
+			t.Fatalf("failed to encode %v: %v", v, err)
+		}
+		again, err := Decode(encoded)
+		if err != nil {
+			t.Fatalf("failed to decode %q: %v", encoded, err)
+		}
+		if !reflect.DeepEqual(v, again) {
+			t.Fatalf("expected %v, got %v", v, again)
+		}
+	})
+}
+
+// FuzzEncoder checks that the Encoder always produces canonical output that
+// decodes back to the original value.
+func FuzzEncoder(f *testing.F) {
+	f.Add("spam", int64(42))
+	f.Add("", int64(0))
+	f.Add("\x00\xff", int64(-1<<63))
+	f.Fuzz(func(t *testing.T, s string, n int64) {
+		v := map[string]interface{}{
+			s:      n,
+			"list": []interface{}{s, n, map[string]interface{}{}},
+		}
+		var buf bytes.Buffer
+		if err := NewEncoder(&buf).Encode(v); err != nil {
+			t.Fatal(err)
+		}
+		decoder := NewDecoder(bufio.NewReader(bytes.NewReader(buf.Bytes())))
+		decoder.DisallowNonCanonical()
+		decoded, err := decoder.Decode()
+		if err != nil {
+			t.Fatalf("failed to decode %q: %v", buf.Bytes(), err)
+		}
+		if !reflect.DeepEqual(v, decoded) {
+			t.Fatalf("expected %v, got %v", v, decoded)
+		}
+	})
+}
@@ -0,0 +1,185 @@
+package bencode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var ErrInvalidJSON = errors.New("json value has no bencode equivalent")
+
+// bytesKey marks a JSON object standing for a byte string that is not valid
+// UTF-8, as in {"$bytes": "<base64>"}.
+const bytesKey = "$bytes"
+
+// ToJSON converts a single bencoded value to JSON. Integers become numbers,
+// lists become arrays and dictionaries become objects. Byte strings that are
+// valid UTF-8 become JSON strings, and any other byte string becomes an object
+// {"$bytes": "<base64>"}.
+//
+// Dictionary keys that are not valid UTF-8 are written as "$bytes:<base64>",
+// and keys starting with "$" are escaped by doubling the "$", so FromJSON can
+// always recover the original value. For canonical input it reproduces the
+// input byte for byte.
+func ToJSON(data []byte) ([]byte, error) {
+	v, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(toJSONValue(v)); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}), nil
+}
+
+func toJSONValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []byte:
+		if utf8.Valid(v) {
+			return string(v)
+		}
+		return map[string]string{bytesKey: base64.StdEncoding.EncodeToString(v)}
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = toJSONValue(item)
+		}
+		return list
+	case map[string]interface{}:
+		dict := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			dict[escapeJSONKey(key)] = toJSONValue(item)
+		}
+		return dict
+	default:
+		return v
+	}
+}
+
+func escapeJSONKey(key string) string {
+	switch {
+	case !utf8.ValidString(key):
+		return bytesKey + ":" + base64.StdEncoding.EncodeToString([]byte(key))
+	case strings.HasPrefix(key, "$"):
+		return "$" + key
+	default:
+		return key
+	}
+}
+
+// FromJSON converts JSON produced by ToJSON back to bencode. Numbers must be
+// integers that fit in an int64; booleans, null and fractional numbers are
+// rejected with ErrInvalidJSON.
+func FromJSON(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("%w: trailing data after value", ErrInvalidJSON)
+	}
+
+	var p path
+	value, err := fromJSONValue(v, &p)
+	if err != nil {
+		return nil, err
+	}
+	return Marshal(value)
+}
+
+func fromJSONValue(v interface{}, p *path) (interface{}, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		n, err := strconv.ParseInt(string(v), 10, 64)
+		if err != nil {
+			return nil, jsonError(p, "number %s is not an int64", v)
+		}
+		return n, nil
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			p.pushIndex(i)
+			value, err := fromJSONValue(item, p)
+			if err != nil {
+				return nil, err
+			}
+			p.pop()
+			list[i] = value
+		}
+		return list, nil
+	case map[string]interface{}:
+		if encoded, ok := v[bytesKey]; ok && len(v) == 1 {
+			s, ok := encoded.(string)
+			if !ok {
+				return nil, jsonError(p, "%s value must be a string", bytesKey)
+			}
+			b, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return nil, jsonError(p, "%s value: %v", bytesKey, err)
+			}
+			return b, nil
+		}
+
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		dict := make(map[string]interface{}, len(v))
+		for _, key := range keys {
+			p.pushKey(key)
+			name, err := unescapeJSONKey(key)
+			if err != nil {
+				return nil, jsonError(p, "%v", err)
+			}
+			if _, ok := dict[name]; ok {
+				return nil, jsonError(p, "duplicate key %q", name)
+			}
+			value, err := fromJSONValue(v[key], p)
+			if err != nil {
+				return nil, err
+			}
+			p.pop()
+			dict[name] = value
+		}
+		return dict, nil
+	default:
+		return nil, jsonError(p, "unsupported %T value", v)
+	}
+}
+
+func unescapeJSONKey(key string) (string, error) {
+	switch {
+	case strings.HasPrefix(key, "$$"):
+		return key[1:], nil
+	case strings.HasPrefix(key, bytesKey+":"):
+		b, err := base64.StdEncoding.DecodeString(key[len(bytesKey)+1:])
+		return string(b), err
+	case strings.HasPrefix(key, "$"):
+		return "", errors.New("unescaped $ in key")
+	default:
+		return key, nil
+	}
+}
+
+func jsonError(p *path, format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+	if s := p.String(); s != "" {
+		msg = s + ": " + msg
+	}
+	return fmt.Errorf("%w: %s", ErrInvalidJSON, msg)
+}
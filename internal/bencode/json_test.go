@@ -0,0 +1,89 @@
+package bencode
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestToJSON(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"i-42e", `-42`},
+		{"4:spam", `"spam"`},
+		{"0:", `""`},
+		{"2:\xff\x00", `{"$bytes":"/wA="}`},
+		{"l4:spami42ee", `["spam",42]`},
+		{"le", `[]`},
+		{"de", `{}`},
+		{"d4:listli1ee3:url10:http://a&be", `{"list":[1],"url":"http://a&b"}`},
+		{"d6:$bytes1:x1:a1:be", `{"$$bytes":"x","a":"b"}`},
+		{"d1:\xffi1ee", `{"$bytes:/w==":1}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			t.Parallel()
+			output, err := ToJSON([]byte(test.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(output) != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, output)
+			}
+
+			back, err := FromJSON(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(back) != test.input {
+				t.Errorf("expected round trip to %q, got %q", test.input, back)
+			}
+		})
+	}
+}
+
+func TestToJSONTorrent(t *testing.T) {
+	data, err := os.ReadFile("../../test/test.torrent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := ToJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := FromJSON(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(back) != string(data) {
+		t.Error("expected JSON round trip to reproduce the torrent")
+	}
+}
+
+func TestFromJSONErrors(t *testing.T) {
+	tests := []string{
+		`1.5`,
+		`1e3`,
+		`true`,
+		`null`,
+		`[1, null]`,
+		`99999999999999999999`,
+		`{"$bytes": 1}`,
+		`{"$bytes": "not base64!"}`,
+		`{"$key": 1}`,
+		`{"$$a": 1, "$bytes:JGE=": 2}`,
+		`1 2`,
+	}
+
+	for _, test := range tests {
+		t.Run(test, func(t *testing.T) {
+			t.Parallel()
+			if _, err := FromJSON([]byte(test)); !errors.Is(err, ErrInvalidJSON) {
+				t.Errorf("expected error %v, got %v", ErrInvalidJSON, err)
+			}
+		})
+	}
+}
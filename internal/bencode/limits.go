@@ -0,0 +1,75 @@
+package bencode
+
+import "errors"
+
+var (
+	ErrStringTooLong    = errors.New("string exceeds maximum length")
+	ErrMaxDepthExceeded = errors.New("maximum nesting depth exceeded")
+	ErrTooManyElements  = errors.New("too many elements")
+	ErrInputTooLarge    = errors.New("input exceeds maximum size")
+)
+
+// Limits bounds the resources spent decoding a single value. A zero field
+// means no limit.
+type Limits struct {
+	MaxStringLength int64 // longest byte string
+	MaxDepth        int   // deepest nesting of lists and dictionaries
+	MaxElements     int64 // total number of values, including dictionary keys
+	MaxBytes        int64 // total bytes of input
+}
+
+// DefaultLimits are used by NewDecoder, Decode, Unmarshal and Validate. They
+// are large enough for any real torrent while keeping hostile input from
+// exhausting memory or the stack. Rather than changing them, which affects
+// the whole process, set limits per call with Decoder.SetLimits or the
+// methods of Limits.
+var DefaultLimits = Limits{
+	MaxStringLength: 128 << 20,
+	MaxDepth:        512,
+}
+
+// limiter tracks resource usage against Limits while decoding.
+type limiter struct {
+	limits   Limits
+	depth    int
+	elements int64
+}
+
+func (l *limiter) reset() {
+	l.depth = 0
+	l.elements = 0
+}
+
+func (l *limiter) element() error {
+	l.elements++
+	if l.limits.MaxElements > 0 && l.elements > l.limits.MaxElements {
+		return ErrTooManyElements
+	}
+	return nil
+}
+
+func (l *limiter) enter() error {
+	l.depth++
+	if l.limits.MaxDepth > 0 && l.depth > l.limits.MaxDepth {
+		return ErrMaxDepthExceeded
+	}
+	return nil
+}
+
+func (l *limiter) leave() {
+	l.depth--
+}
+
+func (l *limiter) checkString(length int64) error {
+	if l.limits.MaxStringLength > 0 && length > l.limits.MaxStringLength {
+		return ErrStringTooLong
+	}
+	return nil
+}
+
+func (l *limiter) checkBytes(n int64) error {
+	if l.limits.MaxBytes > 0 && n > l.limits.MaxBytes {
+		return ErrInputTooLarge
+	}
+	return nil
+}
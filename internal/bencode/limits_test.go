@@ -0,0 +1,127 @@
+package bencode
+
+import (
+	"bufio"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecoderLimits(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		limits      Limits
+		expectedErr error
+	}{
+		{"string length", "5:hello", Limits{MaxStringLength: 4}, ErrStringTooLong},
+		{"huge declared length", "9999999999:", DefaultLimits, ErrStringTooLong},
+		{"depth", "llllleeeee", Limits{MaxDepth: 4}, ErrMaxDepthExceeded},
+		{"default depth", strings.Repeat("l", 1000) + strings.Repeat("e", 1000), DefaultLimits, ErrMaxDepthExceeded},
+		{"elements", "li1ei2ei3ee", Limits{MaxElements: 3}, ErrTooManyElements},
+		{"dict keys count", "d1:ai1ee", Limits{MaxElements: 2}, ErrTooManyElements},
+		{"bytes", "li1ei2ei3ee", Limits{MaxBytes: 8}, ErrInputTooLarge},
+		{"bytes in string", "l10:0123456789e", Limits{MaxBytes: 8}, ErrInputTooLarge},
+		{"within limits", "d1:ali1ei2eee", Limits{MaxStringLength: 1, MaxDepth: 2, MaxElements: 5, MaxBytes: 13}, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			decoder := NewDecoder(bufio.NewReader(strings.NewReader(test.input)))
+			decoder.SetLimits(test.limits)
+			_, err := decoder.Decode()
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("expected error %v, got %v", test.expectedErr, err)
+			}
+			var syntaxErr *SyntaxError
+			if err != nil && !errors.As(err, &syntaxErr) {
+				t.Errorf("expected *SyntaxError, got %T", err)
+			}
+		})
+	}
+}
+
+func TestDecoderLimitsReset(t *testing.T) {
+	decoder := NewDecoder(bufio.NewReader(strings.NewReader("li1eeli2ee")))
+	decoder.SetLimits(Limits{MaxElements: 2})
+	for i := 0; i < 2; i++ {
+		if _, err := decoder.Decode(); err != nil {
+			t.Fatalf("value %d: %v", i, err)
+		}
+	}
+}
+
+func TestDecoderHostileLength(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedErr error
+	}{
+		{"9999999999:abc", ErrReadValueFailed},
+		{"-1:", ErrInvalidLeadingByte},
+		{strings.Repeat("1", 100) + ":", ErrInvalidLengthFormat},
+		{"i" + strings.Repeat("1", 100) + "e", ErrReadValueFailed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			t.Parallel()
+			decoder := NewDecoder(bufio.NewReader(strings.NewReader(test.input)))
+			decoder.SetLimits(Limits{})
+			var err error
+			if test.input[0] == 'i' {
+				_, err = decoder.DecodeInt()
+			} else {
+				_, err = decoder.DecodeString()
+			}
+			if !errors.Is(err, test.expectedErr) {
+				t.Errorf("expected error %v, got %v", test.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestDecodeLimits(t *testing.T) {
+	deep := []byte(strings.Repeat("l", 1000) + strings.Repeat("e", 1000))
+	if _, err := Decode(deep); !errors.Is(err, ErrMaxDepthExceeded) {
+		t.Errorf("expected error %v, got %v", ErrMaxDepthExceeded, err)
+	}
+	var v interface{}
+	if err := Unmarshal(deep, &v); !errors.Is(err, ErrMaxDepthExceeded) {
+		t.Errorf("expected error %v, got %v", ErrMaxDepthExceeded, err)
+	}
+	var s struct{}
+	if err := Unmarshal([]byte("d1:a"+string(deep)+"e"), &s); !errors.Is(err, ErrMaxDepthExceeded) {
+		t.Errorf("expected error %v, got %v", ErrMaxDepthExceeded, err)
+	}
+}
+
+func TestLimitsMethods(t *testing.T) {
+	input := []byte("d1:ali1ei2eee")
+	tests := []struct {
+		name        string
+		limits      Limits
+		expectedErr error
+	}{
+		{"within limits", Limits{MaxStringLength: 1, MaxDepth: 2, MaxElements: 5, MaxBytes: 13}, nil},
+		{"bytes", Limits{MaxBytes: 12}, ErrInputTooLarge},
+		{"depth", Limits{MaxDepth: 1}, ErrMaxDepthExceeded},
+		{"elements", Limits{MaxElements: 4}, ErrTooManyElements},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := test.limits.Decode(input); !errors.Is(err, test.expectedErr) {
+				t.Errorf("Decode: expected error %v, got %v", test.expectedErr, err)
+			}
+			var v interface{}
+			if err := test.limits.Unmarshal(input, &v); !errors.Is(err, test.expectedErr) {
+				t.Errorf("Unmarshal: expected error %v, got %v", test.expectedErr, err)
+			}
+			if err := test.limits.Validate(input); !errors.Is(err, test.expectedErr) {
+				t.Errorf("Validate: expected error %v, got %v", test.expectedErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package bencode
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Marshaler is implemented by types that can encode themselves into valid
+// bencode.
+type Marshaler interface {
+	MarshalBencode() ([]byte, error)
+}
+
+var marshalerType = reflect.TypeFor[Marshaler]()
+
+// Marshal returns the bencoding of v.
+//
+// Values implementing Marshaler, directly or through a pointer when
+// addressable, are encoded by calling MarshalBencode, and its output is checked
+// to be a single valid value.
+//
+// Strings, byte slices and byte arrays encode as byte strings. Integers of any
+// width encode as integers, and bools encode as i1e or i0e. Slices and arrays
+// encode as lists. Maps with string keys and structs encode as dictionaries
+// with sorted keys.
+//
+// Struct fields are named by the "bencode" struct tag, falling back to the
+// field name. The "omitempty" option omits zero values, and a tag of "-"
+// ignores the field. Bencode has no null, so nil pointer and interface fields
+// are always omitted.
+func Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (e *Encoder) encodeValue(v reflect.Value) error {
+	if !v.IsValid() {
+		return ErrInvalidType
+	}
+	if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+		return fmt.Errorf("%w: nil %s", ErrInvalidType, v.Type())
+	}
+	if v.Type().Implements(marshalerType) {
+		return e.encodeMarshaler(v.Interface().(Marshaler))
+	}
+	if v.Kind() != reflect.Pointer && v.CanAddr() && reflect.PointerTo(v.Type()).Implements(marshalerType) {
+		return e.encodeMarshaler(v.Addr().Interface().(Marshaler))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return e.EncodeString(v.String())
+	case reflect.Bool:
+		if v.Bool() {
+			return e.EncodeInt(1)
+		}
+		return e.EncodeInt(0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return e.EncodeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		_, err := fmt.Fprintf(e.w, "i%de", v.Uint())
+		return err
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return e.encodeBytes(v.Bytes())
+		}
+		return e.encodeList(v)
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			return e.encodeBytes(b)
+		}
+		return e.encodeList(v)
+	case reflect.Map:
+		return e.encodeMap(v)
+	case reflect.Struct:
+		return e.encodeStruct(v)
+	case reflect.Pointer, reflect.Interface:
+		return e.encodeValue(v.Elem())
+	default:
+		return fmt.Errorf("%w: %s", ErrInvalidType, v.Type())
+	}
+}
+
+func (e *Encoder) encodeMarshaler(m Marshaler) error {
+	b, err := m.MarshalBencode()
+	if err != nil {
+		return err
+	}
+	if err := Validate(b); err != nil {
+		return fmt.Errorf("%w: %T: %w", ErrInvalidMarshalerOutput, m, err)
+	}
+	_, err = e.w.Write(b)
+	return err
+}
+
+func (e *Encoder) encodeList(v reflect.Value) error {
+	_, err := e.w.Write([]byte{'l'})
+	if err != nil {
+		return err
+	}
+	for i := 0; i < v.Len(); i++ {
+		if err := e.encodeValue(v.Index(i)); err != nil {
+			return err
+		}
+	}
+	_, err = e.w.Write([]byte{'e'})
+	return err
+}
+
+func (e *Encoder) encodeMap(v reflect.Value) error {
+	if v.Type().Key().Kind() != reflect.String {
+		return fmt.Errorf("%w: %s", ErrInvalidType, v.Type())
+	}
+	_, err := e.w.Write([]byte{'d'})
+	if err != nil {
+		return err
+	}
+
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+
+	for _, key := range keys {
+		if err := e.EncodeString(key.String()); err != nil {
+			return err
+		}
+		if err := e.encodeValue(v.MapIndex(key)); err != nil {
+			return err
+		}
+	}
+	_, err = e.w.Write([]byte{'e'})
+	return err
+}
+
+func (e *Encoder) encodeStruct(v reflect.Value) error {
+	_, err := e.w.Write([]byte{'d'})
+	if err != nil {
+		return err
+	}
+	for _, f := range cachedFields(v.Type()).list {
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok {
+			continue
+		}
+		if (fv.Kind() == reflect.Pointer || fv.Kind() == reflect.Interface) && fv.IsNil() {
+			continue
+		}
+		if f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		if err := e.EncodeString(f.name); err != nil {
+			return err
+		}
+		if err := e.encodeValue(fv); err != nil {
+			return err
+		}
+	}
+	_, err = e.w.Write([]byte{'e'})
+	return err
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex but reports false instead of
+// panicking when it has to step through a nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+type field struct {
+	name      string
+	index     []int
+	tagged    bool
+	omitEmpty bool
+}
+
+type structFields struct {
+	list   []field
+	byName map[string]*field
+}
+
+var fieldCache sync.Map // map[reflect.Type]structFields
+
+// cachedFields returns the bencoded fields of struct type t sorted by key.
+func cachedFields(t reflect.Type) structFields {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.(structFields)
+	}
+	f, _ := fieldCache.LoadOrStore(t, typeFields(t))
+	return f.(structFields)
+}
+
+func typeFields(t reflect.Type) structFields {
+	var fields []field
+	collectFields(t, nil, &fields)
+
+	// Sort by name, then by depth and tag so that the dominant field for
+	// each name comes first, mirroring encoding/json.
+	sort.SliceStable(fields, func(i, j int) bool {
+		if fields[i].name != fields[j].name {
+			return fields[i].name < fields[j].name
+		}
+		if len(fields[i].index) != len(fields[j].index) {
+			return len(fields[i].index) < len(fields[j].index)
+		}
+		return fields[i].tagged && !fields[j].tagged
+	})
+
+	out := fields[:0]
+	for i := 0; i < len(fields); {
+		j := i + 1
+		for j < len(fields) && fields[j].name == fields[i].name {
+			j++
+		}
+		dominant := fields[i]
+		if j-i == 1 || len(fields[i+1].index) > len(dominant.index) || (dominant.tagged && !fields[i+1].tagged) {
+			out = append(out, dominant)
+		}
+		i = j
+	}
+
+	byName := make(map[string]*field, len(out))
+	for i := range out {
+		byName[out[i].name] = &out[i]
+	}
+	return structFields{list: out, byName: byName}
+}
+
+func collectFields(t reflect.Type, index []int, fields *[]field) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("bencode")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		idx := make([]int, len(index)+1)
+		copy(idx, index)
+		idx[len(index)] = i
+
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				collectFields(ft, idx, fields)
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		f := field{name: name, index: idx, tagged: name != ""}
+		if f.name == "" {
+			f.name = sf.Name
+		}
+		for opts != "" {
+			var opt string
+			opt, opts, _ = strings.Cut(opts, ",")
+			if opt == "omitempty" {
+				f.omitEmpty = true
+			}
+		}
+		*fields = append(*fields, f)
+	}
+}
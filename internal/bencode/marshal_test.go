@@ -0,0 +1,146 @@
+package bencode
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type marshalInner struct {
+	Path []string `bencode:"path"`
+	Size uint32   `bencode:"length"`
+}
+
+type marshalEmbedded struct {
+	Source string `bencode:"source,omitempty"`
+}
+
+type marshalOuter struct {
+	marshalEmbedded
+	Name        string         `bencode:"name"`
+	PieceLength int64          `bencode:"piece length"`
+	Private     bool           `bencode:"private,omitempty"`
+	Hash        [4]byte        `bencode:"hash"`
+	Raw         []byte         `bencode:"raw,omitempty"`
+	Files       []marshalInner `bencode:"files,omitempty"`
+	Comment     *string        `bencode:"comment"`
+	Extra       map[string]int `bencode:"extra,omitempty"`
+	Ignored     string         `bencode:"-"`
+	Untagged    int8
+	unexported  int
+}
+
+func TestMarshal(t *testing.T) {
+	comment := "hi"
+	tests := []struct {
+		name        string
+		input       interface{}
+		expected    string
+		expectedErr error
+	}{
+		{"string", "spam", "4:spam", nil},
+		{"bytes", []byte("spam"), "4:spam", nil},
+		{"int", 42, "i42e", nil},
+		{"uint64", uint64(1 << 63), "i9223372036854775808e", nil},
+		{"int8", int8(-3), "i-3e", nil},
+		{"bool", true, "i1e", nil},
+		{"slice", []int{1, 2}, "li1ei2ee", nil},
+		{"array", [2]string{"a", "b"}, "l1:a1:be", nil},
+		{"map", map[string]int{"b": 2, "a": 1}, "d1:ai1e1:bi2ee", nil},
+		{"pointer", &comment, "2:hi", nil},
+		{
+			"struct",
+			marshalOuter{
+				Name:        "x",
+				PieceLength: 16,
+				Hash:        [4]byte{'a', 'b', 'c', 'd'},
+				Ignored:     "nope",
+				Untagged:    7,
+				unexported:  1,
+			},
+			"d8:Untaggedi7e4:hash4:abcd4:name1:x12:piece lengthi16ee",
+			nil,
+		},
+		{
+			"struct with optional fields",
+			marshalOuter{
+				marshalEmbedded: marshalEmbedded{Source: "src"},
+				Name:            "x",
+				Private:         true,
+				Files:           []marshalInner{{Path: []string{"a", "b"}, Size: 3}},
+				Comment:         &comment,
+				Extra:           map[string]int{"k": 1},
+			},
+			"d8:Untaggedi0e7:comment2:hi5:extrad1:ki1ee5:filesld6:lengthi3e4:pathl1:a1:beee4:hash4:\x00\x00\x00\x004:name1:x12:piece lengthi0e7:privatei1e6:source3:srce",
+			nil,
+		},
+		{"float", 1.5, "", ErrInvalidType},
+		{"int map", map[int]string{1: "a"}, "", ErrInvalidType},
+		{"nil pointer", (*string)(nil), "", ErrInvalidType},
+		{"nil in list", []interface{}{nil}, "l", ErrInvalidType},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := Marshal(test.input)
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("expected error %v, got %v for input %v", test.expectedErr, err, test.input)
+			}
+			if err == nil && string(result) != test.expected {
+				t.Errorf("expected %q, got %q for input %v", test.expected, result, test.input)
+			}
+		})
+	}
+}
+
+type upperString string
+
+func (s upperString) MarshalBencode() ([]byte, error) {
+	return Marshal(strings.ToUpper(string(s)))
+}
+
+func (s *upperString) UnmarshalBencode(data []byte) error {
+	var v string
+	if err := Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*s = upperString(strings.ToLower(v))
+	return nil
+}
+
+type badMarshaler struct{}
+
+func (badMarshaler) MarshalBencode() ([]byte, error) {
+	return []byte("i1"), nil
+}
+
+func TestMarshaler(t *testing.T) {
+	v := struct {
+		Name upperString   `bencode:"name"`
+		List []upperString `bencode:"list"`
+	}{"spam", []upperString{"a", "b"}}
+
+	data, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "d4:listl1:A1:Be4:name4:SPAMe"; string(data) != expected {
+		t.Errorf("expected %q, got %q", expected, data)
+	}
+
+	var out struct {
+		Name upperString   `bencode:"name"`
+		List []upperString `bencode:"list"`
+	}
+	if err := Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != "spam" || len(out.List) != 2 || out.List[1] != "b" {
+		t.Errorf("expected round trip, got %+v", out)
+	}
+
+	if _, err := Marshal(badMarshaler{}); !errors.Is(err, ErrInvalidMarshalerOutput) {
+		t.Errorf("expected error %v, got %v", ErrInvalidMarshalerOutput, err)
+	}
+}
@@ -0,0 +1,88 @@
+package bencode
+
+import (
+	"bufio"
+	"encoding/hex"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxPrettyBytes is the number of bytes of a binary string shown by
+// PrettyPrint before it is cut short.
+const maxPrettyBytes = 32
+
+// PrettyPrint writes a human-readable, indented rendering of a single bencoded
+// value to w. Dictionaries are printed one key per line in sorted order and
+// lists one item per line. Byte strings that are valid UTF-8 are printed
+// quoted, and other byte strings are printed in hex between angle brackets,
+// with binary strings longer than 32 bytes, such as pieces, shortened to their
+// first 32 bytes and their length.
+func PrettyPrint(w io.Writer, data []byte) error {
+	v, err := Decode(data)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	prettyValue(bw, v, 0)
+	bw.WriteByte('\n')
+	return bw.Flush()
+}
+
+func prettyValue(w *bufio.Writer, v interface{}, depth int) {
+	switch v := v.(type) {
+	case int64:
+		w.WriteString(strconv.FormatInt(v, 10))
+	case []byte:
+		w.WriteString(prettyString(v))
+	case []interface{}:
+		if len(v) == 0 {
+			w.WriteString("[]")
+			return
+		}
+		w.WriteString("[\n")
+		for _, item := range v {
+			prettyIndent(w, depth+1)
+			prettyValue(w, item, depth+1)
+			w.WriteByte('\n')
+		}
+		prettyIndent(w, depth)
+		w.WriteByte(']')
+	case map[string]interface{}:
+		if len(v) == 0 {
+			w.WriteString("{}")
+			return
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		w.WriteString("{\n")
+		for _, key := range keys {
+			prettyIndent(w, depth+1)
+			w.WriteString(prettyString([]byte(key)))
+			w.WriteString(": ")
+			prettyValue(w, v[key], depth+1)
+			w.WriteByte('\n')
+		}
+		prettyIndent(w, depth)
+		w.WriteByte('}')
+	}
+}
+
+func prettyString(b []byte) string {
+	if utf8.Valid(b) {
+		return strconv.Quote(string(b))
+	}
+	if len(b) <= maxPrettyBytes {
+		return "<" + hex.EncodeToString(b) + ">"
+	}
+	return "<" + hex.EncodeToString(b[:maxPrettyBytes]) + "... " + strconv.Itoa(len(b)) + " bytes>"
+}
+
+func prettyIndent(w *bufio.Writer, depth int) {
+	w.WriteString(strings.Repeat("  ", depth))
+}
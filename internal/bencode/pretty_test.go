@@ -0,0 +1,43 @@
+package bencode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPrettyPrint(t *testing.T) {
+	pieces := strings.Repeat("\xab", 40)
+	input := "d8:announce14:http://tracker4:infod5:filesld6:lengthi1e4:pathl1:aeee4:name1:x6:pieces40:" + pieces + "e4:listle4:dictde2:id2:\xff\x00e"
+	expected := `{
+  "announce": "http://tracker"
+  "dict": {}
+  "id": <ff00>
+  "info": {
+    "files": [
+      {
+        "length": 1
+        "path": [
+          "a"
+        ]
+      }
+    ]
+    "name": "x"
+    "pieces": <` + strings.Repeat("ab", 32) + `... 40 bytes>
+  }
+  "list": []
+}
+`
+
+	var buf bytes.Buffer
+	if err := PrettyPrint(&buf, []byte(input)); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+
+	if err := PrettyPrint(&buf, []byte("d")); err == nil {
+		t.Error("expected an error for invalid input")
+	}
+}
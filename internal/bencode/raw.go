@@ -0,0 +1,41 @@
+package bencode
+
+import (
+	"errors"
+)
+
+var ErrEmptyRawMessage = errors.New("empty raw message")
+
+// RawMessage is a raw encoded bencode value. Unmarshal stores the exact bytes
+// of the value in it, which is useful for hashing a value as it appeared in
+// the input, and Marshal writes it out verbatim.
+type RawMessage []byte
+
+// MarshalBencode returns m as the bencoding of m.
+func (m RawMessage) MarshalBencode() ([]byte, error) {
+	if len(m) == 0 {
+		return nil, ErrEmptyRawMessage
+	}
+	return m, nil
+}
+
+// UnmarshalBencode sets *m to a copy of data.
+func (m *RawMessage) UnmarshalBencode(data []byte) error {
+	*m = append((*m)[:0], data...)
+	return nil
+}
+
+// RawValue is a decoded bencode value together with its encoding. Unmarshal
+// fills in both from a single pass over the input: Value as decoding into an
+// empty interface would, and Raw as the exact bytes of the value. Raw is a
+// sub-slice of the data passed to Unmarshal rather than a copy, so it must be
+// copied if it is kept after the data is modified.
+type RawValue struct {
+	Value interface{}
+	Raw   RawMessage
+}
+
+// MarshalBencode returns v.Raw, like RawMessage.
+func (v RawValue) MarshalBencode() ([]byte, error) {
+	return v.Raw.MarshalBencode()
+}
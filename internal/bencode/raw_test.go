@@ -0,0 +1,88 @@
+package bencode
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRawMessage(t *testing.T) {
+	// The info dictionary is deliberately non-canonical: its keys are out of
+	// order and its integer has a leading plus sign.
+	input := "d4:infod4:name1:x6:lengthi+5ee3:foo3:bare"
+	var v struct {
+		Info RawMessage `bencode:"info"`
+		Foo  string     `bencode:"foo"`
+	}
+	if err := Unmarshal([]byte(input), &v); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "d4:name1:x6:lengthi+5ee"; string(v.Info) != expected {
+		t.Errorf("expected %q, got %q", expected, v.Info)
+	}
+	if v.Foo != "bar" {
+		t.Errorf("expected %q, got %q", "bar", v.Foo)
+	}
+
+	output, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "d3:foo3:bar4:infod4:name1:x6:lengthi+5eee"; string(output) != expected {
+		t.Errorf("expected %q, got %q", expected, output)
+	}
+}
+
+func TestRawMessageEmpty(t *testing.T) {
+	if _, err := Marshal(RawMessage(nil)); err != ErrEmptyRawMessage {
+		t.Errorf("expected error %v, got %v", ErrEmptyRawMessage, err)
+	}
+	var v struct {
+		Info RawMessage `bencode:"info,omitempty"`
+	}
+	output, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(output) != "de" {
+		t.Errorf("expected %q, got %q", "de", output)
+	}
+}
+
+func TestRawMessageErrorPath(t *testing.T) {
+	var v map[string]RawMessage
+	err := Unmarshal([]byte("d4:infod5:filesld4:pathl1:ai1xeeeeee"), &v)
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("expected a SyntaxError, got %v", err)
+	}
+	if expected := "info.files[0].path[1]"; syntaxErr.Path != expected {
+		t.Errorf("expected path %q, got %q", expected, syntaxErr.Path)
+	}
+}
+
+func TestRawValue(t *testing.T) {
+	input := "d4:infod4:name1:x6:lengthi+5ee3:foo3:bare"
+	var v map[string]RawValue
+	if err := Unmarshal([]byte(input), &v); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "d4:name1:x6:lengthi+5ee"; string(v["info"].Raw) != expected {
+		t.Errorf("expected %q, got %q", expected, v["info"].Raw)
+	}
+	expected := map[string]interface{}{"name": "x", "length": int64(5)}
+	if !reflect.DeepEqual(v["info"].Value, expected) {
+		t.Errorf("expected %v, got %v", expected, v["info"].Value)
+	}
+	if v["foo"].Value != "bar" || string(v["foo"].Raw) != "3:bar" {
+		t.Errorf("expected %q encoded as %q, got %v", "bar", "3:bar", v["foo"])
+	}
+
+	output, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "d3:foo3:bar4:infod4:name1:x6:lengthi+5eee"; string(output) != expected {
+		t.Errorf("expected %q, got %q", expected, output)
+	}
+}
@@ -0,0 +1,402 @@
+package bencode
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// TokenKind identifies the kind of a Token.
+type TokenKind uint8
+
+const (
+	TokenDictStart TokenKind = iota + 1
+	TokenListStart
+	TokenEnd
+	TokenInt
+	TokenString
+)
+
+func (k TokenKind) String() string {
+	switch k {
+	case TokenDictStart:
+		return "DictStart"
+	case TokenListStart:
+		return "ListStart"
+	case TokenEnd:
+		return "End"
+	case TokenInt:
+		return "Int"
+	case TokenString:
+		return "String"
+	default:
+		return "TokenKind(" + strconv.Itoa(int(k)) + ")"
+	}
+}
+
+// Token is a single element of the bencode stream. Dictionary keys are
+// returned as String tokens, alternating with their values.
+type Token struct {
+	Kind   TokenKind
+	Int    int64  // value of an Int token
+	String string // value of a String token
+}
+
+// frame is an open list or dictionary.
+type frame struct {
+	dict bool
+	n    int    // items read so far; in a dictionary keys and values both count
+	key  string // last key read in a dictionary
+}
+
+// maxTokenLength bounds the digits read for an integer or string length, so
+// that a missing delimiter cannot make the decoder buffer the whole input.
+const maxTokenLength = 64
+
+// maxPrealloc bounds the buffer allocated up front for a string.
+const maxPrealloc = 1 << 20
+
+var errTokenTooLong = errors.New("token too long")
+
+// Token returns the next token in the input. It checks that lists and
+// dictionaries are well formed, and applies the decoder's limits and strict
+// mode, but keeps no more than the current key path in memory. At the end of
+// the input it returns an error wrapping io.EOF.
+func (d *Decoder) Token() (Token, error) {
+	return d.token(false)
+}
+
+// More reports whether there is another item in the current list or
+// dictionary.
+func (d *Decoder) More() bool {
+	if len(d.stack) == 0 {
+		return false
+	}
+	next, err := d.r.Peek(1)
+	return err == nil && next[0] != 'e'
+}
+
+// Skip reads and discards the next value, including everything inside it if it
+// is a list or dictionary. Byte strings are discarded without being buffered.
+func (d *Decoder) Skip() error {
+	if top := d.top(); top != nil {
+		if next, err := d.r.Peek(1); err == nil && next[0] == 'e' {
+			return d.error(d.off, ErrInvalidLeadingByte)
+		}
+	}
+	depth := len(d.stack)
+	for {
+		if _, err := d.token(true); err != nil {
+			return err
+		}
+		if len(d.stack) == depth {
+			return nil
+		}
+	}
+}
+
+// NonCanonical reads the next value like Skip and returns a *SyntaxError for
+// every place where it is not in canonical form, each wrapping
+// ErrNonCanonicalInt, ErrNonCanonicalLength, ErrUnsortedKeys or
+// ErrDuplicateKey. Unlike strict mode it keeps going after each finding; err is
+// set only if the value is malformed or exceeds a limit.
+func (d *Decoder) NonCanonical() (found []error, err error) {
+	d.collect, d.found = true, nil
+	defer func() { d.collect, d.found = false, nil }()
+	if err := d.Skip(); err != nil {
+		return d.found, err
+	}
+	return d.found, nil
+}
+
+func (d *Decoder) top() *frame {
+	if len(d.stack) == 0 {
+		return nil
+	}
+	return &d.stack[len(d.stack)-1]
+}
+
+// token reads the next token. When discard is set, byte string values are
+// skipped over rather than read into memory.
+func (d *Decoder) token(discard bool) (Token, error) {
+	if len(d.stack) == 0 {
+		d.lim.elements = 0
+	}
+
+	top := d.top()
+	if top != nil {
+		next, err := d.r.Peek(1)
+		if err != nil {
+			if top.dict && top.n%2 == 1 {
+				return Token{}, d.readFailed(ErrInvalidLeadingByte, err)
+			}
+			return Token{}, d.unterminated(err)
+		}
+		if next[0] == 'e' {
+			if top.dict && top.n%2 == 1 {
+				return Token{}, d.error(d.off, ErrInvalidLeadingByte)
+			}
+			if _, err := d.readByte(); err != nil {
+				return Token{}, d.unterminated(err)
+			}
+			d.stack = d.stack[:len(d.stack)-1]
+			d.lim.leave()
+			d.finish()
+			return Token{Kind: TokenEnd}, nil
+		}
+		if top.dict && top.n%2 == 0 {
+			return d.key(top)
+		}
+	}
+
+	leading, err := d.r.Peek(1)
+	if err != nil {
+		if err == io.EOF && top == nil {
+			return Token{}, d.error(d.off, wrapCause(ErrInvalidLeadingByte, err))
+		}
+		return Token{}, d.readFailed(ErrInvalidLeadingByte, err)
+	}
+	switch leading[0] {
+	case 'i':
+		value, err := d.readInt()
+		if err != nil {
+			return Token{}, err
+		}
+		d.finish()
+		return Token{Kind: TokenInt, Int: value}, nil
+	case 'l':
+		if err := d.open(false); err != nil {
+			return Token{}, err
+		}
+		return Token{Kind: TokenListStart}, nil
+	case 'd':
+		if err := d.open(true); err != nil {
+			return Token{}, err
+		}
+		return Token{Kind: TokenDictStart}, nil
+	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		value, err := d.readString(discard)
+		if err != nil {
+			return Token{}, err
+		}
+		d.finish()
+		return Token{Kind: TokenString, String: value}, nil
+	default:
+		return Token{}, d.error(d.off, ErrInvalidLeadingByte)
+	}
+}
+
+// key reads a dictionary key, checking its order in strict mode.
+func (d *Decoder) key(top *frame) (Token, error) {
+	start := d.off
+	key, err := d.readString(false)
+	if err != nil {
+		return Token{}, err
+	}
+	prevKey := top.key
+	top.key = key
+	top.n++
+	if top.n > 1 {
+		if key == prevKey {
+			if err := d.nonCanonical(start, ErrDuplicateKey); err != nil {
+				return Token{}, err
+			}
+		} else if key < prevKey {
+			if err := d.nonCanonical(start, ErrUnsortedKeys); err != nil {
+				return Token{}, err
+			}
+		}
+	}
+	return Token{Kind: TokenString, String: key}, nil
+}
+
+// finish records that a value in the current list or dictionary is complete.
+func (d *Decoder) finish() {
+	if top := d.top(); top != nil {
+		top.n++
+	}
+}
+
+// open consumes the leading byte of a list or dictionary.
+func (d *Decoder) open(dict bool) error {
+	if err := d.element(); err != nil {
+		return err
+	}
+	start := d.off
+	if _, err := d.readByte(); err != nil {
+		return d.readFailed(ErrReadLeadingFailed, err)
+	}
+	if err := d.lim.enter(); err != nil {
+		return d.error(start, err)
+	}
+	d.stack = append(d.stack, frame{dict: dict})
+	return nil
+}
+
+// element counts a value against the limits.
+func (d *Decoder) element() error {
+	if err := d.lim.element(); err != nil {
+		return d.error(d.off, err)
+	}
+	return nil
+}
+
+func (d *Decoder) readString(discard bool) (string, error) {
+	if err := d.element(); err != nil {
+		return "", err
+	}
+	start := d.off
+	lenStr, err := d.readToken(':')
+	if err == errTokenTooLong {
+		return "", d.error(start, ErrInvalidLengthFormat)
+	}
+	if err != nil {
+		return "", d.readFailed(ErrReadLengthFailed, err)
+	}
+	length, err := strconv.ParseInt(lenStr, 10, 64)
+	if err != nil || length < 0 {
+		return "", d.error(start, ErrInvalidLengthFormat)
+	}
+	if !isCanonicalUint(lenStr) {
+		if err := d.nonCanonical(start, ErrNonCanonicalLength); err != nil {
+			return "", err
+		}
+	}
+	if err := d.lim.checkString(length); err != nil {
+		return "", d.error(start, err)
+	}
+	if err := d.lim.checkBytes(d.off + length); err != nil {
+		return "", d.error(d.off, err)
+	}
+
+	if discard {
+		n, err := d.r.Discard(int(length))
+		d.off += int64(n)
+		if err != nil {
+			return "", d.readFailed(ErrReadValueFailed, err)
+		}
+		return "", nil
+	}
+
+	// Only trust the declared length up to maxPrealloc and grow the buffer as
+	// data arrives beyond that, so that a short input cannot force a huge
+	// allocation.
+	var value strings.Builder
+	value.Grow(int(min(length, maxPrealloc)))
+	n, err := io.CopyN(&value, d.r, length)
+	d.off += n
+	if err != nil {
+		return "", d.readFailed(ErrReadValueFailed, err)
+	}
+	return value.String(), nil
+}
+
+func (d *Decoder) readInt() (int64, error) {
+	if err := d.element(); err != nil {
+		return 0, err
+	}
+	if _, err := d.readByte(); err != nil {
+		return 0, d.readFailed(ErrReadLeadingFailed, err)
+	}
+	start := d.off
+	valueString, err := d.readToken('e')
+	if err == errTokenTooLong {
+		return 0, d.error(start, ErrReadValueFailed)
+	}
+	if err != nil {
+		return 0, d.readFailed(ErrInvalidEndingByte, err)
+	}
+	value, err := strconv.ParseInt(valueString, 10, 64)
+	if err != nil {
+		return 0, d.error(start, ErrReadValueFailed)
+	}
+	if !isCanonicalInt(valueString) {
+		if err := d.nonCanonical(start, ErrNonCanonicalInt); err != nil {
+			return 0, err
+		}
+	}
+	return value, nil
+}
+
+// nonCanonical handles a non-canonical encoding found at offset: it is an
+// error in strict mode and a finding while NonCanonical is collecting.
+func (d *Decoder) nonCanonical(offset int64, err error) error {
+	if d.strict {
+		return d.error(offset, err)
+	}
+	if d.collect {
+		d.found = append(d.found, d.error(offset, err))
+	}
+	return nil
+}
+
+func (d *Decoder) readByte() (byte, error) {
+	if err := d.lim.checkBytes(d.off + 1); err != nil {
+		return 0, err
+	}
+	b, err := d.r.ReadByte()
+	if err == nil {
+		d.off++
+	}
+	return b, err
+}
+
+// readToken reads up to and including delim and returns the bytes before it.
+func (d *Decoder) readToken(delim byte) (string, error) {
+	var buf [maxTokenLength]byte
+	for n := 0; n < len(buf); n++ {
+		b, err := d.readByte()
+		if err != nil {
+			return "", err
+		}
+		if b == delim {
+			return string(buf[:n]), nil
+		}
+		buf[n] = b
+	}
+	return "", errTokenTooLong
+}
+
+func (d *Decoder) error(offset int64, err error) error {
+	return d.errorIn(d.stack, offset, err)
+}
+
+// unterminated reports a list or dictionary that could not be ended at the
+// path of the list or dictionary itself.
+func (d *Decoder) unterminated(cause error) error {
+	if cause == ErrInputTooLarge {
+		return d.errorIn(d.stack[:len(d.stack)-1], d.off, cause)
+	}
+	if cause == io.EOF {
+		cause = io.ErrUnexpectedEOF
+	}
+	return d.errorIn(d.stack[:len(d.stack)-1], d.off, wrapCause(ErrInvalidEndingByte, cause))
+}
+
+// errorIn returns a *SyntaxError at the path of the current item of each frame
+// in stack.
+func (d *Decoder) errorIn(stack []frame, offset int64, err error) error {
+	var p path
+	for _, f := range stack {
+		switch {
+		case !f.dict:
+			p.pushIndex(f.n)
+		case f.n%2 == 1:
+			p.pushKey(f.key)
+		}
+	}
+	return &SyntaxError{Offset: offset, Path: p.String(), Err: err}
+}
+
+// readFailed reports a failed read as sentinel wrapping the underlying cause,
+// unless the read was stopped by a limit.
+func (d *Decoder) readFailed(sentinel, cause error) error {
+	if cause == ErrInputTooLarge {
+		return d.error(d.off, cause)
+	}
+	if cause == io.EOF {
+		cause = io.ErrUnexpectedEOF
+	}
+	return d.error(d.off, wrapCause(sentinel, cause))
+}
@@ -0,0 +1,144 @@
+package bencode
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestToken(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []Token
+	}{
+		{"i42e", []Token{{Kind: TokenInt, Int: 42}}},
+		{"4:spam", []Token{{Kind: TokenString, String: "spam"}}},
+		{"le", []Token{{Kind: TokenListStart}, {Kind: TokenEnd}}},
+		{
+			"d3:bar4:spam3:fooli1edeee",
+			[]Token{
+				{Kind: TokenDictStart},
+				{Kind: TokenString, String: "bar"},
+				{Kind: TokenString, String: "spam"},
+				{Kind: TokenString, String: "foo"},
+				{Kind: TokenListStart},
+				{Kind: TokenInt, Int: 1},
+				{Kind: TokenDictStart},
+				{Kind: TokenEnd},
+				{Kind: TokenEnd},
+				{Kind: TokenEnd},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			t.Parallel()
+			decoder := NewDecoder(bufio.NewReader(strings.NewReader(test.input)))
+			var result []Token
+			for {
+				tok, err := decoder.Token()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				result = append(result, tok)
+			}
+			if !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("expected %v, got %v for input %q", test.expected, result, test.input)
+			}
+		})
+	}
+}
+
+func TestTokenErrors(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedErr error
+	}{
+		{"l", ErrInvalidEndingByte},
+		{"d1:ae", ErrInvalidLeadingByte},     // Error case: key without value
+		{"di1e1:ae", ErrInvalidLengthFormat}, // Error case: non-string key
+		{"li1ex", ErrInvalidLeadingByte},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			t.Parallel()
+			decoder := NewDecoder(bufio.NewReader(strings.NewReader(test.input)))
+			var err error
+			for err == nil {
+				_, err = decoder.Token()
+			}
+			if !errors.Is(err, test.expectedErr) {
+				t.Errorf("expected error %v, got %v for input %q", test.expectedErr, err, test.input)
+			}
+		})
+	}
+}
+
+func TestSkip(t *testing.T) {
+	pieces := strings.Repeat("x", 1<<20)
+	input := "d8:announce3:url4:infod6:pieces1048576:" + pieces + "e3:zzzi7ee"
+	decoder := NewDecoder(bufio.NewReader(strings.NewReader(input)))
+
+	// Pick out the announce and zzz keys without decoding info.
+	if tok, err := decoder.Token(); err != nil || tok.Kind != TokenDictStart {
+		t.Fatalf("expected DictStart, got %v, %v", tok, err)
+	}
+	found := map[string]interface{}{}
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key.String == "info" {
+			if err := decoder.Skip(); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		value, err := decoder.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		found[key.String] = value
+	}
+	if tok, err := decoder.Token(); err != nil || tok.Kind != TokenEnd {
+		t.Fatalf("expected End, got %v, %v", tok, err)
+	}
+
+	expected := map[string]interface{}{"announce": "url", "zzz": int64(7)}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected %v, got %v", expected, found)
+	}
+	if decoder.InputOffset() != int64(len(input)) {
+		t.Errorf("expected offset %d, got %d", len(input), decoder.InputOffset())
+	}
+}
+
+func TestSkipAtEnd(t *testing.T) {
+	decoder := NewDecoder(bufio.NewReader(strings.NewReader("le")))
+	if _, err := decoder.Token(); err != nil {
+		t.Fatal(err)
+	}
+	if err := decoder.Skip(); !errors.Is(err, ErrInvalidLeadingByte) {
+		t.Errorf("expected error %v, got %v", ErrInvalidLeadingByte, err)
+	}
+	if tok, err := decoder.Token(); err != nil || tok.Kind != TokenEnd {
+		t.Errorf("expected End, got %v, %v", tok, err)
+	}
+}
+
+func TestSkipStrict(t *testing.T) {
+	decoder := NewDecoder(bufio.NewReader(strings.NewReader("d1:bi1e1:ai2ee")))
+	decoder.DisallowNonCanonical()
+	if err := decoder.Skip(); !errors.Is(err, ErrUnsortedKeys) {
+		t.Errorf("expected error %v, got %v", ErrUnsortedKeys, err)
+	}
+}
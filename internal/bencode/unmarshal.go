@@ -0,0 +1,365 @@
+package bencode
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var (
+	ErrInvalidUnmarshal = errors.New("non-nil pointer required")
+	ErrTrailingData     = errors.New("trailing data after value")
+	ErrUnmarshalType    = errors.New("cannot unmarshal value into type")
+)
+
+// Unmarshaler is implemented by types that can decode a bencoded
+// representation of themselves. The data passed to UnmarshalBencode is a
+// single valid value, and must be copied if it is kept after returning.
+type Unmarshaler interface {
+	UnmarshalBencode(data []byte) error
+}
+
+var (
+	unmarshalerType = reflect.TypeFor[Unmarshaler]()
+	rawValueType    = reflect.TypeFor[RawValue]()
+)
+
+// Unmarshal decodes the bencoded data and stores the result in the value
+// pointed to by v, following the mapping described on Marshal.
+//
+// Integers decode into any integer kind, failing if the value overflows, and
+// into bools where any non-zero value is true. Dictionaries decode into
+// structs or string-keyed maps, and unknown keys are ignored. Decoding into an
+// empty interface stores the same values the Decoder returns. Values whose
+// pointer implements Unmarshaler, such as RawMessage, are passed the encoded
+// bytes of their value, and a RawValue gets both the value and its bytes.
+func Unmarshal(data []byte, v interface{}) error {
+	return DefaultLimits.Unmarshal(data, v)
+}
+
+// Unmarshal is like the package-level Unmarshal, but applies l instead of
+// DefaultLimits.
+func (l Limits) Unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return ErrInvalidUnmarshal
+	}
+
+	d := newDecodeState(data, l)
+	d.strings = true
+	if err := d.lim.checkBytes(int64(len(data))); err != nil {
+		return d.error(int(d.lim.limits.MaxBytes), err)
+	}
+	if err := d.unmarshal(rv.Elem()); err != nil {
+		return err
+	}
+	if d.off != len(d.data) {
+		return d.error(d.off, ErrTrailingData)
+	}
+	return nil
+}
+
+func (d *decodeState) unmarshal(v reflect.Value) error {
+	if v.Type() == rawValueType {
+		start := d.off
+		value, err := d.value()
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(RawValue{Value: value, Raw: d.data[start:d.off:d.off]}))
+		return nil
+	}
+	if v.Kind() != reflect.Pointer && v.CanAddr() && reflect.PointerTo(v.Type()).Implements(unmarshalerType) {
+		start := d.off
+		if err := d.skip(); err != nil {
+			return err
+		}
+		u := v.Addr().Interface().(Unmarshaler)
+		if err := u.UnmarshalBencode(d.data[start:d.off:d.off]); err != nil {
+			return d.error(start, err)
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.unmarshal(v.Elem())
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return d.unmarshalTypeError(v.Type())
+		}
+		value, err := d.value()
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(value))
+		return nil
+	}
+
+	if d.off >= len(d.data) {
+		return d.truncated(ErrInvalidLeadingByte)
+	}
+	switch d.data[d.off] {
+	case 'i':
+		return d.unmarshalInt(v)
+	case 'l':
+		return d.unmarshalList(v)
+	case 'd':
+		return d.unmarshalDict(v)
+	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return d.unmarshalString(v)
+	default:
+		return d.error(d.off, ErrInvalidLeadingByte)
+	}
+}
+
+func (d *decodeState) unmarshalInt(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Bool:
+	default:
+		return d.unmarshalTypeError(v.Type())
+	}
+
+	start := d.off
+	x, err := d.int()
+	if err != nil {
+		return err
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.OverflowInt(x) {
+			return d.error(start, fmt.Errorf("%w: %d overflows %s", ErrUnmarshalType, x, v.Type()))
+		}
+		v.SetInt(x)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if x < 0 || v.OverflowUint(uint64(x)) {
+			return d.error(start, fmt.Errorf("%w: %d overflows %s", ErrUnmarshalType, x, v.Type()))
+		}
+		v.SetUint(uint64(x))
+	case reflect.Bool:
+		v.SetBool(x != 0)
+	}
+	return nil
+}
+
+func (d *decodeState) unmarshalString(v reflect.Value) error {
+	isBytes := (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Type().Elem().Kind() == reflect.Uint8
+	if v.Kind() != reflect.String && !isBytes {
+		return d.unmarshalTypeError(v.Type())
+	}
+
+	start := d.off
+	x, err := d.string()
+	if err != nil {
+		return err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(string(x))
+	case reflect.Slice:
+		v.SetBytes(append([]byte{}, x...))
+	case reflect.Array:
+		if len(x) != v.Len() {
+			return d.error(start, fmt.Errorf("%w: string of length %d into %s", ErrUnmarshalType, len(x), v.Type()))
+		}
+		reflect.Copy(v, reflect.ValueOf(x))
+	}
+	return nil
+}
+
+func (d *decodeState) unmarshalList(v reflect.Value) error {
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return d.unmarshalTypeError(v.Type())
+	}
+
+	if v.Kind() == reflect.Slice {
+		v.SetLen(0)
+	}
+
+	if err := d.open(); err != nil {
+		return err
+	}
+	defer d.lim.leave()
+	i := 0
+	for {
+		more, err := d.more()
+		if err != nil {
+			return err
+		}
+		if !more {
+			break
+		}
+
+		if v.Kind() == reflect.Slice {
+			if i >= v.Cap() {
+				v.Grow(1)
+			}
+			v.SetLen(i + 1)
+			v.Index(i).SetZero()
+		}
+		d.path.pushIndex(i)
+		if i < v.Len() {
+			if err := d.unmarshal(v.Index(i)); err != nil {
+				return err
+			}
+		} else if err := d.skip(); err != nil {
+			return err
+		}
+		d.path.pop()
+		i++
+	}
+
+	switch {
+	case v.Kind() == reflect.Array:
+		for ; i < v.Len(); i++ {
+			v.Index(i).SetZero()
+		}
+	case i == 0:
+		v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+	default:
+		v.SetLen(i)
+	}
+	return nil
+}
+
+func (d *decodeState) unmarshalDict(v reflect.Value) error {
+	var fields structFields
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return d.unmarshalTypeError(v.Type())
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+	case reflect.Struct:
+		fields = cachedFields(v.Type())
+	default:
+		return d.unmarshalTypeError(v.Type())
+	}
+
+	if err := d.open(); err != nil {
+		return err
+	}
+	defer d.lim.leave()
+	for {
+		more, err := d.more()
+		if err != nil || !more {
+			return err
+		}
+		key, err := d.string()
+		if err != nil {
+			return err
+		}
+		d.path.pushRawKey(key)
+		if err := d.unmarshalDictValue(v, fields, key); err != nil {
+			return err
+		}
+		d.path.pop()
+	}
+}
+
+func (d *decodeState) unmarshalDictValue(v reflect.Value, fields structFields, key []byte) error {
+	if v.Kind() == reflect.Map {
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if err := d.unmarshal(elem); err != nil {
+			return err
+		}
+		v.SetMapIndex(reflect.ValueOf(string(key)).Convert(v.Type().Key()), elem)
+		return nil
+	}
+
+	f, ok := fields.byName[string(key)]
+	if !ok {
+		return d.skip()
+	}
+	fv, err := fieldByIndexAlloc(v, f.index)
+	if err != nil {
+		return d.error(d.off, err)
+	}
+	return d.unmarshal(fv)
+}
+
+// skip advances past the next value without decoding it.
+func (d *decodeState) skip() error {
+	if d.off >= len(d.data) {
+		return d.truncated(ErrInvalidLeadingByte)
+	}
+	switch d.data[d.off] {
+	case 'i':
+		_, err := d.int()
+		return err
+	case 'l', 'd':
+		dict := d.data[d.off] == 'd'
+		if err := d.open(); err != nil {
+			return err
+		}
+		defer d.lim.leave()
+		for i := 0; ; i++ {
+			more, err := d.more()
+			if err != nil || !more {
+				return err
+			}
+			if dict {
+				key, err := d.string()
+				if err != nil {
+					return err
+				}
+				d.path.pushRawKey(key)
+			} else {
+				d.path.pushIndex(i)
+			}
+			if err := d.skip(); err != nil {
+				return err
+			}
+			d.path.pop()
+		}
+	case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		_, err := d.string()
+		return err
+	default:
+		return d.error(d.off, ErrInvalidLeadingByte)
+	}
+}
+
+// fieldByIndexAlloc is like reflect.Value.FieldByIndex but allocates nil
+// embedded pointers on the way down.
+func fieldByIndexAlloc(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, fmt.Errorf("%w: nil embedded pointer to unexported %s", ErrUnmarshalType, v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
+
+func (d *decodeState) unmarshalTypeError(t reflect.Type) error {
+	var kind string
+	switch {
+	case d.off >= len(d.data):
+		return d.truncated(ErrInvalidLeadingByte)
+	case d.data[d.off] == 'i':
+		kind = "integer"
+	case d.data[d.off] == 'l':
+		kind = "list"
+	case d.data[d.off] == 'd':
+		kind = "dict"
+	case d.data[d.off] >= '0' && d.data[d.off] <= '9':
+		kind = "string"
+	default:
+		return d.error(d.off, ErrInvalidLeadingByte)
+	}
+	return d.error(d.off, fmt.Errorf("%w: %s into %s", ErrUnmarshalType, kind, t))
+}
@@ -0,0 +1,98 @@
+package bencode
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestUnmarshal(t *testing.T) {
+	comment := "hi"
+	tests := []struct {
+		input       string
+		into        interface{}
+		expected    interface{}
+		expectedErr error
+	}{
+		{"4:spam", new(string), "spam", nil},
+		{"4:spam", new([]byte), []byte("spam"), nil},
+		{"4:spam", new([4]byte), [4]byte{'s', 'p', 'a', 'm'}, nil},
+		{"i42e", new(int), 42, nil},
+		{"i42e", new(uint8), uint8(42), nil},
+		{"i1e", new(bool), true, nil},
+		{"i0e", new(bool), false, nil},
+		{"i42e", new(*int64), func() *int64 { v := int64(42); return &v }(), nil},
+		{"li1ei2ee", new([]int), []int{1, 2}, nil},
+		{"li1ee", new([2]int), [2]int{1, 0}, nil},
+		{"d1:ai1e1:bi2ee", new(map[string]int), map[string]int{"a": 1, "b": 2}, nil},
+		{"li1e1:ae", new(interface{}), []interface{}{int64(1), "a"}, nil},
+		{
+			"d8:Untaggedi7e7:comment2:hi5:filesld6:lengthi3e4:pathl1:a1:beee4:hash4:abcd4:name1:x12:piece lengthi16e7:privatei1e6:source3:src7:unknowni0ee",
+			new(marshalOuter),
+			marshalOuter{
+				marshalEmbedded: marshalEmbedded{Source: "src"},
+				Name:            "x",
+				PieceLength:     16,
+				Private:         true,
+				Hash:            [4]byte{'a', 'b', 'c', 'd'},
+				Files:           []marshalInner{{Path: []string{"a", "b"}, Size: 3}},
+				Comment:         &comment,
+				Untagged:        7,
+			},
+			nil,
+		},
+		{"i256e", new(uint8), nil, ErrUnmarshalType},          // Error case: overflow
+		{"i-1e", new(uint), nil, ErrUnmarshalType},            // Error case: negative into unsigned
+		{"4:spam", new(int), nil, ErrUnmarshalType},           // Error case: string into int
+		{"3:abc", new([4]byte), nil, ErrUnmarshalType},        // Error case: array length mismatch
+		{"li1ee", new(map[string]int), nil, ErrUnmarshalType}, // Error case: list into map
+		{"i1ei2e", new(int), nil, ErrTrailingData},            // Error case: trailing data
+		{"i1", new(int), nil, ErrInvalidEndingByte},           // Error case: truncated input
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			t.Parallel()
+			err := Unmarshal([]byte(test.input), test.into)
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("expected error %v, got %v for input %q", test.expectedErr, err, test.input)
+			}
+			if err != nil {
+				return
+			}
+			result := reflect.ValueOf(test.into).Elem().Interface()
+			if !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("expected %#v, got %#v for input %q", test.expected, result, test.input)
+			}
+		})
+	}
+
+	t.Run("non-pointer", func(t *testing.T) {
+		var v int
+		if err := Unmarshal([]byte("i1e"), v); err != ErrInvalidUnmarshal {
+			t.Errorf("expected error %v, got %v", ErrInvalidUnmarshal, err)
+		}
+	})
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	input := marshalOuter{
+		Name:        "round trip",
+		PieceLength: 262144,
+		Hash:        [4]byte{0, 1, 2, 3},
+		Raw:         []byte{0xff, 0x00},
+		Files:       []marshalInner{{Path: []string{"dir", "file"}, Size: 1 << 20}},
+		Extra:       map[string]int{"a": -1},
+	}
+	data, err := Marshal(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var output marshalOuter
+	if err := Unmarshal(data, &output); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(input, output) {
+		t.Errorf("expected %#v, got %#v", input, output)
+	}
+}
@@ -0,0 +1,240 @@
+package bencode
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+var ErrWriterState = errors.New("invalid writer state")
+
+// Writer writes bencode one token at a time without building the value in
+// memory. It checks that the output is well formed as it goes, including that
+// dictionary keys are written in sorted order, so everything it writes is
+// canonical.
+type Writer struct {
+	w       io.Writer
+	stack   []writerFrame
+	pending *stringWriter
+}
+
+type writerFrame struct {
+	dict    bool
+	n       int    // values written
+	haveKey bool   // a key has been written and awaits its value
+	lastKey string // last key written in a dictionary
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Depth returns the number of lists and dictionaries that are still open.
+func (w *Writer) Depth() int {
+	return len(w.stack)
+}
+
+func (w *Writer) top() *writerFrame {
+	if len(w.stack) == 0 {
+		return nil
+	}
+	return &w.stack[len(w.stack)-1]
+}
+
+// value checks that a value may be written next.
+func (w *Writer) value() error {
+	if w.pending != nil {
+		return fmt.Errorf("%w: string writer still open", ErrWriterState)
+	}
+	if top := w.top(); top != nil && top.dict && !top.haveKey {
+		return fmt.Errorf("%w: dictionary key expected", ErrWriterState)
+	}
+	return nil
+}
+
+// done records that a value has been written.
+func (w *Writer) done() {
+	if top := w.top(); top != nil {
+		top.n++
+		top.haveKey = false
+	}
+}
+
+func (w *Writer) write(b []byte) error {
+	_, err := w.w.Write(b)
+	return err
+}
+
+func (w *Writer) begin(dict bool) error {
+	if err := w.value(); err != nil {
+		return err
+	}
+	leading := byte('l')
+	if dict {
+		leading = 'd'
+	}
+	if err := w.write([]byte{leading}); err != nil {
+		return err
+	}
+	w.stack = append(w.stack, writerFrame{dict: dict})
+	return nil
+}
+
+// BeginDict starts a dictionary. Its items are written as alternating calls to
+// Key and a value, and it is closed with End.
+func (w *Writer) BeginDict() error {
+	return w.begin(true)
+}
+
+// BeginList starts a list, which is closed with End.
+func (w *Writer) BeginList() error {
+	return w.begin(false)
+}
+
+// Key writes the next key of the current dictionary. Keys must be written in
+// strictly increasing order.
+func (w *Writer) Key(key string) error {
+	top := w.top()
+	if w.pending != nil || top == nil || !top.dict || top.haveKey {
+		return fmt.Errorf("%w: unexpected key %q", ErrWriterState, key)
+	}
+	if top.n > 0 {
+		if key == top.lastKey {
+			return fmt.Errorf("%w: %q", ErrDuplicateKey, key)
+		}
+		if key < top.lastKey {
+			return fmt.Errorf("%w: %q after %q", ErrUnsortedKeys, key, top.lastKey)
+		}
+	}
+	if err := w.writeString(key); err != nil {
+		return err
+	}
+	top.haveKey = true
+	top.lastKey = key
+	return nil
+}
+
+// End closes the current list or dictionary.
+func (w *Writer) End() error {
+	top := w.top()
+	if w.pending != nil || top == nil || top.haveKey {
+		return fmt.Errorf("%w: unexpected end", ErrWriterState)
+	}
+	if err := w.write([]byte{'e'}); err != nil {
+		return err
+	}
+	w.stack = w.stack[:len(w.stack)-1]
+	w.done()
+	return nil
+}
+
+func (w *Writer) Int(v int64) error {
+	if err := w.value(); err != nil {
+		return err
+	}
+	if err := w.write(strconv.AppendInt([]byte{'i'}, v, 10)); err != nil {
+		return err
+	}
+	if err := w.write([]byte{'e'}); err != nil {
+		return err
+	}
+	w.done()
+	return nil
+}
+
+func (w *Writer) String(v string) error {
+	if err := w.value(); err != nil {
+		return err
+	}
+	if err := w.writeString(v); err != nil {
+		return err
+	}
+	w.done()
+	return nil
+}
+
+func (w *Writer) Bytes(v []byte) error {
+	if err := w.value(); err != nil {
+		return err
+	}
+	if err := w.writeLength(int64(len(v))); err != nil {
+		return err
+	}
+	if err := w.write(v); err != nil {
+		return err
+	}
+	w.done()
+	return nil
+}
+
+func (w *Writer) writeString(v string) error {
+	if err := w.writeLength(int64(len(v))); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w.w, v)
+	return err
+}
+
+func (w *Writer) writeLength(n int64) error {
+	return w.write(append(strconv.AppendInt(nil, n, 10), ':'))
+}
+
+// Value writes v as encoded by an Encoder.
+func (w *Writer) Value(v interface{}) error {
+	if err := w.value(); err != nil {
+		return err
+	}
+	if err := NewEncoder(w.w).Encode(v); err != nil {
+		return err
+	}
+	w.done()
+	return nil
+}
+
+// StringWriter starts a byte string of the given length whose contents are
+// written through the returned writer, so that large strings such as piece
+// hashes can be produced incrementally. Exactly length bytes must be written
+// before the writer is closed, and nothing else may be written until then.
+func (w *Writer) StringWriter(length int64) (io.WriteCloser, error) {
+	if err := w.value(); err != nil {
+		return nil, err
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("%w: negative string length %d", ErrWriterState, length)
+	}
+	if err := w.writeLength(length); err != nil {
+		return nil, err
+	}
+	w.pending = &stringWriter{w: w, remaining: length}
+	return w.pending, nil
+}
+
+type stringWriter struct {
+	w         *Writer
+	remaining int64
+}
+
+func (s *stringWriter) Write(p []byte) (int, error) {
+	if s.w.pending != s {
+		return 0, fmt.Errorf("%w: string writer closed", ErrWriterState)
+	}
+	if int64(len(p)) > s.remaining {
+		return 0, fmt.Errorf("%w: string longer than declared", ErrWriterState)
+	}
+	n, err := s.w.w.Write(p)
+	s.remaining -= int64(n)
+	return n, err
+}
+
+func (s *stringWriter) Close() error {
+	if s.w.pending != s {
+		return nil
+	}
+	if s.remaining != 0 {
+		return fmt.Errorf("%w: string %d bytes shorter than declared", ErrWriterState, s.remaining)
+	}
+	s.w.pending = nil
+	s.w.done()
+	return nil
+}
@@ -0,0 +1,118 @@
+package bencode
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	steps := []func() error{
+		w.BeginDict,
+		func() error { return w.Key("announce") },
+		func() error { return w.String("http://tracker") },
+		func() error { return w.Key("info") },
+		w.BeginDict,
+		func() error { return w.Key("files") },
+		w.BeginList,
+		func() error { return w.Value(map[string]interface{}{"length": int64(1), "path": []string{"a"}}) },
+		w.End,
+		func() error { return w.Key("name") },
+		func() error { return w.Bytes([]byte("x")) },
+		func() error { return w.Key("piece length") },
+		func() error { return w.Int(16384) },
+		func() error { return w.Key("pieces") },
+		func() error {
+			sw, err := w.StringWriter(40)
+			if err != nil {
+				return err
+			}
+			for i := 0; i < 2; i++ {
+				if _, err := sw.Write(bytes.Repeat([]byte{'h'}, 20)); err != nil {
+					return err
+				}
+			}
+			return sw.Close()
+		},
+		w.End,
+		w.End,
+	}
+	for i, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+	}
+
+	expected := "d8:announce14:http://tracker4:infod5:filesld6:lengthi1e4:pathl1:aeee4:name1:x12:piece lengthi16384e6:pieces40:" + strings.Repeat("h", 40) + "ee"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+	if w.Depth() != 0 {
+		t.Errorf("expected depth 0, got %d", w.Depth())
+	}
+}
+
+func TestWriterErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		steps       func(w *Writer) error
+		expectedErr error
+	}{
+		{"unsorted keys", func(w *Writer) error {
+			w.BeginDict()
+			w.Key("b")
+			w.Int(1)
+			return w.Key("a")
+		}, ErrUnsortedKeys},
+		{"duplicate keys", func(w *Writer) error {
+			w.BeginDict()
+			w.Key("a")
+			w.Int(1)
+			return w.Key("a")
+		}, ErrDuplicateKey},
+		{"value without key", func(w *Writer) error {
+			w.BeginDict()
+			return w.Int(1)
+		}, ErrWriterState},
+		{"key in list", func(w *Writer) error {
+			w.BeginList()
+			return w.Key("a")
+		}, ErrWriterState},
+		{"key without value", func(w *Writer) error {
+			w.BeginDict()
+			w.Key("a")
+			return w.End()
+		}, ErrWriterState},
+		{"end without begin", func(w *Writer) error {
+			return w.End()
+		}, ErrWriterState},
+		{"write during string", func(w *Writer) error {
+			w.StringWriter(4)
+			return w.Int(1)
+		}, ErrWriterState},
+		{"string too long", func(w *Writer) error {
+			sw, _ := w.StringWriter(2)
+			_, err := sw.Write([]byte("abc"))
+			return err
+		}, ErrWriterState},
+		{"string too short", func(w *Writer) error {
+			sw, _ := w.StringWriter(2)
+			sw.Write([]byte("a"))
+			return sw.Close()
+		}, ErrWriterState},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			err := test.steps(NewWriter(&buf))
+			if !errors.Is(err, test.expectedErr) {
+				t.Errorf("expected error %v, got %v", test.expectedErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+// Package magnet parses and builds BitTorrent magnet links (BEP 9), including
+// v2 multihashes (BEP 52) and select-only file ranges (BEP 53).
+package magnet
+
+import (
+	"cmp"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidScheme = errors.New("not a magnet link")
+	ErrMissingHash   = errors.New("missing btih or btmh exact topic")
+	ErrInvalidHash   = errors.New("invalid info hash")
+	ErrInvalidParam  = errors.New("invalid parameter")
+)
+
+const (
+	btihPrefix = "urn:btih:"
+	btmhPrefix = "urn:btmh:"
+
+	// sha256Multihash is the multihash prefix of a SHA-256 digest: the
+	// function code 0x12 followed by the digest length 0x20.
+	sha256Multihash = "1220"
+)
+
+// Magnet is a parsed magnet link. At least one of InfoHash and InfoHashV2 is
+// set; the other is zero when absent.
+type Magnet struct {
+	InfoHash    [20]byte // xt=urn:btih, the v1 info hash
+	InfoHashV2  [32]byte // xt=urn:btmh, the v2 info hash
+	DisplayName string   // dn
+	Length      int64    // xl, zero when absent
+	Trackers    []string // tr
+	WebSeeds    []string // ws
+	Peers       []string // x.pe, as host:port
+	SelectOnly  []Range  // so (BEP 53)
+
+	// Params holds any other parameters, which String writes back unchanged.
+	Params url.Values
+}
+
+// Range is an inclusive range of file indexes selected by a magnet link.
+type Range struct {
+	First, Last int
+}
+
+// HasV1 reports whether m carries a v1 info hash.
+func (m Magnet) HasV1() bool {
+	return m.InfoHash != [20]byte{}
+}
+
+// HasV2 reports whether m carries a v2 info hash.
+func (m Magnet) HasV2() bool {
+	return m.InfoHashV2 != [32]byte{}
+}
+
+// Selected reports whether the file at index should be downloaded. Every file
+// is selected when the link has no so parameter.
+func (m Magnet) Selected(index int) bool {
+	if len(m.SelectOnly) == 0 {
+		return true
+	}
+	for _, r := range m.SelectOnly {
+		if index >= r.First && index <= r.Last {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse parses a magnet link. The v1 info hash may be given in hex or base32,
+// and the v2 info hash as a hex SHA-256 multihash. Parameters numbered as in
+// xt.1 or tr.2 are treated like their plain forms.
+func Parse(s string) (*Magnet, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "magnet" || u.Opaque != "" && u.Opaque != "?" {
+		return nil, ErrInvalidScheme
+	}
+	params, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidParam, err)
+	}
+
+	// Numbered keys are sorted by number, so that tr.10 comes after tr.2.
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		baseA, nA := splitKey(a)
+		baseB, nB := splitKey(b)
+		return cmp.Or(strings.Compare(baseA, baseB), cmp.Compare(nA, nB), strings.Compare(a, b))
+	})
+
+	var m Magnet
+	for _, key := range keys {
+		for _, value := range params[key] {
+			if err := m.parseParam(key, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if !m.HasV1() && !m.HasV2() {
+		return nil, ErrMissingHash
+	}
+	return &m, nil
+}
+
+func (m *Magnet) parseParam(key, value string) error {
+	switch baseKey(key) {
+	case "xt":
+		switch {
+		case strings.HasPrefix(value, btihPrefix):
+			hash, err := parseBTIH(value[len(btihPrefix):])
+			if err != nil {
+				return err
+			}
+			m.InfoHash = hash
+			return nil
+		case strings.HasPrefix(value, btmhPrefix):
+			hash, err := parseBTMH(value[len(btmhPrefix):])
+			if err != nil {
+				return err
+			}
+			m.InfoHashV2 = hash
+			return nil
+		}
+	case "dn":
+		m.DisplayName = value
+		return nil
+	case "xl":
+		length, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || length < 0 {
+			return fmt.Errorf("%w: xl=%q", ErrInvalidParam, value)
+		}
+		m.Length = length
+		return nil
+	case "tr":
+		m.Trackers = append(m.Trackers, value)
+		return nil
+	case "ws":
+		m.WebSeeds = append(m.WebSeeds, value)
+		return nil
+	case "x.pe":
+		m.Peers = append(m.Peers, value)
+		return nil
+	case "so":
+		ranges, err := parseSelectOnly(value)
+		if err != nil {
+			return err
+		}
+		m.SelectOnly = append(m.SelectOnly, ranges...)
+		return nil
+	}
+	if m.Params == nil {
+		m.Params = make(url.Values)
+	}
+	m.Params.Add(key, value)
+	return nil
+}
+
+// baseKey strips a numeric suffix such as the ".1" of "xt.1" from the keys
+// that may be repeated that way.
+func baseKey(key string) string {
+	base, _ := splitKey(key)
+	return base
+}
+
+// splitKey splits a key such as "tr.2" into its base key and number. Keys
+// without a number are returned whole, with the number -1.
+func splitKey(key string) (string, int64) {
+	base, suffix, ok := strings.Cut(key, ".")
+	if !ok || (base != "xt" && base != "tr") {
+		return key, -1
+	}
+	n, err := strconv.ParseUint(suffix, 10, 32)
+	if err != nil {
+		return key, -1
+	}
+	return base, int64(n)
+}
+
+func parseBTIH(s string) ([20]byte, error) {
+	var hash [20]byte
+	var err error
+	switch len(s) {
+	case 40:
+		_, err = hex.Decode(hash[:], []byte(s))
+	case 32:
+		_, err = base32.StdEncoding.Decode(hash[:], []byte(strings.ToUpper(s)))
+	default:
+		err = errors.New("wrong length")
+	}
+	if err != nil {
+		return hash, fmt.Errorf("%w: btih %q: %v", ErrInvalidHash, s, err)
+	}
+	return hash, nil
+}
+
+func parseBTMH(s string) ([32]byte, error) {
+	var hash [32]byte
+	if len(s) != len(sha256Multihash)+64 || !strings.HasPrefix(s, sha256Multihash) {
+		return hash, fmt.Errorf("%w: btmh %q is not a SHA-256 multihash", ErrInvalidHash, s)
+	}
+	if _, err := hex.Decode(hash[:], []byte(s[len(sha256Multihash):])); err != nil {
+		return hash, fmt.Errorf("%w: btmh %q: %v", ErrInvalidHash, s, err)
+	}
+	return hash, nil
+}
+
+// parseSelectOnly parses a BEP 53 list of file indexes and ranges, such as
+// "0,2,4-6".
+func parseSelectOnly(s string) ([]Range, error) {
+	var ranges []Range
+	for _, item := range strings.Split(s, ",") {
+		first, last, isRange := strings.Cut(item, "-")
+		r := Range{}
+		var err1, err2 error
+		r.First, err1 = strconv.Atoi(first)
+		r.Last = r.First
+		if isRange {
+			r.Last, err2 = strconv.Atoi(last)
+		}
+		if err1 != nil || err2 != nil || r.First < 0 || r.Last < r.First {
+			return nil, fmt.Errorf("%w: so=%q", ErrInvalidParam, s)
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// String returns m as a magnet link. The v1 info hash is written in hex.
+func (m Magnet) String() string {
+	var b strings.Builder
+	b.WriteString("magnet:?")
+	sep := ""
+	param := func(key, value string) {
+		b.WriteString(sep)
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(value)
+		sep = "&"
+	}
+
+	if m.HasV1() {
+		param("xt", btihPrefix+hex.EncodeToString(m.InfoHash[:]))
+	}
+	if m.HasV2() {
+		param("xt", btmhPrefix+sha256Multihash+hex.EncodeToString(m.InfoHashV2[:]))
+	}
+	if m.DisplayName != "" {
+		param("dn", url.QueryEscape(m.DisplayName))
+	}
+	if m.Length > 0 {
+		param("xl", strconv.FormatInt(m.Length, 10))
+	}
+	for _, tracker := range m.Trackers {
+		param("tr", url.QueryEscape(tracker))
+	}
+	for _, webSeed := range m.WebSeeds {
+		param("ws", url.QueryEscape(webSeed))
+	}
+	for _, peer := range m.Peers {
+		param("x.pe", url.QueryEscape(peer))
+	}
+	if len(m.SelectOnly) > 0 {
+		items := make([]string, len(m.SelectOnly))
+		for i, r := range m.SelectOnly {
+			items[i] = strconv.Itoa(r.First)
+			if r.Last != r.First {
+				items[i] += "-" + strconv.Itoa(r.Last)
+			}
+		}
+		param("so", strings.Join(items, ","))
+	}
+	if len(m.Params) > 0 {
+		b.WriteString(sep)
+		b.WriteString(m.Params.Encode())
+	}
+	return b.String()
+}
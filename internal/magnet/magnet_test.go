@@ -0,0 +1,179 @@
+package magnet
+
+import (
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+const (
+	hexHash    = "4a3f5e08bcef825718eda30637230585e3330599"
+	base32Hash = "JI7V4CF456BFOGHNUMDDOIYFQXRTGBMZ"
+	v2Hash     = "a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f90"
+)
+
+func mustHash20(s string) [20]byte {
+	var hash [20]byte
+	hex.Decode(hash[:], []byte(s))
+	return hash
+}
+
+func mustHash32(s string) [32]byte {
+	var hash [32]byte
+	hex.Decode(hash[:], []byte(s))
+	return hash
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		link     string
+		expected Magnet
+	}{
+		{
+			"hex",
+			"magnet:?xt=urn:btih:" + hexHash,
+			Magnet{InfoHash: mustHash20(hexHash)},
+		},
+		{
+			"base32",
+			"magnet:?xt=urn:btih:" + base32Hash,
+			Magnet{InfoHash: mustHash20(hexHash)},
+		},
+		{
+			"lowercase base32",
+			"magnet:?xt=urn:btih:ji7v4cf456bfoghnumddoiyfqxrtgbmz",
+			Magnet{InfoHash: mustHash20(hexHash)},
+		},
+		{
+			"v2",
+			"magnet:?xt=urn:btmh:1220" + v2Hash,
+			Magnet{InfoHashV2: mustHash32(v2Hash)},
+		},
+		{
+			"hybrid",
+			"magnet:?xt=urn:btih:" + hexHash + "&xt=urn:btmh:1220" + v2Hash,
+			Magnet{InfoHash: mustHash20(hexHash), InfoHashV2: mustHash32(v2Hash)},
+		},
+		{
+			"all parameters",
+			"magnet:?xt=urn:btih:" + hexHash + "&dn=ubuntu+24.04%2Biso&xl=6203355136" +
+				"&tr=http%3A%2F%2Fa%2Fannounce&tr=udp://b:80&ws=http%3A%2F%2Fw%2F" +
+				"&x.pe=10.0.0.1:6881&x.pe=[::1]:6881&so=0,2,4-6",
+			Magnet{
+				InfoHash:    mustHash20(hexHash),
+				DisplayName: "ubuntu 24.04+iso",
+				Length:      6203355136,
+				Trackers:    []string{"http://a/announce", "udp://b:80"},
+				WebSeeds:    []string{"http://w/"},
+				Peers:       []string{"10.0.0.1:6881", "[::1]:6881"},
+				SelectOnly:  []Range{{0, 0}, {2, 2}, {4, 6}},
+			},
+		},
+		{
+			"numbered parameters",
+			"magnet:?xt.1=urn:btih:" + hexHash + "&tr.1=http://a&tr.2=http://b",
+			Magnet{InfoHash: mustHash20(hexHash), Trackers: []string{"http://a", "http://b"}},
+		},
+		{
+			"ten or more numbered trackers",
+			"magnet:?xt=urn:btih:" + hexHash + "&tr.10=http://10&tr.2=http://2&tr.11=http://11&tr.1=http://1" +
+				"&tr.3=http://3&tr.4=http://4&tr.5=http://5&tr.6=http://6&tr.7=http://7&tr.8=http://8&tr.9=http://9&tr=http://0",
+			Magnet{InfoHash: mustHash20(hexHash), Trackers: []string{
+				"http://0", "http://1", "http://2", "http://3", "http://4", "http://5",
+				"http://6", "http://7", "http://8", "http://9", "http://10", "http://11",
+			}},
+		},
+		{
+			"unknown parameters",
+			"magnet:?xt=urn:btih:" + hexHash + "&kt=ubuntu&xt=urn:sha1:abc",
+			Magnet{InfoHash: mustHash20(hexHash), Params: url.Values{"kt": {"ubuntu"}, "xt": {"urn:sha1:abc"}}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m, err := Parse(test.link)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*m, test.expected) {
+				t.Errorf("expected %+v, got %+v", test.expected, *m)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		link     string
+		expected error
+	}{
+		{"http", "http://example.com/?xt=urn:btih:" + hexHash, ErrInvalidScheme},
+		{"opaque", "magnet:xt=urn:btih:" + hexHash, ErrInvalidScheme},
+		{"no hash", "magnet:?dn=a", ErrMissingHash},
+		{"other hash only", "magnet:?xt=urn:sha1:abc", ErrMissingHash},
+		{"short btih", "magnet:?xt=urn:btih:4a3f", ErrInvalidHash},
+		{"bad hex", "magnet:?xt=urn:btih:" + hexHash[:39] + "z", ErrInvalidHash},
+		{"bad base32", "magnet:?xt=urn:btih:" + base32Hash[:31] + "1", ErrInvalidHash},
+		{"sha1 multihash", "magnet:?xt=urn:btmh:1114" + hexHash, ErrInvalidHash},
+		{"short btmh", "magnet:?xt=urn:btmh:1220" + v2Hash[:62], ErrInvalidHash},
+		{"bad xl", "magnet:?xt=urn:btih:" + hexHash + "&xl=-1", ErrInvalidParam},
+		{"bad so", "magnet:?xt=urn:btih:" + hexHash + "&so=1,a", ErrInvalidParam},
+		{"reversed so", "magnet:?xt=urn:btih:" + hexHash + "&so=6-4", ErrInvalidParam},
+		{"bad escape", "magnet:?xt=urn:btih:" + hexHash + "&dn=%zz", ErrInvalidParam},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Parse(test.link)
+			if !errors.Is(err, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, err)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	m := Magnet{
+		InfoHash:    mustHash20(hexHash),
+		InfoHashV2:  mustHash32(v2Hash),
+		DisplayName: "a b&c",
+		Length:      42,
+		Trackers:    []string{"http://a/announce?k=1"},
+		WebSeeds:    []string{"http://w/"},
+		Peers:       []string{"10.0.0.1:6881"},
+		SelectOnly:  []Range{{0, 0}, {4, 6}},
+		Params:      url.Values{"kt": {"x y"}},
+	}
+	expected := "magnet:?xt=urn:btih:" + hexHash + "&xt=urn:btmh:1220" + v2Hash +
+		"&dn=a+b%26c&xl=42&tr=http%3A%2F%2Fa%2Fannounce%3Fk%3D1&ws=http%3A%2F%2Fw%2F" +
+		"&x.pe=10.0.0.1%3A6881&so=0,4-6&kt=x+y"
+	if s := m.String(); s != expected {
+		t.Errorf("expected %s, got %s", expected, s)
+	}
+
+	parsed, err := Parse(m.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*parsed, m) {
+		t.Errorf("expected %+v, got %+v", m, *parsed)
+	}
+}
+
+func TestSelected(t *testing.T) {
+	all := Magnet{}
+	some := Magnet{SelectOnly: []Range{{0, 0}, {4, 6}}}
+	for i, expected := range []bool{true, false, false, false, true, true, true, false} {
+		if !all.Selected(i) {
+			t.Errorf("expected file %d to be selected without so", i)
+		}
+		if some.Selected(i) != expected {
+			t.Errorf("expected file %d selected to be %v", i, expected)
+		}
+	}
+}
@@ -0,0 +1,471 @@
+package metainfo
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidPieceLength = errors.New("piece length must be a power of two of at least 16 KiB")
+	ErrUnsupportedVersion = errors.New("unsupported metainfo version")
+)
+
+// Version selects the metainfo versions a Builder creates.
+type Version uint8
+
+const (
+	V1 Version = 1 << iota // SHA-1 pieces (BEP 3)
+	V2                     // SHA-256 merkle trees (BEP 52)
+
+	Hybrid = V1 | V2 // both, with BEP 47 padding files aligning v1 files to pieces
+)
+
+// Bounds and target used to choose a piece length automatically.
+const (
+	minPieceLength = 16 << 10
+	maxPieceLength = 16 << 20
+	targetPieces   = 1500
+)
+
+// Builder creates torrents from files on disk. The zero value builds a public
+// torrent with an automatically chosen piece length, hashing on every CPU.
+type Builder struct {
+	// AnnounceList holds the tracker tiers. The first tracker is written as
+	// announce, and the full list as announce-list when there is more than one
+	// tracker.
+	AnnounceList [][]string
+	Comment      string
+	CreatedBy    string
+	CreationDate time.Time // omitted when zero
+	URLList      []string  // BEP 19 web seeds
+
+	// PieceLength is the number of bytes per piece. When zero it is chosen
+	// from the total size, aiming for about 1500 pieces.
+	PieceLength int64
+	Private     bool
+	MD5Sum      bool // also compute the md5sum of every v1 file, reading it twice
+
+	// Version is the metainfo version to create, V1 by default.
+	Version Version
+
+	// Workers is the number of pieces hashed in parallel, defaulting to the
+	// number of CPUs.
+	Workers int
+
+	// Progress, if set, is called after each piece is hashed with the number
+	// of bytes hashed so far and the total. Calls are never concurrent.
+	Progress func(hashed, total int64)
+}
+
+// buildFile is a file of the torrent being built.
+type buildFile struct {
+	osPath     string
+	path       []string
+	length     int64
+	md5sum     []byte
+	pad        int64 // length of the padding file following it in a hybrid
+	piecesRoot [32]byte
+	pieceLayer [][32]byte
+}
+
+// Build hashes the file or directory at root and writes the resulting torrent
+// to w through the bencode encoder. A directory becomes a multi-file torrent
+// holding every regular file below it in lexical order, and is named after
+// root. It returns the torrent as Parse would read it back.
+func (b *Builder) Build(w io.Writer, root string) (*MetaInfo, error) {
+	if len(b.AnnounceList) == 0 || len(b.AnnounceList[0]) == 0 {
+		return nil, &FieldError{Path: "announce", Err: ErrMissingField}
+	}
+
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	var files []buildFile
+	if stat.IsDir() {
+		files, err = walkFiles(root)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			return nil, ErrNoFiles
+		}
+	} else {
+		files = []buildFile{{osPath: root, path: []string{stat.Name()}, length: stat.Size()}}
+	}
+
+	version := b.Version
+	if version == 0 {
+		version = V1
+	}
+	if version != V1 && version != V2 && version != Hybrid {
+		return nil, ErrUnsupportedVersion
+	}
+
+	var total int64
+	for _, f := range files {
+		total += f.length
+	}
+	pieceLength := b.PieceLength
+	if pieceLength == 0 {
+		pieceLength = autoPieceLength(total)
+	} else if pieceLength < minPieceLength || pieceLength&(pieceLength-1) != 0 {
+		return nil, ErrInvalidPieceLength
+	}
+
+	workers := b.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	info := Info{
+		Name:        stat.Name(),
+		PieceLength: pieceLength,
+		Private:     b.Private,
+	}
+	var pieceLayers map[[32]byte][][32]byte
+
+	p := &progress{fn: b.Progress, total: total}
+	if version&V2 == 0 {
+		if info.Pieces, err = hashPieces(files, pieceLength, total, workers, p); err != nil {
+			return nil, err
+		}
+	} else {
+		// The files of a hybrid torrent are padded to piece boundaries, so its
+		// v1 pieces are hashed from the same reads as the v2 merkle trees.
+		var pieces [][20]byte
+		if version&V1 != 0 {
+			pieces = make([][20]byte, padFiles(files, pieceLength))
+		}
+		if err := hashFileTrees(files, pieceLength, workers, p, pieces); err != nil {
+			return nil, err
+		}
+		info.Pieces = pieces
+		info.MetaVersion = 2
+		info.FileTree = make([]File, len(files))
+		for i, f := range files {
+			info.FileTree[i] = File{Length: f.length, Path: f.path, PiecesRoot: f.piecesRoot}
+			if f.pieceLayer != nil {
+				if pieceLayers == nil {
+					pieceLayers = make(map[[32]byte][][32]byte)
+				}
+				pieceLayers[f.piecesRoot] = f.pieceLayer
+			}
+		}
+	}
+
+	if version&V1 != 0 {
+		if b.MD5Sum {
+			if err := hashMD5Sums(files, workers); err != nil {
+				return nil, err
+			}
+		}
+		if stat.IsDir() {
+			for _, f := range files {
+				info.Files = append(info.Files, File{Length: f.length, MD5Sum: f.md5sum, Path: f.path})
+				if f.pad > 0 {
+					info.Files = append(info.Files, File{Length: f.pad, Path: []string{".pad", strconv.FormatInt(f.pad, 10)}, Attr: "p"})
+				}
+			}
+		} else {
+			info.Length = files[0].length
+			info.MD5Sum = files[0].md5sum
+		}
+	}
+
+	m := MetaInfo{
+		Announce:     b.AnnounceList[0][0],
+		Comment:      b.Comment,
+		CreatedBy:    b.CreatedBy,
+		CreationDate: b.CreationDate,
+		Info:         info,
+		URLList:      b.URLList,
+		PieceLayers:  pieceLayers,
+	}
+	if len(b.AnnounceList) > 1 || len(b.AnnounceList[0]) > 1 {
+		m.AnnounceList = b.AnnounceList
+	}
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	parsed, err := Parse(bufio.NewReader(bytes.NewReader(buf.Bytes())))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := buf.WriteTo(w); err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
+
+// walkFiles lists the regular files below root in lexical order.
+func walkFiles(root string) ([]buildFile, error) {
+	var files []buildFile
+	err := filepath.WalkDir(root, func(osPath string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		stat, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, osPath)
+		if err != nil {
+			return err
+		}
+		files = append(files, buildFile{
+			osPath: osPath,
+			path:   strings.Split(filepath.ToSlash(rel), "/"),
+			length: stat.Size(),
+		})
+		return nil
+	})
+	return files, err
+}
+
+// autoPieceLength picks the smallest power of two between 16 KiB and 16 MiB
+// that splits total into at most targetPieces pieces.
+func autoPieceLength(total int64) int64 {
+	pieceLength := int64(minPieceLength)
+	for pieceLength < maxPieceLength && total > pieceLength*targetPieces {
+		pieceLength *= 2
+	}
+	return pieceLength
+}
+
+// progress reports the bytes hashed so far to a Builder's Progress callback.
+type progress struct {
+	mu     sync.Mutex
+	fn     func(hashed, total int64)
+	hashed int64
+	total  int64
+}
+
+func (p *progress) add(n int64) {
+	if p.fn == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.hashed += n
+	p.fn(p.hashed, p.total)
+}
+
+// hashParallel calls hash for every job below n on up to workers goroutines,
+// each with a buffer of bufSize bytes of its own, and returns the first error.
+func hashParallel(n, workers int, bufSize int64, hash func(job int, buf []byte) error) error {
+	jobs := make(chan int)
+	errs := make(chan error, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, n); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, bufSize)
+			for job := range jobs {
+				if err := hash(job, buf); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+
+	var err error
+feed:
+	for job := 0; job < n; job++ {
+		select {
+		case jobs <- job:
+		case err = <-errs:
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if err != nil {
+		return err
+	}
+	select {
+	case err := <-errs:
+		return err
+	default:
+		return nil
+	}
+}
+
+// hashPieces returns the SHA-1 hashes of the v1 pieces of files laid end to
+// end.
+func hashPieces(files []buildFile, pieceLength, total int64, workers int, p *progress) ([][20]byte, error) {
+	pieces := make([][20]byte, (total+pieceLength-1)/pieceLength)
+	r := filesReader{files: files}
+	err := hashParallel(len(pieces), workers, pieceLength, func(i int, buf []byte) error {
+		offset := int64(i) * pieceLength
+		piece := buf[:min(pieceLength, total-offset)]
+		if _, err := r.ReadAt(piece, offset); err != nil {
+			return err
+		}
+		pieces[i] = sha1.Sum(piece)
+		p.add(int64(len(piece)))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pieces, nil
+}
+
+// padFiles sets the padding needed after each file but the last to align the
+// next one to a piece boundary, and returns the number of v1 pieces.
+func padFiles(files []buildFile, pieceLength int64) int {
+	var total int64
+	for i := range files {
+		if rem := files[i].length % pieceLength; rem != 0 && i < len(files)-1 {
+			files[i].pad = pieceLength - rem
+		}
+		total += files[i].length + files[i].pad
+	}
+	return int((total + pieceLength - 1) / pieceLength)
+}
+
+// hashFileTrees sets the pieces root and piece layer of every non-empty file,
+// hashing each file's 16 KiB blocks with SHA-256 for its own v2 merkle tree.
+// When pieces is non-nil, it also sets the v1 piece hashes of files padded by
+// padFiles.
+func hashFileTrees(files []buildFile, pieceLength int64, workers int, p *progress, pieces [][20]byte) error {
+	type job struct {
+		file   int
+		offset int64
+		piece  int // index of the v1 piece
+	}
+	var jobs []job
+	leaves := make([][][32]byte, len(files))
+	for i, f := range files {
+		leaves[i] = make([][32]byte, (f.length+BlockSize-1)/BlockSize)
+		for offset := int64(0); offset < f.length; offset += pieceLength {
+			jobs = append(jobs, job{i, offset, len(jobs)})
+		}
+	}
+
+	err := hashParallel(len(jobs), workers, pieceLength, func(i int, buf []byte) error {
+		j := jobs[i]
+		f := files[j.file]
+		piece := buf[:min(pieceLength, f.length-j.offset)]
+		if _, err := readFileAt(f.osPath, piece, j.offset); err != nil {
+			return err
+		}
+		for block := 0; block < len(piece); block += BlockSize {
+			leaves[j.file][(j.offset+int64(block))/BlockSize] = sha256.Sum256(piece[block:min(block+BlockSize, len(piece))])
+		}
+		// Progress counts file bytes only, not the padding hashed with them.
+		p.add(int64(len(piece)))
+		if pieces != nil {
+			if j.offset+pieceLength >= f.length && f.pad > 0 {
+				piece = buf[:len(piece)+int(f.pad)]
+				clear(piece[len(piece)-int(f.pad):])
+			}
+			pieces[j.piece] = sha1.Sum(piece)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := range files {
+		if files[i].length > 0 {
+			files[i].piecesRoot, files[i].pieceLayer = merkleLayers(leaves[i], pieceLength)
+		}
+	}
+	return nil
+}
+
+// hashMD5Sums sets the md5sum of every file, hashing up to workers files at a
+// time.
+func hashMD5Sums(files []buildFile, workers int) error {
+	sem := make(chan struct{}, workers)
+	errs := make([]error, len(files))
+	var wg sync.WaitGroup
+	for i := range files {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			files[i].md5sum, errs[i] = md5File(files[i].osPath)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func md5File(name string) ([]byte, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// filesReader reads files laid end to end as one stream.
+type filesReader struct {
+	files []buildFile
+}
+
+// ReadAt fills p from offset in the concatenation of the files. Files that
+// have shrunk since they were listed are reported as io.ErrUnexpectedEOF.
+func (r filesReader) ReadAt(p []byte, offset int64) (int, error) {
+	n := 0
+	var start int64
+	for _, file := range r.files {
+		end := start + file.length
+		if pos := offset + int64(n); pos < end && n < len(p) {
+			m, err := readFileAt(file.osPath, p[n:min(int64(len(p)), int64(n)+end-pos)], pos-start)
+			n += m
+			if err != nil {
+				return n, err
+			}
+		}
+		start = end
+	}
+	if n < len(p) {
+		return n, io.ErrUnexpectedEOF
+	}
+	return n, nil
+}
+
+func readFileAt(name string, p []byte, offset int64) (int, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	n, err := f.ReadAt(p, offset)
+	if err == io.EOF {
+		err = fmt.Errorf("%s: %w", name, io.ErrUnexpectedEOF)
+	}
+	return n, err
+}
@@ -0,0 +1,207 @@
+package metainfo
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func writeTestFiles(t *testing.T, root string, files map[string][]byte) {
+	t.Helper()
+	for name, data := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func testData(seed, n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte((i*7 + seed) % 251)
+	}
+	return b
+}
+
+func pieceHashes(data []byte, pieceLength int) [][20]byte {
+	var pieces [][20]byte
+	for i := 0; i < len(data); i += pieceLength {
+		pieces = append(pieces, sha1.Sum(data[i:min(i+pieceLength, len(data))]))
+	}
+	return pieces
+}
+
+func TestBuilderMultiFile(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "content")
+	files := map[string][]byte{
+		"b.txt":         testData(1, 1000),
+		"a/z.bin":       testData(2, 70000),
+		"a/empty":       nil,
+		"c/d/e/f.dat":   testData(3, 50000),
+		"c/d/small.txt": []byte("hello"),
+	}
+	writeTestFiles(t, root, files)
+
+	var hashed []int64
+	b := Builder{
+		AnnounceList: [][]string{{"http://a/announce", "http://b/announce"}, {"udp://c:80"}},
+		Comment:      "comment",
+		CreatedBy:    "torrent test",
+		CreationDate: time.Unix(1700000000, 0),
+		PieceLength:  32 << 10,
+		Private:      true,
+		MD5Sum:       true,
+		Workers:      4,
+		Progress: func(n, total int64) {
+			hashed = append(hashed, n)
+		},
+	}
+	var buf bytes.Buffer
+	built, err := b.Build(&buf, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := Parse(bufio.NewReader(bytes.NewReader(buf.Bytes())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.InfoHash() != built.InfoHash() {
+		t.Errorf("expected returned torrent to match the output")
+	}
+	if m.Announce != "http://a/announce" {
+		t.Errorf("expected announce %q, got %q", "http://a/announce", m.Announce)
+	}
+	if !slices.EqualFunc(m.AnnounceList, b.AnnounceList, slices.Equal) {
+		t.Errorf("expected announce list %q, got %q", b.AnnounceList, m.AnnounceList)
+	}
+	if m.Comment != b.Comment || m.CreatedBy != b.CreatedBy || !m.CreationDate.Equal(b.CreationDate) {
+		t.Errorf("expected comment, created by and creation date to round trip, got %q %q %v", m.Comment, m.CreatedBy, m.CreationDate)
+	}
+	if m.Info.Name != "content" || m.Info.PieceLength != b.PieceLength || !m.Info.Private {
+		t.Errorf("expected name, piece length and private to round trip, got %v", m.Info)
+	}
+
+	order := []string{"a/empty", "a/z.bin", "b.txt", "c/d/e/f.dat", "c/d/small.txt"}
+	if len(m.Info.Files) != len(order) {
+		t.Fatalf("expected %d files, got %d", len(order), len(m.Info.Files))
+	}
+	var all []byte
+	for i, name := range order {
+		file := m.Info.Files[i]
+		osPath, err := file.OSPath()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if osPath != filepath.FromSlash(name) {
+			t.Errorf("file %d: expected %q, got %q", i, name, osPath)
+		}
+		if file.Length != int64(len(files[name])) {
+			t.Errorf("file %d: expected length %d, got %d", i, len(files[name]), file.Length)
+		}
+		if sum := md5.Sum(files[name]); !bytes.Equal(file.MD5Sum, sum[:]) {
+			t.Errorf("file %d: expected md5sum %x, got %x", i, sum, file.MD5Sum)
+		}
+		all = append(all, files[name]...)
+	}
+	if expected := pieceHashes(all, int(b.PieceLength)); !slices.Equal(m.Info.Pieces, expected) {
+		t.Errorf("expected %d piece hashes to match the content, got %d", len(expected), len(m.Info.Pieces))
+	}
+
+	if len(hashed) != len(m.Info.Pieces) || hashed[len(hashed)-1] != int64(len(all)) || !slices.IsSorted(hashed) {
+		t.Errorf("expected one increasing progress call per piece ending at %d, got %v", len(all), hashed)
+	}
+}
+
+func TestBuilderSingleFile(t *testing.T) {
+	dir := t.TempDir()
+	data := testData(4, 100000)
+	writeTestFiles(t, dir, map[string][]byte{"file.iso": data})
+
+	b := Builder{AnnounceList: [][]string{{"http://a/announce"}}}
+	var buf bytes.Buffer
+	if _, err := b.Build(&buf, filepath.Join(dir, "file.iso")); err != nil {
+		t.Fatal(err)
+	}
+	m, err := Parse(bufio.NewReader(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Info.Name != "file.iso" || m.Info.Length != int64(len(data)) || m.Info.Files != nil {
+		t.Errorf("expected a single file torrent, got %v", m.Info)
+	}
+	if m.AnnounceList != nil || m.Comment != "" || !m.CreationDate.IsZero() || m.Info.Private || m.Info.MD5Sum != nil {
+		t.Errorf("expected unset options to be omitted, got %v", m)
+	}
+	if m.Info.PieceLength != minPieceLength {
+		t.Errorf("expected piece length %d, got %d", minPieceLength, m.Info.PieceLength)
+	}
+	if expected := pieceHashes(data, minPieceLength); !slices.Equal(m.Info.Pieces, expected) {
+		t.Error("expected piece hashes to match the content")
+	}
+}
+
+func TestBuilderErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string][]byte{"full/a": []byte("a")})
+	if err := os.Mkdir(filepath.Join(dir, "empty"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		builder     Builder
+		root        string
+		expectedErr error
+	}{
+		{"no trackers", Builder{}, "full", ErrMissingField},
+		{"empty directory", Builder{AnnounceList: [][]string{{"a"}}}, "empty", ErrNoFiles},
+		{"missing root", Builder{AnnounceList: [][]string{{"a"}}}, "missing", os.ErrNotExist},
+		{"small piece length", Builder{AnnounceList: [][]string{{"a"}}, PieceLength: 1 << 10}, "full", ErrInvalidPieceLength},
+		{"odd piece length", Builder{AnnounceList: [][]string{{"a"}}, PieceLength: 20000}, "full", ErrInvalidPieceLength},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			_, err := test.builder.Build(&buf, filepath.Join(dir, test.root))
+			if !errors.Is(err, test.expectedErr) {
+				t.Errorf("expected error %v, got %v", test.expectedErr, err)
+			}
+			if buf.Len() != 0 {
+				t.Errorf("expected nothing written, got %d bytes", buf.Len())
+			}
+		})
+	}
+}
+
+func TestAutoPieceLength(t *testing.T) {
+	tests := []struct {
+		total    int64
+		expected int64
+	}{
+		{0, 16 << 10},
+		{1000, 16 << 10},
+		{1500 * 16 << 10, 16 << 10},
+		{1500*16<<10 + 1, 32 << 10},
+		{6203355136, 4 << 20},
+		{1 << 40, 16 << 20},
+	}
+
+	for _, test := range tests {
+		if pieceLength := autoPieceLength(test.total); pieceLength != test.expected {
+			t.Errorf("%d: expected %d, got %d", test.total, test.expected, pieceLength)
+		}
+	}
+}
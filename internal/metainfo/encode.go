@@ -0,0 +1,257 @@
+package metainfo
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/stupoid/torrent/internal/bencode"
+)
+
+// WriteTo writes m to w as a canonical bencoded torrent, including any keys
+// Parse did not recognise. While Info is unchanged since Parse, the info
+// dictionary is written exactly as it was read, so that rewriting the other
+// fields of a torrent never changes its info hash.
+func (m MetaInfo) WriteTo(w io.Writer) (int64, error) {
+	b, err := m.MarshalBencode()
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+// MarshalBencode returns the encoding written by WriteTo.
+func (m MetaInfo) MarshalBencode() ([]byte, error) {
+	dict := maps.Clone(m.Extra)
+	if dict == nil {
+		dict = make(map[string]interface{})
+	}
+	if m.Announce != "" {
+		dict["announce"] = m.Announce
+	}
+	if len(m.AnnounceList) > 0 {
+		dict["announce-list"] = m.AnnounceList
+	}
+	if m.Comment != "" {
+		dict["comment"] = m.Comment
+	}
+	if m.CreatedBy != "" {
+		dict["created by"] = m.CreatedBy
+	}
+	if !m.CreationDate.IsZero() {
+		dict["creation date"] = m.CreationDate.Unix()
+	}
+	if m.Encoding != "" {
+		dict["encoding"] = m.Encoding
+	}
+	if len(m.Nodes) > 0 {
+		nodes := make([]interface{}, len(m.Nodes))
+		for i, node := range m.Nodes {
+			nodes[i] = []interface{}{node.Host, int64(node.Port)}
+		}
+		dict["nodes"] = nodes
+	}
+	if m.Publisher != "" {
+		dict["publisher"] = m.Publisher
+	}
+	if m.PublisherURL != "" {
+		dict["publisher-url"] = m.PublisherURL
+	}
+	if len(m.URLList) > 0 {
+		dict["url-list"] = m.URLList
+	}
+	if len(m.HTTPSeeds) > 0 {
+		dict["httpseeds"] = m.HTTPSeeds
+	}
+	info, err := m.encodedInfo()
+	if err != nil {
+		return nil, err
+	}
+	dict["info"] = bencode.RawMessage(info)
+	if len(m.PieceLayers) > 0 {
+		layers := make(map[string]interface{}, len(m.PieceLayers))
+		for root, layer := range m.PieceLayers {
+			hashes := make([]byte, 0, len(layer)*sha256.Size)
+			for _, hash := range layer {
+				hashes = append(hashes, hash[:]...)
+			}
+			layers[string(root[:])] = hashes
+		}
+		dict["piece layers"] = layers
+	}
+	return bencode.Marshal(dict)
+}
+
+// encodedInfo returns the original encoding of the info dictionary if Info is
+// unchanged since Parse, and its canonical encoding otherwise.
+func (m MetaInfo) encodedInfo() ([]byte, error) {
+	b, err := m.Info.encode()
+	if err != nil {
+		return nil, err
+	}
+	if m.infoBytes != nil && sha1.Sum(b) == m.infoSum {
+		return m.infoBytes, nil
+	}
+	return b, nil
+}
+
+// MarshalBencode returns the canonical encoding of the info dictionary,
+// including any keys ParseInfo did not recognise. Multiple File Mode is used
+// when Files is non-nil. The v2 keys are written when MetaVersion is 2, and the
+// v1 keys unless the info is v2 only.
+func (i Info) MarshalBencode() ([]byte, error) {
+	return i.encode()
+}
+
+// encode returns the canonical encoding of i. It fails if an Extra map holds
+// a value that bencode cannot represent.
+func (i Info) encode() ([]byte, error) {
+	dict := maps.Clone(i.Extra)
+	if dict == nil {
+		dict = make(map[string]interface{})
+	}
+	dict["name"] = i.Name
+	dict["piece length"] = i.PieceLength
+	if i.Private {
+		dict["private"] = int64(1)
+	}
+	if i.Source != "" {
+		dict["source"] = i.Source
+	}
+	if i.Similar != nil {
+		similar := make([]interface{}, len(i.Similar))
+		for j, hash := range i.Similar {
+			similar[j] = hash[:]
+		}
+		dict["similar"] = similar
+	}
+	if len(i.Collections) > 0 {
+		dict["collections"] = i.Collections
+	}
+	if i.HasV2() {
+		dict["meta version"] = i.MetaVersion
+		dict["file tree"] = i.fileTree()
+	}
+	if !i.HasV1() {
+		return i.encodeDict(dict)
+	}
+
+	pieces := make([]byte, 0, len(i.Pieces)*sha1.Size)
+	for _, piece := range i.Pieces {
+		pieces = append(pieces, piece[:]...)
+	}
+	dict["pieces"] = pieces
+	if i.Files != nil {
+		files := make([]interface{}, len(i.Files))
+		for j, file := range i.Files {
+			files[j] = file.dict()
+		}
+		dict["files"] = files
+	} else {
+		dict["length"] = i.Length
+		if i.MD5Sum != nil {
+			dict["md5sum"] = hex.EncodeToString(i.MD5Sum)
+		}
+	}
+	return i.encodeDict(dict)
+}
+
+// encodeDict encodes a dictionary built from the fields of i. The typed fields
+// always encode, so a failure is reported as a *FieldError naming the
+// offending Extra value.
+func (i Info) encodeDict(dict map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := bencode.NewEncoder(&buf).EncodeDict(dict); err != nil {
+		if extraErr := i.checkExtra(); extraErr != nil {
+			return nil, extraErr
+		}
+		return nil, &FieldError{Path: "info", Err: fmt.Errorf("%w: %w", ErrInvalidField, err)}
+	}
+	return buf.Bytes(), nil
+}
+
+// checkExtra returns a *FieldError for the first value in the Extra maps of i
+// and its files that bencode cannot represent.
+func (i Info) checkExtra() error {
+	if err := checkExtra("info", i.Extra); err != nil {
+		return err
+	}
+	for j, file := range i.Files {
+		if err := checkExtra(fmt.Sprintf("info.files[%d]", j), file.Extra); err != nil {
+			return err
+		}
+	}
+	for _, file := range i.FileTree {
+		if err := checkExtra("info.file tree"+treePath(file.Path)+`[""]`, file.Extra); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkExtra returns a *FieldError for the first value of extra, in key
+// order, that bencode cannot represent. Keys are appended to path.
+func checkExtra(path string, extra map[string]interface{}) error {
+	for _, key := range slices.Sorted(maps.Keys(extra)) {
+		if _, err := bencode.Marshal(extra[key]); err != nil {
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+			return &FieldError{Path: keyPath, Err: fmt.Errorf("%w: %w", ErrInvalidField, err)}
+		}
+	}
+	return nil
+}
+
+// fileTree returns the nested BEP 52 file tree dictionary of i.FileTree.
+func (i Info) fileTree() map[string]interface{} {
+	tree := make(map[string]interface{})
+	for _, file := range i.FileTree {
+		dir := tree
+		for _, name := range file.Path[:len(file.Path)-1] {
+			child, ok := dir[name].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				dir[name] = child
+			}
+			dir = child
+		}
+
+		leaf := maps.Clone(file.Extra)
+		if leaf == nil {
+			leaf = make(map[string]interface{})
+		}
+		leaf["length"] = file.Length
+		if file.Attr != "" {
+			leaf["attr"] = file.Attr
+		}
+		if file.Length > 0 {
+			leaf["pieces root"] = file.PiecesRoot[:]
+		}
+		dir[file.Path[len(file.Path)-1]] = map[string]interface{}{"": leaf}
+	}
+	return tree
+}
+
+func (f File) dict() map[string]interface{} {
+	dict := maps.Clone(f.Extra)
+	if dict == nil {
+		dict = make(map[string]interface{})
+	}
+	dict["length"] = f.Length
+	dict["path"] = f.Path
+	if f.Attr != "" {
+		dict["attr"] = f.Attr
+	}
+	if f.MD5Sum != nil {
+		dict["md5sum"] = hex.EncodeToString(f.MD5Sum)
+	}
+	return dict
+}
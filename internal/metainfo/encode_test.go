@@ -0,0 +1,282 @@
+package metainfo
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stupoid/torrent/internal/bencode"
+)
+
+func parseString(t *testing.T, s string) *MetaInfo {
+	t.Helper()
+	m, err := Parse(bufio.NewReader(strings.NewReader(s)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func writeString(t *testing.T, m *MetaInfo) string {
+	t.Helper()
+	var buf bytes.Buffer
+	n, err := m.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("expected %d bytes written, got %d", buf.Len(), n)
+	}
+	return buf.String()
+}
+
+func TestWriteToRoundTrip(t *testing.T) {
+	for _, name := range []string{"test.torrent", "multi.torrent"} {
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("..", "..", "test", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			m := parseString(t, string(data))
+			if output := writeString(t, m); output != string(data) {
+				t.Error("expected WriteTo to reproduce the canonical input")
+			}
+		})
+	}
+}
+
+func TestWriteToKeepsUnknownKeys(t *testing.T) {
+	// The info dictionary is not canonical: its keys are unsorted and its
+	// length has a leading zero.
+	info := "d4:name1:a6:lengthi05e12:piece lengthi16384e6:pieces20:aaaaaaaaaaaaaaaaaaaa6:source3:srce"
+	input := "d8:announce5:http:7:x-extrali1ee4:info" + info + "e"
+	m := parseString(t, input)
+	infoHash := m.InfoHash()
+	if infoHash != sha1.Sum([]byte(info)) {
+		t.Fatalf("expected info hash of the original info dictionary, got %x", infoHash)
+	}
+
+	m.Announce = "udp://new:80"
+	m.AnnounceList = [][]string{{"udp://new:80"}, {"http://backup"}}
+	expected := "d8:announce12:udp://new:8013:announce-listll12:udp://new:80el13:http://backupee4:info" + info + "7:x-extrali1eee"
+	output := writeString(t, m)
+	if output != expected {
+		t.Errorf("expected %q, got %q", expected, output)
+	}
+	if reparsed := parseString(t, output); reparsed.InfoHash() != infoHash {
+		t.Errorf("expected info hash %x to be kept, got %x", infoHash, reparsed.InfoHash())
+	}
+
+	m.Info.Name = "b"
+	canonical := "d6:lengthi5e4:name1:b12:piece lengthi16384e6:pieces20:aaaaaaaaaaaaaaaaaaaa6:source3:srce"
+	if m.InfoHash() != sha1.Sum([]byte(canonical)) {
+		t.Errorf("expected info hash of the edited info dictionary")
+	}
+	if output := writeString(t, m); !strings.Contains(output, "4:info"+canonical) {
+		t.Errorf("expected canonical edited info dictionary in %q", output)
+	}
+}
+
+func TestWriteToKeepsUnknownFileKeys(t *testing.T) {
+	input := "d8:announce5:http:4:infod5:filesld4:attr1:x6:lengthi1e4:pathl1:aeee4:name1:d12:piece lengthi16384e6:pieces20:aaaaaaaaaaaaaaaaaaaaee"
+	m := parseString(t, input)
+	m.Info.Files[0].Path = []string{"b"}
+	expected := "d8:announce5:http:4:infod5:filesld4:attr1:x6:lengthi1e4:pathl1:beee4:name1:d12:piece lengthi16384e6:pieces20:aaaaaaaaaaaaaaaaaaaaee"
+	if output := writeString(t, m); output != expected {
+		t.Errorf("expected %q, got %q", expected, output)
+	}
+}
+
+func TestWriteToKeepsEmptyStrings(t *testing.T) {
+	input := "d8:announce0:7:comment0:4:infod6:lengthi0e4:name1:a12:piece lengthi1e6:pieces0:6:source0:ee"
+	m := parseString(t, input)
+	output := writeString(t, m)
+	if output != input {
+		t.Errorf("expected WriteTo to reproduce the input, got %q", output)
+	}
+	if _, err := Parse(bufio.NewReader(strings.NewReader(output))); err != nil {
+		t.Errorf("expected the output to parse, got %v", err)
+	}
+
+	m.Info.Name = "b"
+	if output := writeString(t, m); !strings.Contains(output, "6:source0:") {
+		t.Errorf("expected the empty source to be kept in the edited info, got %q", output)
+	}
+}
+
+func TestInfoMarshalBencodeKeepsPrivate(t *testing.T) {
+	for _, private := range []string{"7:privatei0e", "7:privatei1e"} {
+		info := "d6:lengthi0e4:name1:a12:piece lengthi1e6:pieces0:" + private + "e"
+		m := parseString(t, "d8:announce1:a4:info"+info+"e")
+		output, err := m.Info.MarshalBencode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(output) != info {
+			t.Errorf("expected %q, got %q", info, output)
+		}
+
+		m.Info.Source = "src"
+		if output, _ := m.Info.MarshalBencode(); !strings.Contains(string(output), private) {
+			t.Errorf("expected %q to be kept in the edited info, got %q", private, output)
+		}
+	}
+}
+
+func TestInfoMarshalBencode(t *testing.T) {
+	tests := []struct {
+		name     string
+		info     Info
+		expected string
+	}{
+		{"single file", Info{Name: "a", PieceLength: 16384, Length: 0, MD5Sum: []byte{0xab}},
+			"d6:lengthi0e6:md5sum2:ab4:name1:a12:piece lengthi16384e6:pieces0:e"},
+		{"multiple files", Info{Name: "d", PieceLength: 16384, Private: true, Pieces: [][20]byte{{}}, Files: []File{{Length: 2, Path: []string{"x", "y"}}}},
+			"d5:filesld6:lengthi2e4:pathl1:x1:yeee4:name1:d12:piece lengthi16384e6:pieces20:" + strings.Repeat("\x00", 20) + "7:privatei1ee"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, err := test.info.MarshalBencode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(output) != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, output)
+			}
+		})
+	}
+}
+
+func TestMetaInfoInfoHashWithoutParse(t *testing.T) {
+	m := MetaInfo{Announce: "http:", Info: Info{Name: "a", PieceLength: 16384}}
+	if m.InfoHash() != sha1.Sum([]byte("d6:lengthi0e4:name1:a12:piece lengthi16384e6:pieces0:e")) {
+		t.Error("expected info hash of the canonical info dictionary")
+	}
+}
+
+func TestUnencodableExtra(t *testing.T) {
+	m := parseString(t, "d8:announce1:a4:infod6:lengthi0e4:name1:a12:piece lengthi16384e6:pieces0:ee")
+	m.Extra["x-top"] = 1.5
+	if _, err := m.WriteTo(io.Discard); !errors.Is(err, bencode.ErrInvalidType) {
+		t.Errorf("expected ErrInvalidType for an unencodable top-level extra, got %v", err)
+	}
+	if !hasFinding(m.Validate(), SeverityError, "x-top", ErrInvalidField) {
+		t.Errorf("expected an error finding for x-top, got %v", m.Validate())
+	}
+	delete(m.Extra, "x-top")
+
+	m.Info.Extra["x"] = 1.5
+	var fieldErr *FieldError
+	if _, _, err := m.InfoHashes(); !errors.As(err, &fieldErr) || fieldErr.Path != "info.x" || !errors.Is(err, ErrInvalidField) {
+		t.Errorf("expected a FieldError for info.x, got %v", err)
+	}
+	if m.InfoHash() != ([20]byte{}) || m.InfoHashV2() != ([32]byte{}) {
+		t.Error("expected zero info hashes")
+	}
+	if _, err := m.WriteTo(io.Discard); !errors.Is(err, ErrInvalidField) {
+		t.Errorf("expected WriteTo to fail with ErrInvalidField, got %v", err)
+	}
+	if _, err := m.Info.MarshalBencode(); !errors.Is(err, ErrInvalidField) {
+		t.Errorf("expected MarshalBencode to fail with ErrInvalidField, got %v", err)
+	}
+	if report := m.Validate(); !hasFinding(report, SeverityError, "info.x", ErrInvalidField) {
+		t.Errorf("expected an error finding for info.x, got %v", report)
+	}
+}
+
+func TestExtensionFields(t *testing.T) {
+	info := "d11:collectionsl2:c1e5:filesld6:lengthi0e4:pathl1:ae6:x-filei7eee4:name1:t12:piece lengthi1e6:pieces0:" +
+		"7:similarl20:bbbbbbbbbbbbbbbbbbbbe6:source3:src6:x-infoi5ee"
+	input := "d8:announce1:a4:info" + info + "5:nodesll9:127.0.0.1i6881eel4:dht1i1eee" +
+		"9:publisher3:pub13:publisher-url9:http://p/8:x-customl1:xee"
+	m := parseString(t, input)
+
+	expectedNodes := []Node{{"127.0.0.1", 6881}, {"dht1", 1}}
+	if !slices.Equal(m.Nodes, expectedNodes) {
+		t.Errorf("expected nodes %v, got %v", expectedNodes, m.Nodes)
+	}
+	if m.Publisher != "pub" || m.PublisherURL != "http://p/" {
+		t.Errorf("expected publisher %q and %q, got %q and %q", "pub", "http://p/", m.Publisher, m.PublisherURL)
+	}
+	if m.Info.Source != "src" {
+		t.Errorf("expected source %q, got %q", "src", m.Info.Source)
+	}
+	if len(m.Info.Similar) != 1 || m.Info.Similar[0] != [20]byte([]byte(strings.Repeat("b", 20))) {
+		t.Errorf("expected one similar torrent, got %x", m.Info.Similar)
+	}
+	if !slices.Equal(m.Info.Collections, []string{"c1"}) {
+		t.Errorf("expected collections %q, got %q", []string{"c1"}, m.Info.Collections)
+	}
+
+	expectedExtras := []struct {
+		name     string
+		extra    map[string]interface{}
+		expected map[string]interface{}
+	}{
+		{"torrent", m.Extra, map[string]interface{}{"x-custom": []interface{}{"x"}}},
+		{"info", m.Info.Extra, map[string]interface{}{"x-info": int64(5)}},
+		{"file", m.Info.Files[0].Extra, map[string]interface{}{"x-file": int64(7)}},
+	}
+	for _, test := range expectedExtras {
+		if !reflect.DeepEqual(test.extra, test.expected) {
+			t.Errorf("%s: expected extras %v, got %v", test.name, test.expected, test.extra)
+		}
+	}
+
+	if output := writeString(t, m); output != input {
+		t.Errorf("expected WriteTo to reproduce the input, got %q", output)
+	}
+
+	// Extras are written back as edited, and the typed fields are part of the
+	// info hash.
+	infoHash := m.InfoHash()
+	delete(m.Extra, "x-custom")
+	m.Extra["x-new"] = "y"
+	if output := writeString(t, m); !strings.HasSuffix(output, "5:x-new1:ye") || strings.Contains(output, "x-custom") {
+		t.Errorf("expected the edited extras in %q", output)
+	}
+	if m.InfoHash() != infoHash {
+		t.Error("expected top-level extras not to change the info hash")
+	}
+	m.Info.Source = "other"
+	if m.InfoHash() == infoHash {
+		t.Error("expected a new source to change the info hash")
+	}
+}
+
+func TestMalformedExtensionFields(t *testing.T) {
+	info := "d11:collectionsi1e6:lengthi0e4:name1:a12:piece lengthi1e6:pieces0:7:similarl1:xee"
+	input := "d8:announce1:a4:info" + info + "5:nodesll1:hi70000eel4:dht1i1eeee"
+	m := parseString(t, input)
+
+	if m.Nodes != nil || m.Info.Similar != nil || m.Info.Collections != nil {
+		t.Errorf("expected no nodes, similar or collections, got %v, %x and %q", m.Nodes, m.Info.Similar, m.Info.Collections)
+	}
+	expectedExtras := []struct {
+		name     string
+		extra    map[string]interface{}
+		expected map[string]interface{}
+	}{
+		{"torrent", m.Extra, map[string]interface{}{"nodes": []interface{}{
+			[]interface{}{"h", int64(70000)}, []interface{}{"dht1", int64(1)},
+		}}},
+		{"info", m.Info.Extra, map[string]interface{}{"collections": int64(1), "similar": []interface{}{"x"}}},
+	}
+	for _, test := range expectedExtras {
+		if !reflect.DeepEqual(test.extra, test.expected) {
+			t.Errorf("%s: expected extras %v, got %v", test.name, test.expected, test.extra)
+		}
+	}
+	if output := writeString(t, m); output != input {
+		t.Errorf("expected WriteTo to reproduce the input, got %q", output)
+	}
+}
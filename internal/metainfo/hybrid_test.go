@@ -0,0 +1,179 @@
+package metainfo
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func buildHybrid(t *testing.T, files map[string][]byte, pieceLength int64) (*MetaInfo, []byte) {
+	t.Helper()
+	root := filepath.Join(t.TempDir(), "hybrid")
+	writeTestFiles(t, root, files)
+	b := Builder{AnnounceList: [][]string{{"http://a/announce"}}, PieceLength: pieceLength, Version: Hybrid, MD5Sum: true}
+	var buf bytes.Buffer
+	m, err := b.Build(&buf, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, buf.Bytes()
+}
+
+func TestBuilderHybrid(t *testing.T) {
+	const pieceLength = 16 << 10
+	files := map[string][]byte{
+		"a.bin":   testData(1, 2*pieceLength+100),
+		"b/empty": nil,
+		"b/c.bin": testData(2, pieceLength),
+		"d.txt":   testData(3, 10),
+	}
+	m, data := buildHybrid(t, files, pieceLength)
+
+	if !m.Info.IsHybrid() {
+		t.Fatalf("expected a hybrid torrent, got %v", m.Info)
+	}
+
+	expectedFiles := []struct {
+		path    string
+		length  int64
+		padding bool
+	}{
+		{"a.bin", 2*pieceLength + 100, false},
+		{".pad/" + "16284", pieceLength - 100, true},
+		{"b/c.bin", pieceLength, false},
+		{"b/empty", 0, false},
+		{"d.txt", 10, false},
+	}
+	if len(m.Info.Files) != len(expectedFiles) {
+		t.Fatalf("expected %d v1 files, got %v", len(expectedFiles), m.Info.Files)
+	}
+	var all []byte
+	for i, expected := range expectedFiles {
+		file := m.Info.Files[i]
+		if strings.Join(file.Path, "/") != expected.path || file.Length != expected.length || file.IsPadding() != expected.padding {
+			t.Errorf("file %d: expected %s of length %d, got %v", i, expected.path, expected.length, file)
+		}
+		if expected.padding {
+			all = append(all, make([]byte, expected.length)...)
+		} else {
+			all = append(all, files[expected.path]...)
+		}
+	}
+	if expected := pieceHashes(all, pieceLength); !slices.Equal(m.Info.Pieces, expected) {
+		t.Errorf("expected %d v1 piece hashes over the padded files, got %d", len(expected), len(m.Info.Pieces))
+	}
+
+	if len(m.Info.FileTree) != 4 {
+		t.Fatalf("expected 4 files in the file tree, got %v", m.Info.FileTree)
+	}
+	for _, file := range m.Info.FileTree {
+		if content := files[strings.Join(file.Path, "/")]; len(content) > 0 && file.PiecesRoot != naiveRoot(content) {
+			t.Errorf("expected pieces root of %q to match its content", file.Path)
+		}
+	}
+	if err := m.VerifyPieceLayers(); err != nil {
+		t.Error(err)
+	}
+
+	start := bytes.Index(data, []byte("4:infod")) + len("4:info")
+	end := bytes.Index(data, []byte("12:piece layers"))
+	info := data[start:end]
+	if m.InfoHash() != sha1.Sum(info) {
+		t.Error("expected the v1 info hash to be the SHA-1 of the info dictionary")
+	}
+	if m.InfoHashV2() != sha256.Sum256(info) {
+		t.Error("expected the v2 info hash to be the SHA-256 of the info dictionary")
+	}
+}
+
+func TestBuilderHybridSingleFile(t *testing.T) {
+	dir := t.TempDir()
+	data := testData(4, 40000)
+	writeTestFiles(t, dir, map[string][]byte{"file.iso": data})
+	b := Builder{AnnounceList: [][]string{{"http://a/announce"}}, Version: Hybrid}
+	m, err := b.Build(&bytes.Buffer{}, filepath.Join(dir, "file.iso"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.Info.IsHybrid() || m.Info.Files != nil || m.Info.Length != int64(len(data)) {
+		t.Fatalf("expected a single file hybrid torrent, got %v", m.Info)
+	}
+	if !slices.Equal(m.Info.Pieces, pieceHashes(data, minPieceLength)) {
+		t.Error("expected v1 piece hashes to match the content")
+	}
+}
+
+func TestBuilderHybridProgress(t *testing.T) {
+	const pieceLength = 16 << 10
+	root := filepath.Join(t.TempDir(), "hybrid")
+	writeTestFiles(t, root, map[string][]byte{
+		"a.bin": testData(1, pieceLength+100),
+		"b.bin": testData(2, 10),
+	})
+	var last, lastTotal int64
+	b := Builder{
+		AnnounceList: [][]string{{"http://a/announce"}},
+		PieceLength:  pieceLength,
+		Version:      Hybrid,
+		Workers:      1,
+		Progress: func(hashed, total int64) {
+			last, lastTotal = hashed, total
+		},
+	}
+	if _, err := b.Build(&bytes.Buffer{}, root); err != nil {
+		t.Fatal(err)
+	}
+	if total := int64(pieceLength + 110); last != total || lastTotal != total {
+		t.Errorf("expected progress to end at %d of %d, got %d of %d", total, total, last, lastTotal)
+	}
+}
+
+func TestParseHybridMismatch(t *testing.T) {
+	const pieceLength = 16 << 10
+	files := map[string][]byte{
+		"a.bin": testData(1, pieceLength+1),
+		"b.bin": testData(2, 5),
+	}
+
+	tests := []struct {
+		name         string
+		modify       func(info *Info)
+		expectedPath string
+	}{
+		{"length", func(info *Info) { info.Files[2].Length++ }, "info.files[2]"},
+		{"path", func(info *Info) { info.Files[0].Path = []string{"x.bin"} }, "info.files[0]"},
+		{"unaligned", func(info *Info) { info.Files = slices.Delete(info.Files, 1, 2) }, "info.files[1]"},
+		{"extra v1 file", func(info *Info) {
+			info.Files = append(info.Files, File{Length: 1, Path: []string{"c"}})
+		}, "info.files[3]"},
+		{"missing v1 file", func(info *Info) { info.Files = info.Files[:2] }, "info.file tree"},
+		{"order", func(info *Info) {
+			info.Files[0], info.Files[2] = info.Files[2], info.Files[0]
+		}, "info.files[0]"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m, _ := buildHybrid(t, files, pieceLength)
+			test.modify(&m.Info)
+			var buf bytes.Buffer
+			if _, err := m.WriteTo(&buf); err != nil {
+				t.Fatal(err)
+			}
+			_, err := Parse(bufio.NewReader(&buf))
+			if !errors.Is(err, ErrFileListMismatch) {
+				t.Fatalf("expected error %v, got %v", ErrFileListMismatch, err)
+			}
+			var fieldErr *FieldError
+			if !errors.As(err, &fieldErr) || fieldErr.Path != test.expectedPath {
+				t.Errorf("expected path %q, got %v", test.expectedPath, err)
+			}
+		})
+	}
+}
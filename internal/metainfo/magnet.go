@@ -0,0 +1,55 @@
+package metainfo
+
+import (
+	"slices"
+
+	"github.com/stupoid/torrent/internal/magnet"
+)
+
+// Magnet returns a magnet link for m. It carries the v1 info hash, the v2 info
+// hash or both depending on the torrent's versions, along with its name, total
+// length, trackers in announce list order and web seeds. It returns the error
+// of InfoHashes if the info dictionary cannot be encoded.
+func (m MetaInfo) Magnet() (magnet.Magnet, error) {
+	v1, v2, err := m.InfoHashes()
+	if err != nil {
+		return magnet.Magnet{}, err
+	}
+	link := magnet.Magnet{
+		DisplayName: m.Info.Name,
+		Length:      m.Info.contentLength(),
+		WebSeeds:    slices.Clone(m.URLList),
+	}
+	if m.Info.HasV1() {
+		link.InfoHash = v1
+	}
+	if m.Info.HasV2() {
+		link.InfoHashV2 = v2
+	}
+	for _, tier := range m.AnnounceList {
+		link.Trackers = append(link.Trackers, tier...)
+	}
+	if len(link.Trackers) == 0 && m.Announce != "" {
+		link.Trackers = []string{m.Announce}
+	}
+	return link, nil
+}
+
+// contentLength returns the total length of the files of i, not counting
+// padding files.
+func (i Info) contentLength() int64 {
+	files := i.Files
+	switch {
+	case !i.HasV1():
+		files = i.FileTree
+	case files == nil:
+		return i.Length
+	}
+	var total int64
+	for _, file := range files {
+		if !file.IsPadding() {
+			total += file.Length
+		}
+	}
+	return total
+}
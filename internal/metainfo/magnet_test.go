@@ -0,0 +1,86 @@
+package metainfo
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestMetaInfoMagnet(t *testing.T) {
+	f, err := os.Open(filepath.Join("..", "..", "test", "multi.torrent"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	m, err := Parse(bufio.NewReader(f))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	link, err := m.Magnet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.InfoHash != m.InfoHash() || link.HasV2() {
+		t.Errorf("expected only the v1 info hash %x, got %x and %x", m.InfoHash(), link.InfoHash, link.InfoHashV2)
+	}
+	if link.DisplayName != "multi" {
+		t.Errorf("expected %q, got %q", "multi", link.DisplayName)
+	}
+	if link.Length != 121000 {
+		t.Errorf("expected length %d, got %d", 121000, link.Length)
+	}
+	var expectedTrackers []string
+	for _, tier := range m.AnnounceList {
+		expectedTrackers = append(expectedTrackers, tier...)
+	}
+	if !slices.Equal(link.Trackers, expectedTrackers) {
+		t.Errorf("expected %q, got %q", expectedTrackers, link.Trackers)
+	}
+}
+
+func TestMetaInfoMagnetVersions(t *testing.T) {
+	const pieceLength = 16 << 10
+	files := map[string][]byte{
+		"a.bin": testData(1, pieceLength+100),
+		"b.txt": testData(2, 10),
+	}
+
+	v2, _ := buildV2(t, files, pieceLength)
+	link, err := v2.Magnet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.HasV1() || link.InfoHashV2 != v2.InfoHashV2() {
+		t.Errorf("expected only the v2 info hash %x, got %x and %x", v2.InfoHashV2(), link.InfoHash, link.InfoHashV2)
+	}
+	if link.Length != pieceLength+110 {
+		t.Errorf("expected length %d, got %d", pieceLength+110, link.Length)
+	}
+
+	hybrid, _ := buildHybrid(t, files, pieceLength)
+	link, err = hybrid.Magnet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.InfoHash != hybrid.InfoHash() || link.InfoHashV2 != hybrid.InfoHashV2() {
+		t.Errorf("expected both info hashes, got %x and %x", link.InfoHash, link.InfoHashV2)
+	}
+	if link.Length != pieceLength+110 {
+		t.Errorf("expected padding files not to count, got length %d", link.Length)
+	}
+	if !slices.Equal(link.Trackers, []string{"http://a/announce"}) {
+		t.Errorf("expected the announce list, got %q", link.Trackers)
+	}
+}
+
+func TestMetaInfoMagnetUnencodable(t *testing.T) {
+	m := parseString(t, "d8:announce1:a4:infod6:lengthi0e4:name1:a12:piece lengthi16384e6:pieces0:ee")
+	m.Info.Extra["x"] = 1.5
+	if _, err := m.Magnet(); !errors.Is(err, ErrInvalidField) {
+		t.Errorf("expected ErrInvalidField, got %v", err)
+	}
+}
@@ -2,14 +2,47 @@ package metainfo
 
 import (
 	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/stupoid/torrent/internal/bencode"
 )
 
+var (
+	ErrMissingField = errors.New("missing field")
+	ErrInvalidField = errors.New("invalid field")
+	ErrNoFiles      = errors.New("missing any file definition")
+	ErrUnsafePath   = errors.New("unsafe file path")
+
+	ErrPieceCountMismatch = errors.New("piece count does not match total length")
+)
+
+// FieldError reports a missing or malformed field of a torrent, identified by
+// its key path such as info.files[3].length.
+type FieldError struct {
+	Path string
+	Err  error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
 type MetaInfo struct {
 	Announce     string
 	AnnounceList [][]string
@@ -18,6 +51,65 @@ type MetaInfo struct {
 	CreationDate time.Time
 	Encoding     string
 	Info         Info
+
+	// URLList holds the BEP 19 web seeds, plain HTTP or FTP servers hosting
+	// the torrent's files, and HTTPSeeds the BEP 17 HTTP seeds.
+	URLList   []string
+	HTTPSeeds []string
+
+	// PieceLayers maps the pieces root of each file in Info.FileTree larger
+	// than a piece to the hashes of its pieces (BEP 52).
+	PieceLayers map[[32]byte][][32]byte
+
+	// Nodes lists DHT nodes to bootstrap from, for trackerless torrents
+	// (BEP 5).
+	Nodes []Node
+
+	Publisher    string
+	PublisherURL string
+
+	// Extra holds the keys Parse did not recognise, along with known keys
+	// whose values could not be parsed, as decoded by bencode.Unmarshal.
+	// WriteTo writes them back, so values must be integers, strings, byte
+	// slices, or lists and dictionaries of those; WriteTo fails on any other
+	// value, and Validate reports it. The known fields take precedence over
+	// keys of the same name.
+	Extra map[string]interface{}
+
+	// infoBytes is the info dictionary as it was encoded in the parsed torrent,
+	// and infoSum the hash of the canonical encoding of Info as parsed, which
+	// tells whether Info has been modified since. infoHash and infoHashV2 are
+	// the hashes of infoBytes.
+	infoBytes  bencode.RawMessage
+	infoSum    [20]byte
+	infoHash   [20]byte
+	infoHashV2 [32]byte
+}
+
+// InfoHash returns the SHA-1 hash of the info dictionary as WriteTo encodes it:
+// exactly as it was encoded in the parsed torrent while Info is unchanged. It
+// returns the zero hash if an Extra map of Info holds a value that cannot be
+// encoded, which InfoHashes reports as an error.
+//
+// Telling whether Info changed takes encoding and hashing it on every call,
+// so callers needing the hash often should keep it.
+func (m MetaInfo) InfoHash() [20]byte {
+	hash, _, _ := m.InfoHashes()
+	return hash
+}
+
+// InfoHashes returns both InfoHash and InfoHashV2, or the error encoding the
+// info dictionary. It costs a single encoding of Info, and the hashes of the
+// parsed torrent are reused while Info is unchanged.
+func (m MetaInfo) InfoHashes() ([20]byte, [32]byte, error) {
+	b, err := m.Info.encode()
+	if err != nil {
+		return [20]byte{}, [32]byte{}, err
+	}
+	if m.infoBytes != nil && sha1.Sum(b) == m.infoSum {
+		return m.infoHash, m.infoHashV2, nil
+	}
+	return sha1.Sum(b), sha256.Sum256(b), nil
 }
 
 func (m MetaInfo) String() string {
@@ -37,158 +129,515 @@ type Info struct {
 
 	// Only present in Multiple File Mode
 	Files []File
+
+	// Only present in v2 torrents (BEP 52). FileTree lists the files of the
+	// file tree depth first in key order, which is the order of their data.
+	MetaVersion int64
+	FileTree    []File
+
+	// Source names the tracker or site a torrent was created for. Private
+	// trackers use it so that the same files get a different info hash on
+	// each of them.
+	Source string
+
+	// Similar holds the info hashes of torrents sharing files with this one,
+	// and Collections the names of the collections it belongs to (BEP 38).
+	Similar     [][20]byte
+	Collections []string
+
+	// Extra holds the keys of the info dictionary that ParseInfo did not
+	// recognise, like MetaInfo.Extra. Changing it changes the info hash.
+	Extra map[string]interface{}
 }
 
 func (i Info) String() string {
-	return fmt.Sprintf("Info{PieceLength: %d, Private: %t, Name: %s, Length: %d, MD5Sum: %x, Files: %v}", i.PieceLength, i.Private, i.Name, i.Length, i.MD5Sum, i.Files)
+	return fmt.Sprintf("Info{PieceLength: %d, Private: %t, Name: %s, Length: %d, MD5Sum: %x, Files: %v, MetaVersion: %d, FileTree: %v}", i.PieceLength, i.Private, i.Name, i.Length, i.MD5Sum, i.Files, i.MetaVersion, i.FileTree)
 }
 
 type File struct {
 	Length int64
 	MD5Sum []byte
-	Path   string
+	Path   []string // Path components relative to the directory named by Info.Name
+
+	// PiecesRoot is the merkle root of a non-empty file in Info.FileTree, and
+	// zero otherwise.
+	PiecesRoot [32]byte
+
+	// Attr holds the BEP 47 file attributes, such as "p" for a padding file.
+	Attr string
+
+	// Extra holds the keys of the file's dictionary that were not
+	// recognised, like MetaInfo.Extra.
+	Extra map[string]interface{}
+}
+
+// Node is the address of a DHT node listed in a torrent.
+type Node struct {
+	Host string
+	Port int
+}
+
+func (n Node) String() string {
+	return net.JoinHostPort(n.Host, strconv.Itoa(n.Port))
+}
+
+// IsPadding reports whether f is a BEP 47 padding file, which holds zeros to
+// align the next file to a piece boundary.
+func (f File) IsPadding() bool {
+	return strings.ContainsRune(f.Attr, 'p')
 }
 
 func (f File) String() string {
-	return fmt.Sprintf("File{Length: %d, MD5Sum: %x, Path: %s}", f.Length, f.MD5Sum, f.Path)
+	return fmt.Sprintf("File{Length: %d, MD5Sum: %x, Path: %q}", f.Length, f.MD5Sum, f.Path)
 }
 
+// OSPath returns the path of f relative to the torrent's directory, joined with
+// the OS path separator. It returns ErrUnsafePath if the path is empty, or if
+// any component is empty, "." or "..", contains a path separator, or is not a
+// valid local file name on this OS, so the result never escapes the directory.
+func (f File) OSPath() (string, error) {
+	if len(f.Path) == 0 {
+		return "", ErrUnsafePath
+	}
+	for _, component := range f.Path {
+		if component == "" || component == "." || component == ".." ||
+			strings.ContainsRune(component, '/') || strings.ContainsRune(component, filepath.Separator) {
+			return "", fmt.Errorf("%w: %q", ErrUnsafePath, f.Path)
+		}
+	}
+	path := filepath.Join(f.Path...)
+	if !filepath.IsLocal(path) {
+		return "", fmt.Errorf("%w: %q", ErrUnsafePath, f.Path)
+	}
+	return path, nil
+}
+
+// maxTorrentSize is the largest torrent Parse reads when bencode.DefaultLimits
+// sets no MaxBytes.
+const maxTorrentSize = 64 << 20
+
+// Parse reads and parses a torrent, decoding it with bencode.DefaultLimits.
+// A torrent larger than their MaxBytes, or than 64 MiB if they set none, fails
+// with bencode.ErrInputTooLarge without being read in full.
 func Parse(r *bufio.Reader) (*MetaInfo, error) {
-	decoder := bencode.NewDecoder(r)
-	dict, err := decoder.DecodeDict()
+	limits := bencode.DefaultLimits
+	if limits.MaxBytes <= 0 {
+		limits.MaxBytes = maxTorrentSize
+	}
+	return ParseWithLimits(r, limits)
+}
+
+// ParseWithLimits is like Parse, but decodes the torrent with limits. When
+// limits.MaxBytes is set, no more than that is read from r.
+func ParseWithLimits(r *bufio.Reader, limits bencode.Limits) (*MetaInfo, error) {
+	var src io.Reader = r
+	if limits.MaxBytes > 0 {
+		src = io.LimitReader(r, limits.MaxBytes+1)
+	}
+	data, err := io.ReadAll(src)
 	if err != nil {
 		return nil, err
 	}
+	return parse(data, limits, nil)
+}
 
-	metaInfo := MetaInfo{}
-
-	if _, ok := dict["announce"]; !ok {
-		return nil, errors.New("missing announce key")
+// parse parses a torrent. When report is not nil, problems that Validate also
+// checks for are added to report instead of failing the parse.
+func parse(data []byte, limits bencode.Limits, report *Report) (*MetaInfo, error) {
+	// The top-level values are decoded together with their encoding, for the
+	// info dictionary to be hashed as it was encoded.
+	var raw map[string]bencode.RawValue
+	if err := limits.Unmarshal(data, &raw); err != nil {
+		return nil, err
 	}
-	announce, ok := dict["announce"].(string)
-	if !ok {
-		return nil, errors.New("invalid announce")
+	dict := make(map[string]interface{}, len(raw))
+	for key, value := range raw {
+		dict[key] = value.Value
 	}
-	metaInfo.Announce = announce
 
+	metaInfo := MetaInfo{Extra: maps.Clone(dict), infoBytes: bytes.Clone(raw["info"].Raw)}
+
+	// A malformed announce-list is kept in Extra, where Validate reports it,
+	// and the torrent falls back to announce.
 	if announceList, ok := dict["announce-list"]; ok {
-		announceList, ok := announceList.([][]string)
-		if ok {
-			metaInfo.AnnounceList = announceList
+		if tiers, err := parseAnnounceList(announceList); err == nil {
+			metaInfo.AnnounceList = tiers
+			delete(metaInfo.Extra, "announce-list")
+		}
+	}
+
+	// Empty strings are left in Extra rather than consumed, since WriteTo
+	// leaves out the empty string fields and would drop them otherwise.
+	announce, hasAnnounce := dict["announce"]
+	if hasAnnounce {
+		announce, ok := announce.(string)
+		if !ok {
+			return nil, &FieldError{Path: "announce", Err: ErrInvalidField}
+		}
+		if announce != "" {
+			metaInfo.Announce = announce
+			delete(metaInfo.Extra, "announce")
 		}
 	}
 
 	if comment, ok := dict["comment"]; ok {
-		if comment, ok := comment.(string); ok {
+		if comment, ok := comment.(string); ok && comment != "" {
 			metaInfo.Comment = comment
+			delete(metaInfo.Extra, "comment")
 		}
 	}
 
 	if createdBy, ok := dict["created by"]; ok {
-		if createdBy, ok := createdBy.(string); ok {
+		if createdBy, ok := createdBy.(string); ok && createdBy != "" {
 			metaInfo.CreatedBy = createdBy
+			delete(metaInfo.Extra, "created by")
 		}
 	}
 
 	if creationDate, ok := dict["creation date"]; ok {
 		if creationDate, ok := creationDate.(int64); ok {
 			metaInfo.CreationDate = time.Unix(creationDate, 0)
+			delete(metaInfo.Extra, "creation date")
 		}
 	}
 
 	if encoding, ok := dict["encoding"]; ok {
-		if encoding, ok := encoding.(string); ok {
+		if encoding, ok := encoding.(string); ok && encoding != "" {
 			metaInfo.Encoding = encoding
+			delete(metaInfo.Extra, "encoding")
+		}
+	}
+
+	if publisher, ok := dict["publisher"].(string); ok && publisher != "" {
+		metaInfo.Publisher = publisher
+		delete(metaInfo.Extra, "publisher")
+	}
+
+	if publisherURL, ok := dict["publisher-url"].(string); ok && publisherURL != "" {
+		metaInfo.PublisherURL = publisherURL
+		delete(metaInfo.Extra, "publisher-url")
+	}
+
+	if nodes, ok := parseNodes(dict["nodes"]); ok {
+		metaInfo.Nodes = nodes
+		delete(metaInfo.Extra, "nodes")
+	}
+
+	// Announce may be left out when an announce-list is present, since BEP 12
+	// clients ignore it in that case, and in trackerless torrents listing DHT
+	// nodes instead.
+	if !hasAnnounce && len(metaInfo.AnnounceList) == 0 && len(metaInfo.Nodes) == 0 {
+		return nil, &FieldError{Path: "announce", Err: ErrMissingField}
+	}
+
+	// Malformed web seeds are kept in Extra, where Validate reports them.
+	if urlList, ok := dict["url-list"]; ok {
+		if urls, err := parseURLList(urlList); err == nil {
+			metaInfo.URLList = urls
+			delete(metaInfo.Extra, "url-list")
+		}
+	}
+
+	if httpSeeds, ok := dict["httpseeds"]; ok {
+		if urls, err := parseStrings("httpseeds", httpSeeds); err == nil {
+			metaInfo.HTTPSeeds = urls
+			delete(metaInfo.Extra, "httpseeds")
 		}
 	}
 
 	dictInfo, ok := dict["info"].(map[string]interface{})
 	if !ok {
-		return nil, errors.New("missing info key")
+		return nil, &FieldError{Path: "info", Err: ErrMissingField}
 	}
-	info, err := ParseInfo(dictInfo)
+	info, err := parseInfo(dictInfo, report)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse info: %w", err)
+		return nil, err
 	}
 	metaInfo.Info = info
+	delete(metaInfo.Extra, "info")
+
+	if pieceLayers, ok := dict["piece layers"]; ok {
+		layers, err := parsePieceLayers(pieceLayers)
+		if err != nil {
+			return nil, err
+		}
+		metaInfo.PieceLayers = layers
+		delete(metaInfo.Extra, "piece layers")
+	}
+	encoded, err := metaInfo.Info.encode()
+	if err != nil {
+		return nil, err
+	}
+	metaInfo.infoSum = sha1.Sum(encoded)
+	metaInfo.infoHash = sha1.Sum(metaInfo.infoBytes)
+	metaInfo.infoHashV2 = sha256.Sum256(metaInfo.infoBytes)
 
 	return &metaInfo, nil
 }
 
+// parseAnnounceList parses a BEP 12 announce-list, a list of tiers each
+// holding a list of tracker URLs. Empty URLs and tiers are dropped.
+func parseAnnounceList(v interface{}) ([][]string, error) {
+	tierList, ok := v.([]interface{})
+	if !ok {
+		return nil, &FieldError{Path: "announce-list", Err: ErrInvalidField}
+	}
+	var tiers [][]string
+	for i, tierValue := range tierList {
+		urlList, ok := tierValue.([]interface{})
+		if !ok {
+			return nil, &FieldError{Path: fmt.Sprintf("announce-list[%d]", i), Err: ErrInvalidField}
+		}
+		var tier []string
+		for j, urlValue := range urlList {
+			url, ok := urlValue.(string)
+			if !ok {
+				return nil, &FieldError{Path: fmt.Sprintf("announce-list[%d][%d]", i, j), Err: ErrInvalidField}
+			}
+			if url != "" {
+				tier = append(tier, url)
+			}
+		}
+		if len(tier) > 0 {
+			tiers = append(tiers, tier)
+		}
+	}
+	return tiers, nil
+}
+
+// parseURLList parses a BEP 19 url-list, which may also be a single URL.
+func parseURLList(v interface{}) ([]string, error) {
+	if url, ok := v.(string); ok {
+		v = []interface{}{url}
+	}
+	return parseStrings("url-list", v)
+}
+
+// parseStrings parses a list of strings, such as URLs, dropping empty ones.
+func parseStrings(path string, v interface{}) ([]string, error) {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, &FieldError{Path: path, Err: ErrInvalidField}
+	}
+	var strs []string
+	for i, item := range list {
+		str, ok := item.(string)
+		if !ok {
+			return nil, &FieldError{Path: fmt.Sprintf("%s[%d]", path, i), Err: ErrInvalidField}
+		}
+		if str != "" {
+			strs = append(strs, str)
+		}
+	}
+	return strs, nil
+}
+
+// parseNodes parses the BEP 5 nodes list, whose items are lists of a host and
+// a port. It reports false if v is missing or malformed.
+func parseNodes(v interface{}) ([]Node, bool) {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	nodes := make([]Node, len(list))
+	for i, item := range list {
+		pair, ok := item.([]interface{})
+		if !ok || len(pair) != 2 {
+			return nil, false
+		}
+		host, ok := pair[0].(string)
+		if !ok || host == "" {
+			return nil, false
+		}
+		port, ok := pair[1].(int64)
+		if !ok || port <= 0 || port > 65535 {
+			return nil, false
+		}
+		nodes[i] = Node{Host: host, Port: int(port)}
+	}
+	return nodes, true
+}
+
+// parseSimilar parses the BEP 38 similar list of info hashes. It reports
+// false if v is missing or malformed.
+func parseSimilar(v interface{}) ([][20]byte, bool) {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	hashes := make([][20]byte, len(list))
+	for i, item := range list {
+		hash, ok := item.(string)
+		if !ok || len(hash) != 20 {
+			return nil, false
+		}
+		copy(hashes[i][:], hash)
+	}
+	return hashes, true
+}
+
 func ParseInfo(dict map[string]interface{}) (Info, error) {
-	info := Info{}
+	return parseInfo(dict, nil)
+}
+
+func parseInfo(dict map[string]interface{}, report *Report) (Info, error) {
+	info := Info{Extra: maps.Clone(dict)}
 
 	pieceLength, ok := dict["piece length"].(int64)
 	if !ok {
-		return info, errors.New("missing piece length")
+		return info, &FieldError{Path: "info.piece length", Err: ErrMissingField}
 	}
 	info.PieceLength = pieceLength
+	delete(info.Extra, "piece length")
+
+	if metaVersion, ok := dict["meta version"]; ok {
+		metaVersion, ok := metaVersion.(int64)
+		if !ok || metaVersion != 2 {
+			return info, &FieldError{Path: "info.meta version", Err: ErrInvalidField}
+		}
+		info.MetaVersion = metaVersion
+		delete(info.Extra, "meta version")
 
+		if err := parseFileTree(&info, dict); err != nil {
+			return info, err
+		}
+	}
+	// Only private=1 is consumed, as the one value encode writes back; others,
+	// such as an explicit 0, stay in Extra.
+	if private, ok := dict["private"].(int64); ok && private == 1 {
+		info.Private = true
+		delete(info.Extra, "private")
+	}
+
+	if name, ok := dict["name"].(string); ok {
+		info.Name = name
+		delete(info.Extra, "name")
+	}
+
+	if source, ok := dict["source"].(string); ok && source != "" {
+		info.Source = source
+		delete(info.Extra, "source")
+	}
+
+	// Malformed extension keys are kept in Extra, like a comment that is not
+	// a string, since they do not keep the torrent from being used.
+	if similar, ok := parseSimilar(dict["similar"]); ok {
+		info.Similar = similar
+		delete(info.Extra, "similar")
+	}
+
+	if collections, ok := dict["collections"]; ok {
+		if names, err := parseStrings("info.collections", collections); err == nil {
+			info.Collections = names
+			delete(info.Extra, "collections")
+		}
+	}
+
+	// The v1 keys are optional in v2 torrents, and present in hybrid ones.
+	if _, ok := dict["pieces"]; !ok && info.MetaVersion == 2 {
+		return info, nil
+	}
+
+	if info.PieceLength <= 0 && report == nil {
+		return info, &FieldError{Path: "info.piece length", Err: ErrInvalidField}
+	}
 	piecesString, ok := dict["pieces"].(string)
 	if !ok {
-		return info, errors.New("missing pieces")
+		return info, &FieldError{Path: "info.pieces", Err: ErrMissingField}
 	}
-	for i := 0; i < len(piecesString); i += 20 {
+	if len(piecesString)%20 != 0 {
+		err := &FieldError{Path: "info.pieces", Err: fmt.Errorf("%w: length %d is not a multiple of 20", ErrInvalidField, len(piecesString))}
+		if report == nil {
+			return info, err
+		}
+		report.add(SeverityError, err)
+	}
+	info.Pieces = make([][20]byte, 0, len(piecesString)/20)
+	for i := 0; i+20 <= len(piecesString); i += 20 {
 		var piece [20]byte
 		copy(piece[:], piecesString[i:i+20])
 		info.Pieces = append(info.Pieces, piece)
 	}
-
-	if private, ok := dict["private"].(int64); ok {
-		info.Private = private == 1
-	}
-
-	if name, ok := dict["name"].(string); ok {
-		info.Name = name
-	}
+	delete(info.Extra, "pieces")
 
 	if length, ok := dict["length"].(int64); ok {
 		info.Length = length
+		delete(info.Extra, "length")
 
 		if md5sumHexString, ok := dict["md5sum"].(string); ok {
 			md5sum, err := hex.DecodeString(md5sumHexString)
 			if err != nil {
-				return info, errors.New("invalid md5sum")
+				return info, &FieldError{Path: "info.md5sum", Err: ErrInvalidField}
 			}
 			info.MD5Sum = md5sum
+			delete(info.Extra, "md5sum")
 		}
 
-	} else if filesList, ok := dict["files"].([]map[string]interface{}); ok {
+	} else if filesList, ok := dict["files"].([]interface{}); ok {
 		// Multiple File Mode
-		for _, fileDict := range filesList {
-			file := File{}
+		if len(filesList) == 0 {
+			return info, &FieldError{Path: "info.files", Err: ErrNoFiles}
+		}
+		for i, fileValue := range filesList {
+			fileDict, ok := fileValue.(map[string]interface{})
+			if !ok {
+				return info, &FieldError{Path: fmt.Sprintf("info.files[%d]", i), Err: ErrInvalidField}
+			}
+			file := File{Extra: maps.Clone(fileDict)}
 
 			length, ok := fileDict["length"].(int64)
 			if !ok {
-				return info, errors.New("missing file length")
+				return info, &FieldError{Path: fmt.Sprintf("info.files[%d].length", i), Err: ErrMissingField}
 			}
 			file.Length = length
+			delete(file.Extra, "length")
 
 			if md5sumHexString, ok := fileDict["md5sum"].(string); ok {
 				md5sum, err := hex.DecodeString(md5sumHexString)
 				if err != nil {
-					return info, errors.New("invalid md5sum")
+					return info, &FieldError{Path: fmt.Sprintf("info.files[%d].md5sum", i), Err: ErrInvalidField}
 				}
 				file.MD5Sum = md5sum
+				delete(file.Extra, "md5sum")
 			}
 
 			pathList, ok := fileDict["path"].([]interface{})
 			if !ok {
-				return info, errors.New("missing file path")
+				return info, &FieldError{Path: fmt.Sprintf("info.files[%d].path", i), Err: ErrMissingField}
+			}
+			if len(pathList) == 0 {
+				return info, &FieldError{Path: fmt.Sprintf("info.files[%d].path", i), Err: ErrInvalidField}
 			}
-			for _, pathComponent := range pathList {
+			file.Path = make([]string, len(pathList))
+			for j, pathComponent := range pathList {
 				pathComponent, ok := pathComponent.(string)
 				if !ok {
-					return info, errors.New("invalid file path")
+					return info, &FieldError{Path: fmt.Sprintf("info.files[%d].path[%d]", i, j), Err: ErrInvalidField}
 				}
-				file.Path += pathComponent
+				file.Path[j] = pathComponent
+			}
+			delete(file.Extra, "path")
+
+			if attr, ok := fileDict["attr"].(string); ok && attr != "" {
+				file.Attr = attr
+				delete(file.Extra, "attr")
 			}
 
 			info.Files = append(info.Files, file)
 		}
+		delete(info.Extra, "files")
 
 	} else {
-		return info, errors.New("missing any file definition")
+		return info, &FieldError{Path: "info", Err: ErrNoFiles}
+	}
+
+	if info.IsHybrid() {
+		if err := checkFileLists(info); err != nil {
+			return info, err
+		}
+	}
+	if n := info.NumPieces(); n != len(info.Pieces) && report == nil {
+		return info, &FieldError{Path: "info.pieces", Err: fmt.Errorf("%w: %d hashes for %d pieces", ErrPieceCountMismatch, len(info.Pieces), n)}
 	}
 
 	return info, nil
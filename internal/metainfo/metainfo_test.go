@@ -2,10 +2,16 @@ package metainfo
 
 import (
 	"bufio"
+	"encoding/hex"
+	"errors"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/stupoid/torrent/internal/bencode"
 )
 
 func TestParse(t *testing.T) {
@@ -17,6 +23,7 @@ func TestParse(t *testing.T) {
 	expectedInfoName := "ubuntu-24.04.1-desktop-amd64.iso"
 	expectedInfoLength := int64(6203355136)
 	expectedCreationDate := time.Unix(1724947415, 0)
+	expectedInfoHash := "4a3f5e08bcef825718eda30637230585e3330599"
 
 	t.Run("ubuntu", func(t *testing.T) {
 		f, err := os.Open(testFilepath)
@@ -36,8 +43,264 @@ func TestParse(t *testing.T) {
 		if m.Info.Length != expectedInfoLength {
 			t.Errorf("expected %d, got %d", expectedInfoLength, m.Info.Length)
 		}
+		expectedAnnounceList := [][]string{{"https://torrent.ubuntu.com/announce"}, {"https://ipv6.torrent.ubuntu.com/announce"}}
+		if !slices.EqualFunc(m.AnnounceList, expectedAnnounceList, slices.Equal) {
+			t.Errorf("expected %q, got %q", expectedAnnounceList, m.AnnounceList)
+		}
 		if m.CreationDate != expectedCreationDate {
 			t.Errorf("expected %v, got %v", expectedCreationDate, m.CreationDate)
 		}
+		infoHash := m.InfoHash()
+		if hex.EncodeToString(infoHash[:]) != expectedInfoHash {
+			t.Errorf("expected %s, got %x", expectedInfoHash, infoHash)
+		}
 	})
+
+	t.Run("multi", func(t *testing.T) {
+		f, err := os.Open(filepath.Join(testDir, "multi.torrent"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		m, err := Parse(bufio.NewReader(f))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Info.Name != "multi" {
+			t.Errorf("expected %q, got %q", "multi", m.Info.Name)
+		}
+		if m.Info.Length != 0 {
+			t.Errorf("expected no single file length, got %d", m.Info.Length)
+		}
+		expectedAnnounceList := [][]string{
+			{"http://tracker.example.com/announce", "udp://tracker.example.com:6969/announce"},
+			{"http://backup.example.org/announce"},
+		}
+		if !slices.EqualFunc(m.AnnounceList, expectedAnnounceList, slices.Equal) {
+			t.Errorf("expected %q, got %q", expectedAnnounceList, m.AnnounceList)
+		}
+		if len(m.Info.Pieces) != 4 {
+			t.Errorf("expected 4 pieces, got %d", len(m.Info.Pieces))
+		}
+
+		expectedFiles := []struct {
+			length int64
+			path   []string
+			osPath string
+		}{
+			{1000, []string{"README.txt"}, "README.txt"},
+			{70000, []string{"data", "a", "b.bin"}, filepath.Join("data", "a", "b.bin")},
+			{50000, []string{"docs", "guide.txt"}, filepath.Join("docs", "guide.txt")},
+			{0, []string{"empty.txt"}, "empty.txt"},
+		}
+		if len(m.Info.Files) != len(expectedFiles) {
+			t.Fatalf("expected %d files, got %d", len(expectedFiles), len(m.Info.Files))
+		}
+		for i, expected := range expectedFiles {
+			file := m.Info.Files[i]
+			if file.Length != expected.length {
+				t.Errorf("file %d: expected length %d, got %d", i, expected.length, file.Length)
+			}
+			if !slices.Equal(file.Path, expected.path) {
+				t.Errorf("file %d: expected path %q, got %q", i, expected.path, file.Path)
+			}
+			osPath, err := file.OSPath()
+			if err != nil {
+				t.Errorf("file %d: %v", i, err)
+			}
+			if osPath != expected.osPath {
+				t.Errorf("file %d: expected OS path %q, got %q", i, expected.osPath, osPath)
+			}
+		}
+		if expected := "f1ce5a99edbaf8b79c0447431b4a890c"; hex.EncodeToString(m.Info.Files[1].MD5Sum) != expected {
+			t.Errorf("expected md5sum %s, got %x", expected, m.Info.Files[1].MD5Sum)
+		}
+
+		infoHash := m.InfoHash()
+		if expected := "f468c531cd04c112301cbaf45f4d898bbc91be3e"; hex.EncodeToString(infoHash[:]) != expected {
+			t.Errorf("expected %s, got %x", expected, infoHash)
+		}
+	})
+}
+
+func TestFileOSPath(t *testing.T) {
+	tests := []struct {
+		path     []string
+		expected string
+		ok       bool
+	}{
+		{[]string{"a"}, "a", true},
+		{[]string{"a", "b", "c.txt"}, filepath.Join("a", "b", "c.txt"), true},
+		{[]string{"..", "etc", "passwd"}, "", false},
+		{[]string{"a", "..", "..", "b"}, "", false},
+		{[]string{"."}, "", false},
+		{[]string{"a", ""}, "", false},
+		{[]string{"a/b"}, "", false},
+		{[]string{"/etc"}, "", false},
+		{nil, "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(strings.Join(test.path, "|"), func(t *testing.T) {
+			path, err := File{Path: test.path}.OSPath()
+			if !test.ok {
+				if !errors.Is(err, ErrUnsafePath) {
+					t.Errorf("expected error %v, got %v", ErrUnsafePath, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, path)
+			}
+		})
+	}
+}
+
+func TestParseAnnounceList(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedAnnounce string
+		expectedList     [][]string
+	}{
+		{"d8:announce1:a4:infod6:lengthi0e4:name1:a12:piece lengthi1e6:pieces0:ee", "a", nil},
+		{"d13:announce-listll1:b1:cel1:dee4:infod6:lengthi0e4:name1:a12:piece lengthi1e6:pieces0:ee", "", [][]string{{"b", "c"}, {"d"}}},
+		{"d8:announce1:a13:announce-listll0:el1:b0:ee4:infod6:lengthi0e4:name1:a12:piece lengthi1e6:pieces0:ee", "a", [][]string{{"b"}}},
+		// A malformed announce-list is left in Extra.
+		{"d8:announce1:a13:announce-list1:x4:infod6:lengthi0e4:name1:a12:piece lengthi1e6:pieces0:ee", "a", nil},
+		{"d8:announce1:a13:announce-listll1:bel1:ci1eee4:infod6:lengthi0e4:name1:a12:piece lengthi1e6:pieces0:ee", "a", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			m, err := Parse(bufio.NewReader(strings.NewReader(test.input)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.Announce != test.expectedAnnounce {
+				t.Errorf("expected %q, got %q", test.expectedAnnounce, m.Announce)
+			}
+			if !slices.EqualFunc(m.AnnounceList, test.expectedList, slices.Equal) {
+				t.Errorf("expected %q, got %q", test.expectedList, m.AnnounceList)
+			}
+		})
+	}
+}
+
+func TestParseWebSeeds(t *testing.T) {
+	const info = "4:infod6:lengthi0e4:name1:a12:piece lengthi1e6:pieces0:e"
+	tests := []struct {
+		input             string
+		expectedURLList   []string
+		expectedHTTPSeeds []string
+	}{
+		{"d8:announce1:a" + info + "e", nil, nil},
+		{"d8:announce1:a" + info + "8:url-list9:http://w/e", []string{"http://w/"}, nil},
+		{"d8:announce1:a" + info + "8:url-listl9:http://w/0:9:http://x/ee", []string{"http://w/", "http://x/"}, nil},
+		{"d8:announce1:a9:httpseedsl9:http://h/e" + info + "e", nil, []string{"http://h/"}},
+		// Malformed web seeds are left in Extra.
+		{"d8:announce1:a" + info + "8:url-listl1:xi1eee", nil, nil},
+		{"d8:announce1:a9:httpseeds1:x" + info + "e", nil, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			m := parseString(t, test.input)
+			if !slices.Equal(m.URLList, test.expectedURLList) {
+				t.Errorf("expected %q, got %q", test.expectedURLList, m.URLList)
+			}
+			if !slices.Equal(m.HTTPSeeds, test.expectedHTTPSeeds) {
+				t.Errorf("expected %q, got %q", test.expectedHTTPSeeds, m.HTTPSeeds)
+			}
+
+			written := parseString(t, writeString(t, m))
+			if !slices.Equal(written.URLList, m.URLList) || !slices.Equal(written.HTTPSeeds, m.HTTPSeeds) {
+				t.Errorf("expected web seeds to survive WriteTo, got %q and %q", written.URLList, written.HTTPSeeds)
+			}
+		})
+	}
+}
+
+func TestParseTrackerless(t *testing.T) {
+	input := "d4:infod6:lengthi0e4:name1:a12:piece lengthi1e6:pieces0:e5:nodesll9:127.0.0.1i6881eeee"
+	m := parseString(t, input)
+	if m.Announce != "" || m.AnnounceList != nil {
+		t.Errorf("expected no trackers, got %q and %q", m.Announce, m.AnnounceList)
+	}
+	if expected := []Node{{"127.0.0.1", 6881}}; !slices.Equal(m.Nodes, expected) {
+		t.Errorf("expected nodes %v, got %v", expected, m.Nodes)
+	}
+	if output := writeString(t, m); output != input {
+		t.Errorf("expected WriteTo to reproduce the input, got %q", output)
+	}
+}
+
+func TestParseWithLimits(t *testing.T) {
+	input := "d8:announce1:a4:infod6:lengthi0e4:name1:a12:piece lengthi1e6:pieces0:ee"
+	parse := func(limits bencode.Limits) error {
+		_, err := ParseWithLimits(bufio.NewReader(strings.NewReader(input)), limits)
+		return err
+	}
+	if err := parse(bencode.Limits{MaxBytes: int64(len(input))}); err != nil {
+		t.Errorf("expected the torrent to fit its own size, got %v", err)
+	}
+	if err := parse(bencode.Limits{MaxBytes: 20}); !errors.Is(err, bencode.ErrInputTooLarge) {
+		t.Errorf("expected %v, got %v", bencode.ErrInputTooLarge, err)
+	}
+	if err := parse(bencode.Limits{MaxDepth: 1}); !errors.Is(err, bencode.ErrMaxDepthExceeded) {
+		t.Errorf("expected %v, got %v", bencode.ErrMaxDepthExceeded, err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedErr  error
+		expectedPath string
+	}{
+		{"d4:infod6:lengthi1e4:name1:a12:piece lengthi1e6:pieces0:ee", ErrMissingField, "announce"},
+		{"d8:announcei1e4:infod6:lengthi1e4:name1:a12:piece lengthi1e6:pieces0:ee", ErrInvalidField, "announce"},
+		{"d8:announce1:ae", ErrMissingField, "info"},
+		{"d13:announce-listle4:infod6:lengthi1e4:name1:a12:piece lengthi1e6:pieces0:ee", ErrMissingField, "announce"},
+		{"d4:infod6:lengthi1e4:name1:a12:piece lengthi1e6:pieces0:e5:nodeslee", ErrMissingField, "announce"},
+		{"d8:announce1:a4:infod6:lengthi1e4:name1:a6:pieces0:ee", ErrMissingField, "info.piece length"},
+		{"d8:announce1:a4:infod6:lengthi1e6:md5sum2:zz4:name1:a12:piece lengthi1e6:pieces0:ee", ErrInvalidField, "info.md5sum"},
+		{"d8:announce1:a4:infod4:name1:a12:piece lengthi1e6:pieces0:ee", ErrNoFiles, "info"},
+		{"d8:announce1:a4:infod5:filesle4:name1:a12:piece lengthi1e6:pieces0:ee", ErrNoFiles, "info.files"},
+		{"d8:announce1:a4:infod5:filesli1ee4:name1:a12:piece lengthi1e6:pieces0:ee", ErrInvalidField, "info.files[0]"},
+		{"d8:announce1:a4:infod5:filesld4:pathl1:aeee4:name1:a12:piece lengthi1e6:pieces0:ee", ErrMissingField, "info.files[0].length"},
+		{"d8:announce1:a4:infod5:filesld6:lengthi1e4:pathl1:aeed6:lengthi1eee4:name1:a12:piece lengthi1e6:pieces0:ee", ErrMissingField, "info.files[1].path"},
+		{"d8:announce1:a4:infod5:filesld6:lengthi1e4:pathleee4:name1:a12:piece lengthi1e6:pieces0:ee", ErrInvalidField, "info.files[0].path"},
+		{"d8:announce1:a4:infod5:filesld6:lengthi1e4:pathl1:ai1eeee4:name1:a12:piece lengthi1e6:pieces0:ee", ErrInvalidField, "info.files[0].path[1]"},
+		{"d8:announce1:a4:infod6:lengthi0e4:name1:a12:piece lengthi0e6:pieces0:ee", ErrInvalidField, "info.piece length"},
+		{"d8:announce1:a4:infod6:lengthi1e4:name1:a12:piece lengthi1e6:pieces7:abcdefgee", ErrInvalidField, "info.pieces"},
+		{"d8:announce1:a4:infod6:lengthi3e4:name1:a12:piece lengthi2e6:pieces20:aaaaaaaaaaaaaaaaaaaaee", ErrPieceCountMismatch, "info.pieces"},
+		{"d8:announce1:a4:infod5:filesld6:lengthi1e4:pathl1:aeee4:name1:a12:piece lengthi1e6:pieces40:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaee", ErrPieceCountMismatch, "info.pieces"},
+		{"d8:announce1:a4:infod4:namei1xeee", bencode.ErrReadValueFailed, "info.name"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			_, err := Parse(bufio.NewReader(strings.NewReader(test.input)))
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("expected error %v, got %v", test.expectedErr, err)
+			}
+			var fieldErr *FieldError
+			var syntaxErr *bencode.SyntaxError
+			switch {
+			case errors.As(err, &fieldErr):
+				if fieldErr.Path != test.expectedPath {
+					t.Errorf("expected path %q, got %q", test.expectedPath, fieldErr.Path)
+				}
+			case errors.As(err, &syntaxErr):
+				if syntaxErr.Path != test.expectedPath {
+					t.Errorf("expected path %q, got %q", test.expectedPath, syntaxErr.Path)
+				}
+			default:
+				t.Errorf("expected a positional error, got %T", err)
+			}
+		})
+	}
 }
@@ -0,0 +1,118 @@
+package metainfo
+
+import (
+	"sort"
+)
+
+// FileExtent is the part of a file covered by a piece.
+type FileExtent struct {
+	File   int   // index of the file in Info.FileList
+	Offset int64 // offset of the extent within the file
+	Length int64
+}
+
+// FileList returns the files of i in the order their data is split into
+// pieces: Files in multi-file torrents, padding files included, a single file
+// named Name in single-file torrents, and FileTree in v2-only torrents.
+func (i Info) FileList() []File {
+	switch {
+	case !i.HasV1():
+		return i.FileTree
+	case i.Files != nil:
+		return i.Files
+	default:
+		return []File{{Length: i.Length, MD5Sum: i.MD5Sum, Path: []string{i.Name}}}
+	}
+}
+
+// TotalLength returns the sum of the lengths of the files of FileList.
+func (i Info) TotalLength() int64 {
+	var total int64
+	for _, file := range i.FileList() {
+		total += file.Length
+	}
+	return total
+}
+
+// NumPieces returns the number of pieces of i. In v2-only torrents every file
+// starts a new piece, so it is the sum of each file's piece count.
+func (i Info) NumPieces() int {
+	if i.PieceLength <= 0 {
+		return 0
+	}
+	_, _, end := i.layout()
+	return int((end + i.PieceLength - 1) / i.PieceLength)
+}
+
+// LastPieceLength returns the length of the last piece of i, which is shorter
+// than PieceLength unless the data ends on a piece boundary.
+func (i Info) LastPieceLength() int64 {
+	n := i.NumPieces()
+	if n == 0 {
+		return 0
+	}
+	_, _, end := i.layout()
+	return end - int64(n-1)*i.PieceLength
+}
+
+// PieceExtents returns the parts of the files covered by the piece at index,
+// in file order, skipping empty files. It returns nil if index is out of
+// range.
+func (i Info) PieceExtents(index int) []FileExtent {
+	if index < 0 || index >= i.NumPieces() {
+		return nil
+	}
+	files, offsets, end := i.layout()
+	start := int64(index) * i.PieceLength
+	stop := min(start+i.PieceLength, end)
+
+	first := sort.Search(len(files), func(j int) bool {
+		return offsets[j]+files[j].Length > start
+	})
+	var extents []FileExtent
+	for j := first; j < len(files) && offsets[j] < stop; j++ {
+		if files[j].Length == 0 {
+			continue
+		}
+		from := max(start, offsets[j])
+		to := min(stop, offsets[j]+files[j].Length)
+		extents = append(extents, FileExtent{File: j, Offset: from - offsets[j], Length: to - from})
+	}
+	return extents
+}
+
+// FilePieces returns the range [begin, end) of the pieces covering the file
+// at index in FileList. The range is empty for empty files, and 0, 0 if index
+// is out of range.
+func (i Info) FilePieces(index int) (begin, end int) {
+	if i.PieceLength <= 0 {
+		return 0, 0
+	}
+	files, offsets, _ := i.layout()
+	if index < 0 || index >= len(files) {
+		return 0, 0
+	}
+	offset, length := offsets[index], files[index].Length
+	begin = int(offset / i.PieceLength)
+	if length <= 0 {
+		return begin, begin
+	}
+	return begin, int((offset + length + i.PieceLength - 1) / i.PieceLength)
+}
+
+// layout returns the files of FileList along with the offset of each in the
+// data split into pieces, and the end of that data. Non-empty files in v2-only
+// torrents are aligned to piece boundaries.
+func (i Info) layout() (files []File, offsets []int64, end int64) {
+	files = i.FileList()
+	offsets = make([]int64, len(files))
+	aligned := !i.HasV1() && i.PieceLength > 0
+	for j, file := range files {
+		if aligned && file.Length > 0 && end%i.PieceLength != 0 {
+			end += i.PieceLength - end%i.PieceLength
+		}
+		offsets[j] = end
+		end += file.Length
+	}
+	return files, offsets, end
+}
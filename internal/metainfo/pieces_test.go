@@ -0,0 +1,215 @@
+package metainfo
+
+import (
+	"bufio"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"testing/quick"
+)
+
+func TestInfoPieces(t *testing.T) {
+	f, err := os.Open(filepath.Join("..", "..", "test", "multi.torrent"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	m, err := Parse(bufio.NewReader(f))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := m.Info
+
+	if total := info.TotalLength(); total != 121000 {
+		t.Errorf("expected total length %d, got %d", 121000, total)
+	}
+	if n := info.NumPieces(); n != len(info.Pieces) {
+		t.Errorf("expected %d pieces, got %d", len(info.Pieces), n)
+	}
+	if last := info.LastPieceLength(); last != 121000-3*32768 {
+		t.Errorf("expected last piece length %d, got %d", 121000-3*32768, last)
+	}
+
+	extentTests := []struct {
+		index    int
+		expected []FileExtent
+	}{
+		{0, []FileExtent{{0, 0, 1000}, {1, 0, 31768}}},
+		{1, []FileExtent{{1, 31768, 32768}}},
+		{2, []FileExtent{{1, 64536, 5464}, {2, 0, 27304}}},
+		{3, []FileExtent{{2, 27304, 22696}}},
+		{4, nil},
+		{-1, nil},
+	}
+	for _, test := range extentTests {
+		if extents := info.PieceExtents(test.index); !slices.Equal(extents, test.expected) {
+			t.Errorf("piece %d: expected %v, got %v", test.index, test.expected, extents)
+		}
+	}
+
+	pieceTests := []struct {
+		file       int
+		begin, end int
+	}{
+		{0, 0, 1},
+		{1, 0, 3},
+		{2, 2, 4},
+		{3, 3, 3},
+		{4, 0, 0},
+		{-1, 0, 0},
+	}
+	for _, test := range pieceTests {
+		if begin, end := info.FilePieces(test.file); begin != test.begin || end != test.end {
+			t.Errorf("file %d: expected pieces [%d, %d), got [%d, %d)", test.file, test.begin, test.end, begin, end)
+		}
+	}
+}
+
+func TestInfoPiecesSingleFile(t *testing.T) {
+	info := Info{PieceLength: 10, Name: "a", Length: 25}
+	if n := info.NumPieces(); n != 3 {
+		t.Errorf("expected 3 pieces, got %d", n)
+	}
+	if last := info.LastPieceLength(); last != 5 {
+		t.Errorf("expected last piece length 5, got %d", last)
+	}
+	if extents := info.PieceExtents(2); !slices.Equal(extents, []FileExtent{{0, 20, 5}}) {
+		t.Errorf("expected the end of the file, got %v", extents)
+	}
+	if files := info.FileList(); len(files) != 1 || files[0].Length != 25 || !slices.Equal(files[0].Path, []string{"a"}) {
+		t.Errorf("expected a single file, got %v", files)
+	}
+}
+
+func TestInfoPiecesV2(t *testing.T) {
+	info := Info{PieceLength: 10, MetaVersion: 2, FileTree: []File{
+		{Length: 15, Path: []string{"a"}},
+		{Length: 0, Path: []string{"b"}},
+		{Length: 10, Path: []string{"c"}},
+		{Length: 3, Path: []string{"d"}},
+	}}
+	if n := info.NumPieces(); n != 4 {
+		t.Errorf("expected 4 pieces, got %d", n)
+	}
+	if last := info.LastPieceLength(); last != 3 {
+		t.Errorf("expected last piece length 3, got %d", last)
+	}
+	if extents := info.PieceExtents(1); !slices.Equal(extents, []FileExtent{{0, 10, 5}}) {
+		t.Errorf("expected pieces not to span files, got %v", extents)
+	}
+	if begin, end := info.FilePieces(2); begin != 2 || end != 3 {
+		t.Errorf("expected pieces [2, 3), got [%d, %d)", begin, end)
+	}
+}
+
+// randomInfo is an Info with a random piece length and file layout, either
+// single-file, multi-file with padding files, or v2-only.
+type randomInfo struct {
+	Info
+}
+
+func (randomInfo) Generate(r *rand.Rand, size int) reflect.Value {
+	info := Info{PieceLength: 1 + r.Int63n(64), Name: "random"}
+	randomLength := func() int64 {
+		if r.Intn(4) == 0 {
+			return 0
+		}
+		return r.Int63n(int64(4*size) + 1)
+	}
+	files := make([]File, 1+r.Intn(8))
+	for i := range files {
+		files[i] = File{Length: randomLength(), Path: []string{string(rune('a' + i))}}
+		if r.Intn(4) == 0 {
+			files[i].Attr = "p"
+		}
+	}
+
+	switch r.Intn(3) {
+	case 0:
+		info.Length = randomLength()
+	case 1:
+		info.Files = files
+	case 2:
+		info.MetaVersion = 2
+		info.FileTree = files
+	}
+	return reflect.ValueOf(randomInfo{info})
+}
+
+func TestInfoPiecesProperties(t *testing.T) {
+	// The extents of all pieces, in order, cover every non-empty file of
+	// FileList once from start to end, and each piece is full except the last
+	// one, or in v2-only torrents except the last one of each file.
+	coversFiles := func(r randomInfo) bool {
+		files := r.FileList()
+		n := r.NumPieces()
+		file, offset := 0, int64(0)
+		for i := 0; i < n; i++ {
+			extents := r.PieceExtents(i)
+			var length int64
+			for _, extent := range extents {
+				for file < len(files) && offset == files[file].Length {
+					file, offset = file+1, 0
+				}
+				if extent.File != file || extent.Offset != offset || extent.Length <= 0 {
+					return false
+				}
+				offset += extent.Length
+				length += extent.Length
+			}
+			switch {
+			case i == n-1:
+				if length != r.LastPieceLength() {
+					return false
+				}
+			case r.HasV1() && length != r.PieceLength:
+				return false
+			case length <= 0 || length > r.PieceLength:
+				return false
+			}
+		}
+		for file < len(files) && offset == files[file].Length {
+			file, offset = file+1, 0
+		}
+		return file == len(files)
+	}
+
+	// The pieces whose extents include a file are exactly FilePieces.
+	matchesFilePieces := func(r randomInfo) bool {
+		for j := range r.FileList() {
+			begin, end := r.FilePieces(j)
+			for i := 0; i < r.NumPieces(); i++ {
+				covered := slices.ContainsFunc(r.PieceExtents(i), func(e FileExtent) bool { return e.File == j })
+				if covered != (i >= begin && i < end) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	// v1 pieces split the concatenated files evenly.
+	countsPieces := func(r randomInfo) bool {
+		if !r.HasV1() {
+			return true
+		}
+		total := r.TotalLength()
+		return int64(r.NumPieces()) == (total+r.PieceLength-1)/r.PieceLength &&
+			(r.NumPieces() == 0 || int64(r.NumPieces()-1)*r.PieceLength+r.LastPieceLength() == total)
+	}
+
+	for name, property := range map[string]interface{}{
+		"covers files":        coversFiles,
+		"matches file pieces": matchesFilePieces,
+		"counts pieces":       countsPieces,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := quick.Check(property, &quick.Config{MaxCount: 500}); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package metainfo
+
+import (
+	"iter"
+	"math/rand/v2"
+	"slices"
+)
+
+// Tiers is a BEP 12 list of tracker tiers. Trackers are tried in order: every
+// tracker of the first tier, then every tracker of the second, and so on.
+type Tiers [][]string
+
+// Tiers returns the trackers of m as a Tiers with each tier shuffled, as BEP
+// 12 asks clients to do when loading a torrent. It falls back to a single tier
+// holding Announce when m has no announce list, and returns nil when m has no
+// trackers at all. The result is a copy, so reordering it leaves m unchanged.
+func (m MetaInfo) Tiers() Tiers {
+	if len(m.AnnounceList) == 0 {
+		if m.Announce == "" {
+			return nil
+		}
+		return Tiers{{m.Announce}}
+	}
+	tiers := make(Tiers, len(m.AnnounceList))
+	for i, tier := range m.AnnounceList {
+		tiers[i] = slices.Clone(tier)
+	}
+	tiers.Shuffle()
+	return tiers
+}
+
+// Shuffle randomly reorders the trackers within each tier, keeping the order
+// of the tiers themselves.
+func (t Tiers) Shuffle() {
+	for _, tier := range t {
+		rand.Shuffle(len(tier), func(i, j int) {
+			tier[i], tier[j] = tier[j], tier[i]
+		})
+	}
+}
+
+// Promote moves url to the front of its tier, shifting the trackers before it
+// back by one, and reports whether url was found. Clients call it after a
+// tracker responds so that it is tried first next time.
+func (t Tiers) Promote(url string) bool {
+	for _, tier := range t {
+		if i := slices.Index(tier, url); i >= 0 {
+			copy(tier[1:i+1], tier[:i])
+			tier[0] = url
+			return true
+		}
+	}
+	return false
+}
+
+// All returns an iterator over the trackers in the order they should be
+// tried, yielding the index of each tracker's tier along with its URL.
+func (t Tiers) All() iter.Seq2[int, string] {
+	return func(yield func(int, string) bool) {
+		for i, tier := range t {
+			for _, url := range tier {
+				if !yield(i, url) {
+					return
+				}
+			}
+		}
+	}
+}
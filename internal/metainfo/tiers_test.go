@@ -0,0 +1,99 @@
+package metainfo
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMetaInfoTiers(t *testing.T) {
+	tests := []struct {
+		name     string
+		metaInfo MetaInfo
+		expected Tiers
+	}{
+		{"announce only", MetaInfo{Announce: "a"}, Tiers{{"a"}}},
+		{"announce list", MetaInfo{Announce: "a", AnnounceList: [][]string{{"b"}, {"c"}}}, Tiers{{"b"}, {"c"}}},
+		{"no trackers", MetaInfo{}, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tiers := test.metaInfo.Tiers()
+			if !slices.EqualFunc(tiers, test.expected, slices.Equal) {
+				t.Errorf("expected %q, got %q", test.expected, tiers)
+			}
+		})
+	}
+}
+
+func TestMetaInfoTiersShuffled(t *testing.T) {
+	m := MetaInfo{AnnounceList: [][]string{{"a", "b", "c", "d"}, {"e", "f"}}}
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		tiers := m.Tiers()
+		if len(tiers) != 2 {
+			t.Fatalf("expected 2 tiers, got %d", len(tiers))
+		}
+		first := slices.Sorted(slices.Values(tiers[0]))
+		second := slices.Sorted(slices.Values(tiers[1]))
+		if !slices.Equal(first, m.AnnounceList[0]) || !slices.Equal(second, m.AnnounceList[1]) {
+			t.Fatalf("expected trackers to stay within their tier, got %q", tiers)
+		}
+		seen[tiers[0][0]] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("expected the first tier to be shuffled, always got %q first", m.AnnounceList[0][0])
+	}
+	if !slices.Equal(m.AnnounceList[0], []string{"a", "b", "c", "d"}) {
+		t.Errorf("expected announce list to be unchanged, got %q", m.AnnounceList)
+	}
+}
+
+func TestTiersPromote(t *testing.T) {
+	tiers := Tiers{{"a", "b", "c"}, {"d", "e"}}
+
+	if !tiers.Promote("c") {
+		t.Fatal("expected c to be found")
+	}
+	if !tiers.Promote("e") {
+		t.Fatal("expected e to be found")
+	}
+	if tiers.Promote("x") {
+		t.Error("expected x not to be found")
+	}
+	expected := Tiers{{"c", "a", "b"}, {"e", "d"}}
+	if !slices.EqualFunc(tiers, expected, slices.Equal) {
+		t.Errorf("expected %q, got %q", expected, tiers)
+	}
+}
+
+func TestTiersAll(t *testing.T) {
+	tiers := Tiers{{"a", "b"}, {"c"}, {"d", "e"}}
+
+	var urls []string
+	var tierIndexes []int
+	for tier, url := range tiers.All() {
+		urls = append(urls, url)
+		tierIndexes = append(tierIndexes, tier)
+	}
+	if expected := []string{"a", "b", "c", "d", "e"}; !slices.Equal(urls, expected) {
+		t.Errorf("expected %q, got %q", expected, urls)
+	}
+	if expected := []int{0, 0, 1, 2, 2}; !slices.Equal(tierIndexes, expected) {
+		t.Errorf("expected tiers %v, got %v", expected, tierIndexes)
+	}
+
+	// A tracker that responds is tried first on the next pass.
+	for _, url := range tiers.All() {
+		if url == "b" {
+			tiers.Promote(url)
+			break
+		}
+	}
+	for _, url := range tiers.All() {
+		if url != "b" {
+			t.Errorf("expected b to be tried first, got %q", url)
+		}
+		break
+	}
+}
@@ -0,0 +1,285 @@
+package metainfo
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"maps"
+	"math/bits"
+	"slices"
+	"sort"
+	"strconv"
+)
+
+var (
+	ErrPieceLayerMismatch = errors.New("piece layer does not match pieces root")
+	ErrFileListMismatch   = errors.New("v1 file list does not match the file tree")
+)
+
+// BlockSize is the size of the leaf blocks of v2 merkle trees.
+const BlockSize = 16 << 10
+
+// HasV1 reports whether i holds the v1 keys: pieces and either length or
+// files. Only v2 torrents can go without them.
+func (i Info) HasV1() bool {
+	return i.MetaVersion != 2 || i.Pieces != nil || i.Files != nil
+}
+
+// HasV2 reports whether i holds the BEP 52 keys: meta version 2 and a file
+// tree.
+func (i Info) HasV2() bool {
+	return i.MetaVersion == 2
+}
+
+// IsHybrid reports whether i holds both the v1 and the v2 keys, so that the
+// torrent can be shared with both protocol versions.
+func (i Info) IsHybrid() bool {
+	return i.HasV1() && i.HasV2()
+}
+
+// InfoHashV2 returns the SHA-256 hash of the info dictionary as WriteTo encodes
+// it, which identifies v2 and hybrid torrents. Like InfoHash, it returns the
+// zero hash if the info dictionary cannot be encoded, and encodes Info on every
+// call; InfoHashes gives both hashes for the cost of one.
+func (m MetaInfo) InfoHashV2() [32]byte {
+	_, hash, _ := m.InfoHashes()
+	return hash
+}
+
+// TruncatedInfoHashV2 returns the first 20 bytes of InfoHashV2, which v2
+// torrents use where a 20 byte info hash is expected, such as in tracker
+// requests and the peer handshake.
+func (m MetaInfo) TruncatedInfoHashV2() [20]byte {
+	var truncated [20]byte
+	hash := m.InfoHashV2()
+	copy(truncated[:], hash[:])
+	return truncated
+}
+
+// VerifyPieceLayers checks that every file in Info.FileTree larger than a
+// piece has a piece layer with one hash per piece whose merkle root is the
+// file's pieces root. Mismatches are reported as a *FieldError wrapping
+// ErrPieceLayerMismatch, and missing layers as one wrapping ErrMissingField.
+func (m MetaInfo) VerifyPieceLayers() error {
+	for _, file := range m.Info.FileTree {
+		if file.Length <= m.Info.PieceLength {
+			continue
+		}
+		path := fmt.Sprintf("piece layers[%x]", file.PiecesRoot)
+		layer, ok := m.PieceLayers[file.PiecesRoot]
+		if !ok {
+			return &FieldError{Path: path, Err: ErrMissingField}
+		}
+		numPieces := (file.Length + m.Info.PieceLength - 1) / m.Info.PieceLength
+		if int64(len(layer)) != numPieces {
+			return &FieldError{Path: path, Err: fmt.Errorf("%w: %d hashes for %d pieces", ErrPieceLayerMismatch, len(layer), numPieces)}
+		}
+		if merkleRoot(layer, padHash(pieceLayerHeight(m.Info.PieceLength))) != file.PiecesRoot {
+			return &FieldError{Path: path, Err: ErrPieceLayerMismatch}
+		}
+	}
+	return nil
+}
+
+// checkFileLists checks that the v1 file list of a hybrid info dictionary
+// describes the same files as its file tree, in the same order, with padding
+// files aligning every non-empty file to a piece boundary.
+func checkFileLists(info Info) error {
+	files := info.FileList()
+	path := func(i int) string { return fmt.Sprintf("info.files[%d]", i) }
+	if info.Files == nil {
+		path = func(int) string { return "info" }
+	}
+
+	var offset int64
+	j := 0
+	for i, file := range files {
+		if file.IsPadding() {
+			offset += file.Length
+			continue
+		}
+		if j == len(info.FileTree) {
+			return &FieldError{Path: path(i), Err: fmt.Errorf("%w: %q is not in the file tree", ErrFileListMismatch, file.Path)}
+		}
+		treeFile := info.FileTree[j]
+		j++
+		if !slices.Equal(file.Path, treeFile.Path) || file.Length != treeFile.Length {
+			return &FieldError{Path: path(i), Err: fmt.Errorf("%w: %q of length %d in the file tree", ErrFileListMismatch, treeFile.Path, treeFile.Length)}
+		}
+		if file.Length > 0 && offset%info.PieceLength != 0 {
+			return &FieldError{Path: path(i), Err: fmt.Errorf("%w: file is not aligned to a piece", ErrFileListMismatch)}
+		}
+		offset += file.Length
+	}
+	if j != len(info.FileTree) {
+		return &FieldError{Path: "info.file tree", Err: fmt.Errorf("%w: %q is not in the v1 file list", ErrFileListMismatch, info.FileTree[j].Path)}
+	}
+	return nil
+}
+
+// parseFileTree parses the BEP 52 file tree of an info dictionary.
+func parseFileTree(info *Info, dict map[string]interface{}) error {
+	if info.PieceLength < BlockSize || bits.OnesCount64(uint64(info.PieceLength)) != 1 {
+		return &FieldError{Path: "info.piece length", Err: ErrInvalidField}
+	}
+	tree, ok := dict["file tree"].(map[string]interface{})
+	if !ok {
+		return &FieldError{Path: "info.file tree", Err: ErrMissingField}
+	}
+	if err := walkFileTree(info, tree, nil); err != nil {
+		return err
+	}
+	if len(info.FileTree) == 0 {
+		return &FieldError{Path: "info.file tree", Err: ErrNoFiles}
+	}
+	delete(info.Extra, "file tree")
+	return nil
+}
+
+func walkFileTree(info *Info, dir map[string]interface{}, path []string) error {
+	names := make([]string, 0, len(dir))
+	for name := range dir {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		node, ok := dir[name].(map[string]interface{})
+		if !ok || name == "" {
+			return &FieldError{Path: "info.file tree" + treePath(append(path, name)), Err: ErrInvalidField}
+		}
+		filePath := append(path[:len(path):len(path)], name)
+
+		leaf, ok := node[""]
+		if !ok {
+			if err := walkFileTree(info, node, filePath); err != nil {
+				return err
+			}
+			continue
+		}
+		fieldPath := "info.file tree" + treePath(filePath) + `[""]`
+		leafDict, ok := leaf.(map[string]interface{})
+		if !ok || len(node) != 1 {
+			return &FieldError{Path: fieldPath, Err: ErrInvalidField}
+		}
+
+		file := File{Path: filePath, Extra: maps.Clone(leafDict)}
+		length, ok := leafDict["length"].(int64)
+		if !ok || length < 0 {
+			return &FieldError{Path: fieldPath + ".length", Err: ErrMissingField}
+		}
+		file.Length = length
+		delete(file.Extra, "length")
+
+		if attr, ok := leafDict["attr"].(string); ok && attr != "" {
+			file.Attr = attr
+			delete(file.Extra, "attr")
+		}
+
+		if length > 0 {
+			root, ok := leafDict["pieces root"].(string)
+			if !ok || len(root) != sha256.Size {
+				return &FieldError{Path: fieldPath + ".pieces root", Err: ErrMissingField}
+			}
+			copy(file.PiecesRoot[:], root)
+			delete(file.Extra, "pieces root")
+		}
+		info.FileTree = append(info.FileTree, file)
+	}
+	return nil
+}
+
+// treePath formats the path of a file tree node for a FieldError.
+func treePath(path []string) string {
+	var s string
+	for _, name := range path {
+		s += "[" + strconv.Quote(name) + "]"
+	}
+	return s
+}
+
+// parsePieceLayers parses the top-level piece layers dictionary.
+func parsePieceLayers(v interface{}) (map[[32]byte][][32]byte, error) {
+	dict, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, &FieldError{Path: "piece layers", Err: ErrInvalidField}
+	}
+	layers := make(map[[32]byte][][32]byte, len(dict))
+	for key, value := range dict {
+		hashes, ok := value.(string)
+		if len(key) != sha256.Size || !ok || len(hashes) == 0 || len(hashes)%sha256.Size != 0 {
+			return nil, &FieldError{Path: fmt.Sprintf("piece layers[%x]", key), Err: ErrInvalidField}
+		}
+		var root [32]byte
+		copy(root[:], key)
+		layer := make([][32]byte, len(hashes)/sha256.Size)
+		for i := range layer {
+			copy(layer[i][:], hashes[i*sha256.Size:])
+		}
+		layers[root] = layer
+	}
+	return layers, nil
+}
+
+// pieceLayerHeight returns the height above the leaves of the merkle tree
+// layer holding one node per piece.
+func pieceLayerHeight(pieceLength int64) int {
+	return bits.TrailingZeros64(uint64(pieceLength / BlockSize))
+}
+
+// padHash returns the root of a merkle subtree of the given height whose
+// leaves are all zero.
+func padHash(height int) [32]byte {
+	var hash [32]byte
+	for ; height > 0; height-- {
+		hash = hashPair(hash, hash)
+	}
+	return hash
+}
+
+func hashPair(left, right [32]byte) [32]byte {
+	var buf [64]byte
+	copy(buf[:32], left[:])
+	copy(buf[32:], right[:])
+	return sha256.Sum256(buf[:])
+}
+
+// merkleRoot returns the root of the merkle tree over nodes, padded with pad
+// to a power of two. It returns pad itself when nodes is empty.
+func merkleRoot(nodes [][32]byte, pad [32]byte) [32]byte {
+	if len(nodes) == 0 {
+		return pad
+	}
+	layer := append([][32]byte(nil), nodes...)
+	for len(layer) > 1 {
+		if len(layer)%2 == 1 {
+			layer = append(layer, pad)
+		}
+		next := layer[:len(layer)/2]
+		for i := range next {
+			next[i] = hashPair(layer[2*i], layer[2*i+1])
+		}
+		layer = next
+		pad = hashPair(pad, pad)
+	}
+	return layer[0]
+}
+
+// merkleLayers returns the pieces root of a file with the given leaf block
+// hashes, and its piece layer if the file is larger than one piece.
+func merkleLayers(leaves [][32]byte, pieceLength int64) ([32]byte, [][32]byte) {
+	height := pieceLayerHeight(pieceLength)
+	blocksPerPiece := 1 << height
+	if len(leaves) <= blocksPerPiece {
+		return merkleRoot(leaves, [32]byte{}), nil
+	}
+	layer := make([][32]byte, 0, (len(leaves)+blocksPerPiece-1)/blocksPerPiece)
+	piece := make([][32]byte, blocksPerPiece)
+	for i := 0; i < len(leaves); i += blocksPerPiece {
+		// The last piece's subtree is padded with zero leaves up to a full
+		// piece rather than to the next power of two of its blocks.
+		clear(piece[copy(piece, leaves[i:]):])
+		layer = append(layer, merkleRoot(piece, [32]byte{}))
+	}
+	return merkleRoot(layer, padHash(height)), layer
+}
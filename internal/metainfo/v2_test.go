@@ -0,0 +1,216 @@
+package metainfo
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// naiveRoot computes a v2 pieces root directly from the file data, padding the
+// leaves with zeros to a power of two.
+func naiveRoot(data []byte) [32]byte {
+	var layer [][32]byte
+	for i := 0; i < len(data); i += BlockSize {
+		layer = append(layer, sha256.Sum256(data[i:min(i+BlockSize, len(data))]))
+	}
+	for len(layer)&(len(layer)-1) != 0 {
+		layer = append(layer, [32]byte{})
+	}
+	for len(layer) > 1 {
+		var next [][32]byte
+		for i := 0; i < len(layer); i += 2 {
+			next = append(next, sha256.Sum256(append(layer[i][:], layer[i+1][:]...)))
+		}
+		layer = next
+	}
+	return layer[0]
+}
+
+func buildV2(t *testing.T, files map[string][]byte, pieceLength int64) (*MetaInfo, []byte) {
+	t.Helper()
+	root := filepath.Join(t.TempDir(), "v2")
+	writeTestFiles(t, root, files)
+	b := Builder{AnnounceList: [][]string{{"http://a/announce"}}, PieceLength: pieceLength, Version: V2, Workers: 3}
+	var buf bytes.Buffer
+	if _, err := b.Build(&buf, root); err != nil {
+		t.Fatal(err)
+	}
+	m, err := Parse(bufio.NewReader(bytes.NewReader(buf.Bytes())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, buf.Bytes()
+}
+
+func TestBuilderV2(t *testing.T) {
+	const pieceLength = 32 << 10
+	files := map[string][]byte{
+		"a/small.txt":  []byte("hello"),
+		"a/one.bin":    testData(1, pieceLength),
+		"b/large.bin":  testData(2, 5*pieceLength+BlockSize+7),
+		"b/blocks.bin": testData(3, 3*BlockSize),
+		"empty":        nil,
+	}
+	m, data := buildV2(t, files, pieceLength)
+
+	if !m.Info.HasV2() || m.Info.HasV1() {
+		t.Fatalf("expected a v2 only torrent, got %v", m.Info)
+	}
+	if bytes.Contains(data, []byte("6:pieces")) {
+		t.Error("expected no v1 pieces in a v2 only torrent")
+	}
+
+	order := []string{"a/one.bin", "a/small.txt", "b/blocks.bin", "b/large.bin", "empty"}
+	if len(m.Info.FileTree) != len(order) {
+		t.Fatalf("expected %d files, got %d", len(order), len(m.Info.FileTree))
+	}
+	for i, name := range order {
+		file := m.Info.FileTree[i]
+		if strings.Join(file.Path, "/") != name {
+			t.Errorf("file %d: expected %q, got %q", i, name, file.Path)
+		}
+		if file.Length != int64(len(files[name])) {
+			t.Errorf("file %d: expected length %d, got %d", i, len(files[name]), file.Length)
+		}
+		var expectedRoot [32]byte
+		if len(files[name]) > 0 {
+			expectedRoot = naiveRoot(files[name])
+		}
+		if file.PiecesRoot != expectedRoot {
+			t.Errorf("file %d: expected pieces root %x, got %x", i, expectedRoot, file.PiecesRoot)
+		}
+		_, hasLayer := m.PieceLayers[file.PiecesRoot]
+		if hasLayer != (file.Length > pieceLength) {
+			t.Errorf("file %d: expected piece layer only for files larger than a piece", i)
+		}
+	}
+	if layer := m.PieceLayers[m.Info.FileTree[3].PiecesRoot]; len(layer) != 6 {
+		t.Errorf("expected 6 hashes in the piece layer, got %d", len(layer))
+	}
+	if err := m.VerifyPieceLayers(); err != nil {
+		t.Error(err)
+	}
+
+	var out bytes.Buffer
+	if _, err := m.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Error("expected WriteTo to reproduce the built torrent")
+	}
+}
+
+func TestBuilderV2SingleFile(t *testing.T) {
+	dir := t.TempDir()
+	data := testData(4, 100000)
+	writeTestFiles(t, dir, map[string][]byte{"file.iso": data})
+	b := Builder{AnnounceList: [][]string{{"http://a/announce"}}, Version: V2}
+	var buf bytes.Buffer
+	m, err := b.Build(&buf, filepath.Join(dir, "file.iso"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Info.FileTree) != 1 || !slices.Equal(m.Info.FileTree[0].Path, []string{"file.iso"}) {
+		t.Fatalf("expected a file tree holding file.iso, got %v", m.Info.FileTree)
+	}
+	if m.Info.FileTree[0].PiecesRoot != naiveRoot(data) {
+		t.Error("expected pieces root to match the content")
+	}
+	if err := m.VerifyPieceLayers(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInfoHashV2(t *testing.T) {
+	m, data := buildV2(t, map[string][]byte{"a": []byte("a")}, 16<<10)
+	start := bytes.Index(data, []byte("4:infod")) + len("4:info")
+	info := data[start : len(data)-1]
+	if m.InfoHashV2() != sha256.Sum256(info) {
+		t.Errorf("expected SHA-256 of the info dictionary")
+	}
+	full, truncated := m.InfoHashV2(), m.TruncatedInfoHashV2()
+	if !bytes.Equal(truncated[:], full[:20]) {
+		t.Errorf("expected truncated hash %x to prefix %x", truncated, full)
+	}
+}
+
+func TestVerifyPieceLayersErrors(t *testing.T) {
+	const pieceLength = 16 << 10
+	files := map[string][]byte{"large": testData(5, 4*pieceLength)}
+
+	tests := []struct {
+		name        string
+		tamper      func(layers map[[32]byte][][32]byte, root [32]byte)
+		expectedErr error
+	}{
+		{"missing", func(layers map[[32]byte][][32]byte, root [32]byte) {
+			delete(layers, root)
+		}, ErrMissingField},
+		{"short", func(layers map[[32]byte][][32]byte, root [32]byte) {
+			layers[root] = layers[root][:3]
+		}, ErrPieceLayerMismatch},
+		{"corrupt", func(layers map[[32]byte][][32]byte, root [32]byte) {
+			layers[root][2][0] ^= 1
+		}, ErrPieceLayerMismatch},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m, _ := buildV2(t, files, pieceLength)
+			test.tamper(m.PieceLayers, m.Info.FileTree[0].PiecesRoot)
+			err := m.VerifyPieceLayers()
+			if !errors.Is(err, test.expectedErr) {
+				t.Errorf("expected error %v, got %v", test.expectedErr, err)
+			}
+			var fieldErr *FieldError
+			if !errors.As(err, &fieldErr) || !strings.HasPrefix(fieldErr.Path, "piece layers[") {
+				t.Errorf("expected a piece layers field error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestParseV2Errors(t *testing.T) {
+	root := strings.Repeat("r", 32)
+	tests := []struct {
+		input        string
+		expectedErr  error
+		expectedPath string
+	}{
+		{"d8:announce1:a4:infod9:file treed1:ad0:d6:lengthi1e11:pieces root32:" + root + "eee12:meta versioni3e4:name1:a12:piece lengthi16384eee", ErrInvalidField, "info.meta version"},
+		{"d8:announce1:a4:infod12:meta versioni2e4:name1:a12:piece lengthi16384eee", ErrMissingField, "info.file tree"},
+		{"d8:announce1:a4:infod9:file treede12:meta versioni2e4:name1:a12:piece lengthi16384eee", ErrNoFiles, "info.file tree"},
+		{"d8:announce1:a4:infod9:file treed1:ad0:d6:lengthi1e11:pieces root32:" + root + "eee12:meta versioni2e4:name1:a12:piece lengthi20000eee", ErrInvalidField, "info.piece length"},
+		{"d8:announce1:a4:infod9:file treed1:ad1:bd0:d6:lengthi1eeeee12:meta versioni2e4:name1:a12:piece lengthi16384eee", ErrMissingField, `info.file tree["a"]["b"][""].pieces root`},
+		{"d8:announce1:a4:infod9:file treed1:ad0:d11:pieces root32:" + root + "eee12:meta versioni2e4:name1:a12:piece lengthi16384eee", ErrMissingField, `info.file tree["a"][""].length`},
+		{"d8:announce1:a4:infod9:file treed1:ai1ee12:meta versioni2e4:name1:a12:piece lengthi16384eee", ErrInvalidField, `info.file tree["a"]`},
+		{"d8:announce1:a4:infod9:file treed1:ad0:d6:lengthi0eeee12:meta versioni2e4:name1:a12:piece lengthi16384ee12:piece layersd1:x1:yee", ErrInvalidField, "piece layers[78]"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expectedPath, func(t *testing.T) {
+			_, err := Parse(bufio.NewReader(strings.NewReader(test.input)))
+			if !errors.Is(err, test.expectedErr) {
+				t.Fatalf("expected error %v, got %v", test.expectedErr, err)
+			}
+			var fieldErr *FieldError
+			if !errors.As(err, &fieldErr) || fieldErr.Path != test.expectedPath {
+				t.Errorf("expected path %q, got %v", test.expectedPath, err)
+			}
+		})
+	}
+}
+
+func TestBuilderUnsupportedVersion(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string][]byte{"a": []byte("a")})
+	b := Builder{AnnounceList: [][]string{{"a"}}, Version: 4}
+	if _, err := b.Build(&bytes.Buffer{}, dir); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("expected error %v, got %v", ErrUnsupportedVersion, err)
+	}
+}
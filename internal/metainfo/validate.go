@@ -0,0 +1,328 @@
+package metainfo
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/stupoid/torrent/internal/bencode"
+)
+
+var (
+	ErrNonCanonical  = errors.New("non-canonical encoding")
+	ErrDuplicatePath = errors.New("duplicate file path")
+	ErrInvalidUTF8   = errors.New("invalid UTF-8")
+	ErrReservedName  = errors.New("reserved file name")
+)
+
+// Severity tells how serious a Finding is.
+type Severity int
+
+const (
+	// SeverityWarning marks a problem that most clients cope with, but that
+	// may keep some of them from handling the torrent correctly.
+	SeverityWarning Severity = iota
+	// SeverityError marks a torrent that clients reject or mishandle.
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+// Finding is a problem reported by Validate, identified by the key path of
+// the offending field like a FieldError.
+type Finding struct {
+	Severity Severity
+	Path     string
+	Err      error
+}
+
+func (f Finding) Error() string {
+	if f.Path == "" {
+		return fmt.Sprintf("%s: %v", f.Severity, f.Err)
+	}
+	return fmt.Sprintf("%s: %s: %v", f.Severity, f.Path, f.Err)
+}
+
+func (f Finding) Unwrap() error {
+	return f.Err
+}
+
+// Report lists the findings of Validate, in the order they were found.
+type Report []Finding
+
+// HasErrors reports whether r holds any finding of SeverityError.
+func (r Report) HasErrors() bool {
+	for _, f := range r {
+		if f.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns the findings of SeverityError joined into one error, or nil if
+// there are none.
+func (r Report) Err() error {
+	var errs []error
+	for _, f := range r {
+		if f.Severity == SeverityError {
+			errs = append(errs, f)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *Report) add(severity Severity, err error) {
+	var fieldErr *FieldError
+	if errors.As(err, &fieldErr) {
+		*r = append(*r, Finding{Severity: severity, Path: fieldErr.Path, Err: fieldErr.Err})
+		return
+	}
+	*r = append(*r, Finding{Severity: severity, Err: err})
+}
+
+func (r *Report) addf(severity Severity, path string, err error, format string, args ...interface{}) {
+	if format != "" {
+		err = fmt.Errorf("%w: "+format, append([]interface{}{err}, args...)...)
+	}
+	*r = append(*r, Finding{Severity: severity, Path: path, Err: err})
+}
+
+// ValidateBytes parses a torrent and validates it like Validate. Unlike
+// Parse, it keeps going past a pieces string whose length is not a multiple
+// of 20 or does not match the total length, and it also reports
+// non-canonical encoding outside the info dictionary, as warnings. A torrent
+// that cannot be parsed at all is reported as a single error.
+func ValidateBytes(data []byte) Report {
+	var report Report
+	dec := bencode.NewDecoder(bufio.NewReader(bytes.NewReader(data)))
+	found, _ := dec.NonCanonical()
+	for _, err := range found {
+		var syntaxErr *bencode.SyntaxError
+		if errors.As(err, &syntaxErr) && !isInfoPath(syntaxErr.Path) {
+			report.addf(SeverityWarning, syntaxErr.Path, ErrNonCanonical, "%v", err)
+		}
+	}
+
+	m, err := parse(data, bencode.DefaultLimits, &report)
+	if err != nil {
+		report.add(SeverityError, err)
+		return report
+	}
+	return append(report, m.Validate()...)
+}
+
+// Validate checks m for problems that Parse lets through, or that were
+// introduced by editing m, and returns all of them:
+//
+//   - Extra values that bencode cannot represent, which keep m from being
+//     written
+//   - malformed values of known keys, which Parse leaves in Extra
+//   - an info dictionary that is not canonically encoded, which clients
+//     re-encoding it would give another info hash
+//   - a piece length that is not positive, or not a power of two
+//   - a number of piece hashes that does not match the total length
+//   - negative file lengths
+//   - empty, duplicate or conflicting file paths
+//   - path components that could escape the torrent's directory, such as
+//     "..", absolute paths and drive letters
+//   - file names reserved on Windows, such as NUL or COM1
+//   - names and paths that are not valid UTF-8
+func (m MetaInfo) Validate() Report {
+	var report Report
+	info := m.Info
+
+	if err := checkExtra("", m.Extra); err != nil {
+		report.add(SeverityError, err)
+	}
+	validateExtra(&report, m)
+	if encoded, err := m.encodedInfo(); err != nil {
+		report.add(SeverityError, err)
+	} else {
+		dec := bencode.NewDecoder(bufio.NewReader(bytes.NewReader(encoded)))
+		found, _ := dec.NonCanonical()
+		for _, err := range found {
+			var syntaxErr *bencode.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				report.addf(SeverityError, infoPath(syntaxErr.Path), ErrNonCanonical, "%v", err)
+			}
+		}
+	}
+
+	switch {
+	case info.PieceLength <= 0:
+		report.addf(SeverityError, "info.piece length", ErrInvalidField, "%d is not positive", info.PieceLength)
+	case bits.OnesCount64(uint64(info.PieceLength)) != 1:
+		report.addf(SeverityWarning, "info.piece length", ErrInvalidField, "%d is not a power of two", info.PieceLength)
+	}
+	if info.HasV1() && info.PieceLength > 0 {
+		if n := info.NumPieces(); n != len(info.Pieces) {
+			report.addf(SeverityError, "info.pieces", ErrPieceCountMismatch, "%d hashes for %d pieces", len(info.Pieces), n)
+		}
+	}
+
+	if !utf8.ValidString(info.Name) {
+		report.addf(SeverityWarning, "info.name", ErrInvalidUTF8, "")
+	}
+	if info.Name != "" {
+		validateComponent(&report, "info.name", info.Name)
+	} else if info.HasV1() && info.Files == nil {
+		report.addf(SeverityError, "info.name", ErrMissingField, "")
+	}
+
+	if info.HasV1() {
+		if info.Files == nil {
+			if info.Length < 0 {
+				report.addf(SeverityError, "info.length", ErrInvalidField, "negative length %d", info.Length)
+			}
+		} else {
+			validateFiles(&report, info.Files,
+				func(i int) string { return fmt.Sprintf("info.files[%d]", i) },
+				func(i, j int) string { return fmt.Sprintf("info.files[%d].path[%d]", i, j) })
+		}
+	}
+	if info.HasV2() {
+		treeFile := func(i int) string { return "info.file tree" + treePath(info.FileTree[i].Path) }
+		validateFiles(&report, info.FileTree, treeFile,
+			func(i, j int) string { return "info.file tree" + treePath(info.FileTree[i].Path[:j+1]) })
+	}
+	return report
+}
+
+// validateExtra reports the known keys that Parse left in Extra because their
+// values are malformed. Clients ignore them, so they are only warnings.
+func validateExtra(report *Report, m MetaInfo) {
+	if announceList, ok := m.Extra["announce-list"]; ok {
+		if _, err := parseAnnounceList(announceList); err != nil {
+			report.add(SeverityWarning, err)
+		}
+	}
+	if urlList, ok := m.Extra["url-list"]; ok {
+		if _, err := parseURLList(urlList); err != nil {
+			report.add(SeverityWarning, err)
+		}
+	}
+	if httpSeeds, ok := m.Extra["httpseeds"]; ok {
+		if _, err := parseStrings("httpseeds", httpSeeds); err != nil {
+			report.add(SeverityWarning, err)
+		}
+	}
+	if nodes, ok := m.Extra["nodes"]; ok {
+		if _, ok := parseNodes(nodes); !ok {
+			report.addf(SeverityWarning, "nodes", ErrInvalidField, "")
+		}
+	}
+	if similar, ok := m.Info.Extra["similar"]; ok {
+		if _, ok := parseSimilar(similar); !ok {
+			report.addf(SeverityWarning, "info.similar", ErrInvalidField, "")
+		}
+	}
+	if collections, ok := m.Info.Extra["collections"]; ok {
+		if _, err := parseStrings("info.collections", collections); err != nil {
+			report.add(SeverityWarning, err)
+		}
+	}
+}
+
+// validateFiles checks a file list, naming each file with path and each of
+// its path components with componentPath.
+func validateFiles(report *Report, files []File, path func(i int) string, componentPath func(i, j int) string) {
+	seen := make(map[string]bool, len(files))
+	dirs := make(map[string]bool)
+	for i, file := range files {
+		if file.Length < 0 {
+			report.addf(SeverityError, path(i)+".length", ErrInvalidField, "negative length %d", file.Length)
+		}
+		if len(file.Path) == 0 {
+			report.addf(SeverityError, path(i)+".path", ErrUnsafePath, "empty path")
+			continue
+		}
+		for j, component := range file.Path {
+			if !utf8.ValidString(component) {
+				report.addf(SeverityWarning, componentPath(i, j), ErrInvalidUTF8, "")
+			}
+			validateComponent(report, componentPath(i, j), component)
+		}
+
+		// Padding files may share a path, since they hold nothing but zeros.
+		if file.IsPadding() {
+			continue
+		}
+		joined := strings.Join(file.Path, "/")
+		if seen[joined] || dirs[joined] {
+			report.addf(SeverityError, path(i)+".path", ErrDuplicatePath, "%q", joined)
+		}
+		seen[joined] = true
+		for j := 1; j < len(file.Path); j++ {
+			dir := strings.Join(file.Path[:j], "/")
+			if seen[dir] {
+				report.addf(SeverityError, path(i)+".path", ErrDuplicatePath, "%q is also a file", dir)
+			}
+			dirs[dir] = true
+		}
+	}
+}
+
+// validateComponent checks a single file or directory name, which must not be
+// able to escape the torrent's directory on any OS.
+func validateComponent(report *Report, path, name string) {
+	switch {
+	case name == "" || name == "." || name == "..":
+		report.addf(SeverityError, path, ErrUnsafePath, "%q", name)
+	case strings.ContainsAny(name, "/\\\x00"):
+		report.addf(SeverityError, path, ErrUnsafePath, "%q contains a path separator", name)
+	case len(name) >= 2 && name[1] == ':' && isASCIILetter(name[0]):
+		report.addf(SeverityError, path, ErrUnsafePath, "%q starts with a drive letter", name)
+	case isReservedName(name):
+		report.addf(SeverityWarning, path, ErrReservedName, "%q", name)
+	}
+}
+
+// isReservedName reports whether Windows reserves name for a device, or
+// would strip its trailing dots or spaces.
+func isReservedName(name string) bool {
+	if strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") {
+		return true
+	}
+	base, _, _ := strings.Cut(name, ".")
+	base = strings.ToUpper(strings.TrimRight(base, " "))
+	switch base {
+	case "CON", "PRN", "AUX", "NUL":
+		return true
+	}
+	return len(base) == 4 && (strings.HasPrefix(base, "COM") || strings.HasPrefix(base, "LPT")) &&
+		base[3] >= '1' && base[3] <= '9'
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// infoPath returns the path within the torrent of path within its info
+// dictionary.
+func infoPath(path string) string {
+	switch {
+	case path == "":
+		return "info"
+	case path[0] == '[':
+		return "info" + path
+	}
+	return "info." + path
+}
+
+func isInfoPath(path string) bool {
+	return path == "info" || strings.HasPrefix(path, "info.") || strings.HasPrefix(path, "info[")
+}
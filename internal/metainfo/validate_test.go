@@ -0,0 +1,203 @@
+package metainfo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func validInfo() Info {
+	return Info{
+		PieceLength: 16,
+		Pieces:      make([][20]byte, 2),
+		Name:        "t",
+		Files: []File{
+			{Length: 10, Path: []string{"a"}},
+			{Length: 10, Path: []string{"b", "c"}},
+		},
+	}
+}
+
+func hasFinding(report Report, severity Severity, path string, err error) bool {
+	for _, f := range report {
+		if f.Severity == severity && f.Path == path && errors.Is(f, err) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestValidate(t *testing.T) {
+	if report := (MetaInfo{Announce: "a", Info: validInfo()}).Validate(); len(report) != 0 {
+		t.Fatalf("expected no findings, got %v", report)
+	}
+
+	tests := []struct {
+		name     string
+		edit     func(info *Info)
+		severity Severity
+		path     string
+		err      error
+	}{
+		{"zero piece length", func(i *Info) { i.PieceLength = 0 }, SeverityError, "info.piece length", ErrInvalidField},
+		{"odd piece length", func(i *Info) { i.PieceLength = 20; i.Pieces = i.Pieces[:1] }, SeverityWarning, "info.piece length", ErrInvalidField},
+		{"missing hash", func(i *Info) { i.Pieces = i.Pieces[:1] }, SeverityError, "info.pieces", ErrPieceCountMismatch},
+		{"extra hash", func(i *Info) { i.Pieces = make([][20]byte, 3) }, SeverityError, "info.pieces", ErrPieceCountMismatch},
+		{"negative length", func(i *Info) { i.Files[1].Length = -4 }, SeverityError, "info.files[1].length", ErrInvalidField},
+		{"negative single length", func(i *Info) { i.Files = nil; i.Length = -1; i.Pieces = nil }, SeverityError, "info.length", ErrInvalidField},
+		{"empty path", func(i *Info) { i.Files[0].Path = nil }, SeverityError, "info.files[0].path", ErrUnsafePath},
+		{"empty component", func(i *Info) { i.Files[1].Path = []string{"b", ""} }, SeverityError, "info.files[1].path[1]", ErrUnsafePath},
+		{"dot dot", func(i *Info) { i.Files[1].Path = []string{"..", "c"} }, SeverityError, "info.files[1].path[0]", ErrUnsafePath},
+		{"absolute", func(i *Info) { i.Files[0].Path = []string{"/etc"} }, SeverityError, "info.files[0].path[0]", ErrUnsafePath},
+		{"backslash", func(i *Info) { i.Files[0].Path = []string{`..\a`} }, SeverityError, "info.files[0].path[0]", ErrUnsafePath},
+		{"drive letter", func(i *Info) { i.Files[0].Path = []string{"C:a"} }, SeverityError, "info.files[0].path[0]", ErrUnsafePath},
+		{"unsafe name", func(i *Info) { i.Name = ".." }, SeverityError, "info.name", ErrUnsafePath},
+		{"missing name", func(i *Info) { i.Files = nil; i.Length = 20; i.Name = "" }, SeverityError, "info.name", ErrMissingField},
+		{"reserved name", func(i *Info) { i.Files[0].Path = []string{"nul.txt"} }, SeverityWarning, "info.files[0].path[0]", ErrReservedName},
+		{"reserved device", func(i *Info) { i.Files[1].Path = []string{"COM1", "c"} }, SeverityWarning, "info.files[1].path[0]", ErrReservedName},
+		{"trailing dot", func(i *Info) { i.Files[0].Path = []string{"a."} }, SeverityWarning, "info.files[0].path[0]", ErrReservedName},
+		{"invalid utf-8 path", func(i *Info) { i.Files[0].Path = []string{"\xff"} }, SeverityWarning, "info.files[0].path[0]", ErrInvalidUTF8},
+		{"invalid utf-8 name", func(i *Info) { i.Name = "\xfe" }, SeverityWarning, "info.name", ErrInvalidUTF8},
+		{"duplicate path", func(i *Info) { i.Files[1].Path = []string{"a"} }, SeverityError, "info.files[1].path", ErrDuplicatePath},
+		{"file and directory", func(i *Info) { i.Files[0].Path = []string{"b"} }, SeverityError, "info.files[1].path", ErrDuplicatePath},
+		{"directory and file", func(i *Info) { i.Files[0].Path = []string{"b", "c", "d"} }, SeverityError, "info.files[1].path", ErrDuplicatePath},
+		{"unencodable extra", func(i *Info) { i.Extra = map[string]interface{}{"x": 1.5} }, SeverityError, "info.x", ErrInvalidField},
+		{"unencodable file extra", func(i *Info) { i.Files[1].Extra = map[string]interface{}{"x": []float64{1}} }, SeverityError, "info.files[1].x", ErrInvalidField},
+		{"v2 unsafe path", func(i *Info) {
+			i.MetaVersion = 2
+			i.FileTree = []File{{Length: 20, Path: []string{".."}}}
+		}, SeverityError, `info.file tree[".."]`, ErrUnsafePath},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			info := validInfo()
+			test.edit(&info)
+			report := MetaInfo{Announce: "a", Info: info}.Validate()
+			if !hasFinding(report, test.severity, test.path, test.err) {
+				t.Errorf("expected %s at %s wrapping %v, got %v", test.severity, test.path, test.err, report)
+			}
+			if report.HasErrors() != (test.severity == SeverityError) || (report.Err() != nil) != report.HasErrors() {
+				t.Errorf("expected HasErrors to be %v, got %v", test.severity == SeverityError, report)
+			}
+		})
+	}
+}
+
+func TestValidatePaddingFiles(t *testing.T) {
+	info := validInfo()
+	info.PieceLength = 16
+	info.Pieces = make([][20]byte, 3)
+	info.Files = []File{
+		{Length: 10, Path: []string{"a"}},
+		{Length: 6, Path: []string{".pad", "6"}, Attr: "p"},
+		{Length: 10, Path: []string{"b"}},
+		{Length: 6, Path: []string{".pad", "6"}, Attr: "p"},
+		{Length: 10, Path: []string{"c"}},
+	}
+	if report := (MetaInfo{Info: info}).Validate(); len(report) != 0 {
+		t.Errorf("expected padding files to share a path, got %v", report)
+	}
+}
+
+func TestValidateBytes(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "test", "multi.torrent"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report := ValidateBytes(data); len(report) != 0 {
+		t.Errorf("expected no findings for multi.torrent, got %v", report)
+	}
+
+	type finding struct {
+		severity Severity
+		path     string
+		err      error
+	}
+	tests := []struct {
+		name     string
+		input    string
+		expected []finding
+	}{
+		{
+			"pieces length",
+			"d8:announce1:a4:infod6:lengthi1e4:name1:a12:piece lengthi1e6:pieces7:abcdefgee",
+			[]finding{
+				{SeverityError, "info.pieces", ErrInvalidField},
+				{SeverityError, "info.pieces", ErrPieceCountMismatch},
+			},
+		},
+		{
+			"all problems",
+			"d8:announce1:a4:infod5:filesld6:lengthi-1e4:pathl2:..eed6:lengthi1e4:pathl3:NULeee4:name1:a12:piece lengthi3e6:pieces0:ee",
+			[]finding{
+				{SeverityWarning, "info.piece length", ErrInvalidField},
+				{SeverityError, "info.files[0].length", ErrInvalidField},
+				{SeverityError, "info.files[0].path[0]", ErrUnsafePath},
+				{SeverityWarning, "info.files[1].path[0]", ErrReservedName},
+			},
+		},
+		{
+			"unsorted info",
+			"d8:announce1:a4:infod4:name1:a6:lengthi0e12:piece lengthi1e6:pieces0:ee",
+			[]finding{{SeverityError, "info.length", ErrNonCanonical}},
+		},
+		{
+			"every info problem",
+			"d8:announce1:a4:infod4:name1:a6:lengthi00e12:piece lengthi1e6:pieces0:ee",
+			[]finding{{SeverityError, "info.length", ErrNonCanonical}, {SeverityError, "info.length", ErrNonCanonical}},
+		},
+		{
+			"non-canonical integer",
+			"d8:announce1:a13:creation datei01e4:infod6:lengthi0e4:name1:a12:piece lengthi1e6:pieces0:ee",
+			[]finding{{SeverityWarning, "creation date", ErrNonCanonical}},
+		},
+		{
+			"every problem outside info",
+			"d8:announce01:a13:creation datei01e4:infod6:lengthi0e4:name1:a12:piece lengthi1e6:pieces0:ee",
+			[]finding{{SeverityWarning, "announce", ErrNonCanonical}, {SeverityWarning, "creation date", ErrNonCanonical}},
+		},
+		{
+			"malformed web seeds",
+			"d8:announce1:a9:httpseeds1:x4:infod6:lengthi0e4:name1:a12:piece lengthi1e6:pieces0:e8:url-listl1:xi1eee",
+			[]finding{
+				{SeverityWarning, "httpseeds", ErrInvalidField},
+				{SeverityWarning, "url-list[1]", ErrInvalidField},
+			},
+		},
+		{
+			"malformed announce-list",
+			"d8:announce1:a13:announce-listll1:bel1:ci1eee4:infod6:lengthi0e4:name1:a12:piece lengthi1e6:pieces0:ee",
+			[]finding{{SeverityWarning, "announce-list[1][1]", ErrInvalidField}},
+		},
+		{
+			"malformed extension fields",
+			"d8:announce1:a4:infod11:collectionsi1e6:lengthi0e4:name1:a12:piece lengthi1e6:pieces0:7:similarl1:xee5:nodesll1:hi70000eeee",
+			[]finding{
+				{SeverityWarning, "nodes", ErrInvalidField},
+				{SeverityWarning, "info.similar", ErrInvalidField},
+				{SeverityWarning, "info.collections", ErrInvalidField},
+			},
+		},
+		{
+			"unparsable",
+			"d8:announce1:ae",
+			[]finding{{SeverityError, "info", ErrMissingField}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			report := ValidateBytes([]byte(test.input))
+			if len(report) != len(test.expected) {
+				t.Fatalf("expected %d findings, got %v", len(test.expected), report)
+			}
+			for _, f := range test.expected {
+				if !hasFinding(report, f.severity, f.path, f.err) {
+					t.Errorf("expected %s at %s wrapping %v, got %v", f.severity, f.path, f.err, report)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package tracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+)
+
+// DualStack is a Tracker announcing to the same tracker over both IPv4 and
+// IPv6, so that peers of either address family can find the client.
+type DualStack struct {
+	IPv4 Tracker
+	IPv6 Tracker
+}
+
+// NewDualStack returns a DualStack for the tracker with the given announce
+// URL, whose two clients connect to its IPv4 and IPv6 addresses.
+func NewDualStack(announceURL string) (*DualStack, error) {
+	u, err := url.Parse(announceURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidURL, err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return &DualStack{
+			IPv4: &HTTPTracker{URL: announceURL, Network: "tcp4"},
+			IPv6: &HTTPTracker{URL: announceURL, Network: "tcp6"},
+		}, nil
+	case "udp":
+		return &DualStack{
+			IPv4: &UDPTracker{URL: announceURL, Network: "udp4"},
+			IPv6: &UDPTracker{URL: announceURL, Network: "udp6"},
+		}, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported scheme %q", ErrInvalidURL, u.Scheme)
+	}
+}
+
+// Announce announces req over both address families at once and merges the
+// responses, keeping the longer intervals and the larger swarm counts. It only
+// fails if both announces do, which happens to hosts with a single family.
+func (d *DualStack) Announce(ctx context.Context, req AnnounceRequest) (*AnnounceResponse, error) {
+	var wg sync.WaitGroup
+	var resps [2]*AnnounceResponse
+	var errs [2]error
+	for i, t := range []Tracker{d.IPv4, d.IPv6} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resps[i], errs[i] = t.Announce(ctx, req)
+		}()
+	}
+	wg.Wait()
+
+	var merged *AnnounceResponse
+	seen := make(map[Peer]bool)
+	for _, resp := range resps {
+		if resp == nil {
+			continue
+		}
+		if merged == nil {
+			merged = &AnnounceResponse{TrackerID: resp.TrackerID, WarningMessage: resp.WarningMessage, Peers: []Peer{}}
+		}
+		merged.Interval = max(merged.Interval, resp.Interval)
+		merged.MinInterval = max(merged.MinInterval, resp.MinInterval)
+		merged.Seeders = max(merged.Seeders, resp.Seeders)
+		merged.Leechers = max(merged.Leechers, resp.Leechers)
+		for _, peer := range resp.Peers {
+			if !seen[peer] {
+				seen[peer] = true
+				merged.Peers = append(merged.Peers, peer)
+			}
+		}
+	}
+	if merged == nil {
+		return nil, errors.Join(errs[0], errs[1])
+	}
+	return merged, nil
+}
+
+// Scrape scrapes over IPv4, falling back to IPv6 if that fails.
+func (d *DualStack) Scrape(ctx context.Context, infoHashes [][20]byte) (map[[20]byte]ScrapeResult, error) {
+	results, err := d.IPv4.Scrape(ctx, infoHashes)
+	if err == nil {
+		return results, nil
+	}
+	results, err6 := d.IPv6.Scrape(ctx, infoHashes)
+	if err6 != nil {
+		return nil, errors.Join(err, err6)
+	}
+	return results, nil
+}
@@ -0,0 +1,147 @@
+package tracker
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeTracker answers every announce and scrape with resp or err.
+type fakeTracker struct {
+	resp    *AnnounceResponse
+	scrape  map[[20]byte]ScrapeResult
+	err     error
+	scraped bool
+}
+
+func (f *fakeTracker) Announce(ctx context.Context, req AnnounceRequest) (*AnnounceResponse, error) {
+	return f.resp, f.err
+}
+
+func (f *fakeTracker) Scrape(ctx context.Context, infoHashes [][20]byte) (map[[20]byte]ScrapeResult, error) {
+	f.scraped = true
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.scrape, nil
+}
+
+func TestDualStackAnnounce(t *testing.T) {
+	shared := Peer{Addr: netip.MustParseAddrPort("10.0.0.1:6881")}
+	ipv4 := &fakeTracker{resp: &AnnounceResponse{
+		Interval:  30 * time.Minute,
+		TrackerID: "v4",
+		Seeders:   5,
+		Leechers:  1,
+		Peers:     []Peer{shared},
+	}}
+	ipv6 := &fakeTracker{resp: &AnnounceResponse{
+		Interval:    time.Hour,
+		MinInterval: time.Minute,
+		TrackerID:   "v6",
+		Seeders:     3,
+		Leechers:    2,
+		Peers:       []Peer{{Addr: netip.MustParseAddrPort("[2001:db8::1]:6881")}, shared},
+	}}
+	errFake := errors.New("network is unreachable")
+
+	tests := []struct {
+		name     string
+		ipv4     *fakeTracker
+		ipv6     *fakeTracker
+		expected *AnnounceResponse
+	}{
+		{
+			name: "both",
+			ipv4: ipv4,
+			ipv6: ipv6,
+			expected: &AnnounceResponse{
+				Interval:    time.Hour,
+				MinInterval: time.Minute,
+				TrackerID:   "v4",
+				Seeders:     5,
+				Leechers:    2,
+				Peers:       []Peer{shared, {Addr: netip.MustParseAddrPort("[2001:db8::1]:6881")}},
+			},
+		},
+		{
+			name:     "IPv4 only",
+			ipv4:     ipv4,
+			ipv6:     &fakeTracker{err: errFake},
+			expected: &AnnounceResponse{Interval: 30 * time.Minute, TrackerID: "v4", Seeders: 5, Leechers: 1, Peers: []Peer{shared}},
+		},
+		{
+			name: "IPv6 only",
+			ipv4: &fakeTracker{err: errFake},
+			ipv6: ipv6,
+			expected: &AnnounceResponse{
+				Interval:    time.Hour,
+				MinInterval: time.Minute,
+				TrackerID:   "v6",
+				Seeders:     3,
+				Leechers:    2,
+				Peers:       ipv6.resp.Peers,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := (&DualStack{IPv4: test.ipv4, IPv6: test.ipv6}).Announce(context.Background(), testRequest())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(resp, test.expected) {
+				t.Errorf("expected %+v, got %+v", test.expected, resp)
+			}
+		})
+	}
+
+	failure := &FailureError{Reason: "banned"}
+	d := &DualStack{IPv4: &fakeTracker{err: errFake}, IPv6: &fakeTracker{err: failure}}
+	if _, err := d.Announce(context.Background(), testRequest()); !errors.Is(err, errFake) || !errors.Is(err, failure) {
+		t.Errorf("expected both errors, got %v", err)
+	}
+}
+
+func TestDualStackScrape(t *testing.T) {
+	results := map[[20]byte]ScrapeResult{{1}: {Seeders: 1}}
+
+	ipv4, ipv6 := &fakeTracker{scrape: results}, &fakeTracker{}
+	if got, err := (&DualStack{IPv4: ipv4, IPv6: ipv6}).Scrape(context.Background(), [][20]byte{{1}}); err != nil || !reflect.DeepEqual(got, results) {
+		t.Errorf("expected %v, got %v, %v", results, got, err)
+	}
+	if ipv6.scraped {
+		t.Error("expected no IPv6 scrape after a successful IPv4 one")
+	}
+
+	ipv4, ipv6 = &fakeTracker{err: errors.New("unreachable")}, &fakeTracker{scrape: results}
+	if got, err := (&DualStack{IPv4: ipv4, IPv6: ipv6}).Scrape(context.Background(), [][20]byte{{1}}); err != nil || !reflect.DeepEqual(got, results) {
+		t.Errorf("expected %v from the IPv6 fallback, got %v, %v", results, got, err)
+	}
+}
+
+func TestNewDualStack(t *testing.T) {
+	d, err := NewDualStack("udp://example.com:6969")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.IPv4.(*UDPTracker).Network != "udp4" || d.IPv6.(*UDPTracker).Network != "udp6" {
+		t.Errorf("expected UDP trackers restricted to each family, got %#v", d)
+	}
+
+	d, err = NewDualStack("https://example.com/announce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.IPv4.(*HTTPTracker).Network != "tcp4" || d.IPv6.(*HTTPTracker).Network != "tcp6" {
+		t.Errorf("expected HTTP trackers restricted to each family, got %#v", d)
+	}
+
+	if _, err := NewDualStack("ftp://example.com"); !errors.Is(err, ErrInvalidURL) {
+		t.Errorf("expected %v, got %v", ErrInvalidURL, err)
+	}
+}
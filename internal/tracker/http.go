@@ -0,0 +1,323 @@
+package tracker
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stupoid/torrent/internal/bencode"
+)
+
+const (
+	// maxResponseSize bounds the size of HTTP tracker responses that are
+	// read.
+	maxResponseSize = 1 << 20
+
+	// httpScrapeBatch is the number of info hashes per HTTP scrape request,
+	// which keeps the URL short enough for common servers.
+	httpScrapeBatch = 50
+)
+
+// HTTPTracker is a client for an HTTP or HTTPS tracker (BEP 3). It asks for
+// compact peer lists (BEP 23), and also accepts lists of dictionaries from
+// trackers that ignore the request.
+type HTTPTracker struct {
+	// URL is the announce URL, which may carry a query of its own such as a
+	// passkey.
+	URL string
+
+	// Client is used for the requests, or http.DefaultClient when nil.
+	Client *http.Client
+
+	// Network restricts the connections to the tracker to "tcp4" or "tcp6"
+	// when set. It is ignored when Client is set.
+	Network string
+
+	once          sync.Once
+	networkClient *http.Client
+}
+
+type httpAnnounceResponse struct {
+	FailureReason  string   `bencode:"failure reason"`
+	WarningMessage string   `bencode:"warning message"`
+	Interval       int64    `bencode:"interval"`
+	MinInterval    int64    `bencode:"min interval"`
+	TrackerID      string   `bencode:"tracker id"`
+	Complete       int64    `bencode:"complete"`
+	Incomplete     int64    `bencode:"incomplete"`
+	Peers          peerList `bencode:"peers"`
+	Peers6         string   `bencode:"peers6"`
+}
+
+// Announce sends req to the tracker. A failure reported by the tracker is
+// returned as a *FailureError.
+func (t *HTTPTracker) Announce(ctx context.Context, req AnnounceRequest) (*AnnounceResponse, error) {
+	var resp httpAnnounceResponse
+	if err := t.get(ctx, t.announceURL(req), &resp); err != nil {
+		return nil, err
+	}
+	peers6, err := parseCompactPeers([]byte(resp.Peers6), 16)
+	if err != nil {
+		return nil, err
+	}
+	return &AnnounceResponse{
+		Interval:       time.Duration(resp.Interval) * time.Second,
+		MinInterval:    time.Duration(resp.MinInterval) * time.Second,
+		TrackerID:      resp.TrackerID,
+		WarningMessage: resp.WarningMessage,
+		Seeders:        int(resp.Complete),
+		Leechers:       int(resp.Incomplete),
+		Peers:          append(resp.Peers, peers6...),
+	}, nil
+}
+
+type httpScrapeResponse struct {
+	FailureReason string `bencode:"failure reason"`
+	Files         map[string]struct {
+		Complete   int64 `bencode:"complete"`
+		Downloaded int64 `bencode:"downloaded"`
+		Incomplete int64 `bencode:"incomplete"`
+	} `bencode:"files"`
+}
+
+// Scrape asks the tracker about the swarms of the torrents with the given
+// info hashes, in requests of up to 50 hashes to the URL returned by
+// ScrapeURL.
+func (t *HTTPTracker) Scrape(ctx context.Context, infoHashes [][20]byte) (map[[20]byte]ScrapeResult, error) {
+	scrapeURL, err := ScrapeURL(t.URL)
+	if err != nil {
+		return nil, err
+	}
+	return scrapeBatches(ctx, infoHashes, httpScrapeBatch, func(ctx context.Context, batch [][20]byte) (map[[20]byte]ScrapeResult, error) {
+		var b strings.Builder
+		b.WriteString(scrapeURL)
+		for i, hash := range batch {
+			if i > 0 || strings.Contains(scrapeURL, "?") {
+				b.WriteByte('&')
+			} else {
+				b.WriteByte('?')
+			}
+			b.WriteString("info_hash=" + escapeBytes(hash[:]))
+		}
+
+		var resp httpScrapeResponse
+		if err := t.get(ctx, b.String(), &resp); err != nil {
+			return nil, err
+		}
+		// Trackers may answer with more torrents than asked for.
+		results := make(map[[20]byte]ScrapeResult, len(batch))
+		for _, hash := range batch {
+			if file, ok := resp.Files[string(hash[:])]; ok {
+				results[hash] = ScrapeResult{
+					Seeders:   int(file.Complete),
+					Completed: int(file.Downloaded),
+					Leechers:  int(file.Incomplete),
+				}
+			}
+		}
+		return results, nil
+	})
+}
+
+// ScrapeURL returns the scrape URL of an HTTP tracker, derived from its
+// announce URL by replacing "announce" at the start of the last path segment
+// with "scrape". Announce URLs not following this convention give an error
+// wrapping ErrNoScrape.
+func ScrapeURL(announceURL string) (string, error) {
+	u, err := url.Parse(announceURL)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidURL, err)
+	}
+	path := u.EscapedPath()
+	i := strings.LastIndexByte(path, '/') + 1
+	if !strings.HasPrefix(path[i:], "announce") {
+		return "", fmt.Errorf("%w: %s", ErrNoScrape, announceURL)
+	}
+	u.RawPath = path[:i] + "scrape" + path[i+len("announce"):]
+	if u.Path, err = url.PathUnescape(u.RawPath); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidURL, err)
+	}
+	return u.String(), nil
+}
+
+func (t *HTTPTracker) announceURL(req AnnounceRequest) string {
+	var b strings.Builder
+	b.WriteString(t.URL)
+	if strings.Contains(t.URL, "?") {
+		b.WriteByte('&')
+	} else {
+		b.WriteByte('?')
+	}
+	b.WriteString("info_hash=" + escapeBytes(req.InfoHash[:]))
+	b.WriteString("&peer_id=" + escapeBytes(req.PeerID[:]))
+	b.WriteString("&port=" + strconv.Itoa(int(req.Port)))
+	b.WriteString("&uploaded=" + strconv.FormatInt(req.Uploaded, 10))
+	b.WriteString("&downloaded=" + strconv.FormatInt(req.Downloaded, 10))
+	b.WriteString("&left=" + strconv.FormatInt(req.Left, 10))
+	b.WriteString("&compact=1")
+	if req.Event != EventNone {
+		b.WriteString("&event=" + req.Event.String())
+	}
+	if req.NumWant > 0 {
+		b.WriteString("&numwant=" + strconv.Itoa(req.NumWant))
+	}
+	if req.Key != 0 {
+		b.WriteString(fmt.Sprintf("&key=%08x", req.Key))
+	}
+	if req.TrackerID != "" {
+		b.WriteString("&trackerid=" + url.QueryEscape(req.TrackerID))
+	}
+	if req.IPv4.Is4() {
+		b.WriteString("&ipv4=" + req.IPv4.String())
+	}
+	if req.IPv6.Is6() && !req.IPv6.Is4In6() {
+		b.WriteString("&ipv6=" + url.QueryEscape(req.IPv6.String()))
+	}
+	return b.String()
+}
+
+// get requests rawURL and decodes the bencoded response into v, returning a
+// *FailureError if it holds a failure reason.
+func (t *HTTPTracker) get(ctx context.Context, rawURL string, v interface{ failure() string }) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize+1))
+	if err != nil {
+		return err
+	}
+	if len(body) > maxResponseSize {
+		return fmt.Errorf("%w: larger than %d bytes", ErrInvalidResponse, maxResponseSize)
+	}
+
+	// Some trackers send their failure reason along with an error status.
+	decodeErr := bencode.Unmarshal(body, v)
+	if decodeErr == nil && v.failure() != "" {
+		return &FailureError{Reason: v.failure()}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s", ErrBadStatus, resp.Status)
+	}
+	if decodeErr != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidResponse, decodeErr)
+	}
+	return nil
+}
+
+func (t *HTTPTracker) client() *http.Client {
+	if t.Client != nil {
+		return t.Client
+	}
+	if t.Network == "" {
+		return http.DefaultClient
+	}
+	t.once.Do(func() {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, t.Network, addr)
+		}
+		t.networkClient = &http.Client{Transport: transport}
+	})
+	return t.networkClient
+}
+
+func (r *httpAnnounceResponse) failure() string {
+	return r.FailureReason
+}
+
+func (r *httpScrapeResponse) failure() string {
+	return r.FailureReason
+}
+
+// escapeBytes percent-encodes every byte of b except the unreserved
+// characters of RFC 3986, which is how binary query parameters such as
+// info_hash are sent.
+func escapeBytes(b []byte) string {
+	const hex = "0123456789ABCDEF"
+	var s strings.Builder
+	for _, c := range b {
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("-._~", c) >= 0 {
+			s.WriteByte(c)
+			continue
+		}
+		s.WriteByte('%')
+		s.WriteByte(hex[c>>4])
+		s.WriteByte(hex[c&15])
+	}
+	return s.String()
+}
+
+// peerList decodes the peers of an announce response, given either as a
+// compact string of 6 byte IPv4 addresses and ports (BEP 23) or as a list of
+// dictionaries (BEP 3). Dictionaries whose ip is a host name rather than an
+// address are skipped. Compact IPv6 peers come separately, in peers6 (BEP 7).
+type peerList []Peer
+
+func (p *peerList) UnmarshalBencode(data []byte) error {
+	if len(data) > 0 && data[0] == 'l' {
+		var dicts []struct {
+			ID   string `bencode:"peer id"`
+			IP   string `bencode:"ip"`
+			Port uint16 `bencode:"port"`
+		}
+		if err := bencode.Unmarshal(data, &dicts); err != nil {
+			return err
+		}
+		peers := make([]Peer, 0, len(dicts))
+		for _, dict := range dicts {
+			addr, err := netip.ParseAddr(dict.IP)
+			if err != nil {
+				continue
+			}
+			peer := Peer{Addr: netip.AddrPortFrom(addr.Unmap(), dict.Port)}
+			copy(peer.ID[:], dict.ID)
+			peers = append(peers, peer)
+		}
+		*p = peers
+		return nil
+	}
+
+	var compact string
+	if err := bencode.Unmarshal(data, &compact); err != nil {
+		return err
+	}
+	peers, err := parseCompactPeers([]byte(compact), 4)
+	if err != nil {
+		return err
+	}
+	*p = peers
+	return nil
+}
+
+// parseCompactPeers parses a compact peer list of addresses of addrLen bytes,
+// each followed by a two byte port in network order.
+func parseCompactPeers(b []byte, addrLen int) ([]Peer, error) {
+	size := addrLen + 2
+	if len(b)%size != 0 {
+		return nil, fmt.Errorf("%w: compact peer list of %d bytes", ErrInvalidResponse, len(b))
+	}
+	peers := make([]Peer, 0, len(b)/size)
+	for ; len(b) > 0; b = b[size:] {
+		addr, _ := netip.AddrFromSlice(b[:addrLen])
+		port := binary.BigEndian.Uint16(b[addrLen:size])
+		peers = append(peers, Peer{Addr: netip.AddrPortFrom(addr, port)})
+	}
+	return peers, nil
+}
@@ -0,0 +1,389 @@
+package tracker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stupoid/torrent/internal/bencode"
+)
+
+// stubTracker serves response to every request, recording the last query.
+func stubTracker(t *testing.T, status int, response interface{}) (*httptest.Server, *url.URL) {
+	t.Helper()
+	body, err := bencode.Marshal(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last url.URL
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		last = *r.URL
+		w.WriteHeader(status)
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+	return server, &last
+}
+
+func testRequest() AnnounceRequest {
+	req := AnnounceRequest{
+		Port:       6881,
+		Uploaded:   100,
+		Downloaded: 200,
+		Left:       300,
+	}
+	for i := range req.InfoHash {
+		req.InfoHash[i] = byte(i * 13)
+	}
+	copy(req.PeerID[:], "-GT0001-abcdefgh ~/?")
+	return req
+}
+
+func TestHTTPAnnounceQuery(t *testing.T) {
+	server, last := stubTracker(t, http.StatusOK, map[string]interface{}{"interval": 1800, "peers": ""})
+
+	tests := []struct {
+		name     string
+		path     string
+		modify   func(*AnnounceRequest)
+		expected url.Values
+	}{
+		{
+			name: "defaults",
+			path: "/announce",
+			expected: url.Values{
+				"port": {"6881"}, "uploaded": {"100"}, "downloaded": {"200"}, "left": {"300"}, "compact": {"1"},
+			},
+		},
+		{
+			name: "optional parameters",
+			path: "/announce",
+			modify: func(req *AnnounceRequest) {
+				req.Event = EventStarted
+				req.NumWant = 50
+				req.Key = 0xbeef
+				req.TrackerID = "id &1"
+			},
+			expected: url.Values{
+				"port": {"6881"}, "uploaded": {"100"}, "downloaded": {"200"}, "left": {"300"}, "compact": {"1"},
+				"event": {"started"}, "numwant": {"50"}, "key": {"0000beef"}, "trackerid": {"id &1"},
+			},
+		},
+		{
+			name: "addresses",
+			path: "/announce",
+			modify: func(req *AnnounceRequest) {
+				req.IPv4 = netip.MustParseAddr("203.0.113.5")
+				req.IPv6 = netip.MustParseAddr("2001:db8::5")
+			},
+			expected: url.Values{
+				"port": {"6881"}, "uploaded": {"100"}, "downloaded": {"200"}, "left": {"300"}, "compact": {"1"},
+				"ipv4": {"203.0.113.5"}, "ipv6": {"2001:db8::5"},
+			},
+		},
+		{
+			name: "addresses of the wrong family",
+			path: "/announce",
+			modify: func(req *AnnounceRequest) {
+				req.IPv4 = netip.MustParseAddr("2001:db8::5")
+				req.IPv6 = netip.MustParseAddr("::ffff:203.0.113.5")
+			},
+			expected: url.Values{
+				"port": {"6881"}, "uploaded": {"100"}, "downloaded": {"200"}, "left": {"300"}, "compact": {"1"},
+			},
+		},
+		{
+			name:   "passkey query",
+			path:   "/announce?passkey=secret",
+			modify: func(req *AnnounceRequest) { req.Event = EventStopped },
+			expected: url.Values{
+				"passkey": {"secret"}, "port": {"6881"}, "uploaded": {"100"}, "downloaded": {"200"}, "left": {"300"},
+				"compact": {"1"}, "event": {"stopped"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := testRequest()
+			if test.modify != nil {
+				test.modify(&req)
+			}
+			tracker := &HTTPTracker{URL: server.URL + test.path}
+			if _, err := tracker.Announce(context.Background(), req); err != nil {
+				t.Fatal(err)
+			}
+
+			query, err := url.ParseQuery(last.RawQuery)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if query.Get("info_hash") != string(req.InfoHash[:]) {
+				t.Errorf("expected info_hash %x, got %x", req.InfoHash, query.Get("info_hash"))
+			}
+			if query.Get("peer_id") != string(req.PeerID[:]) {
+				t.Errorf("expected peer_id %q, got %q", req.PeerID, query.Get("peer_id"))
+			}
+			query.Del("info_hash")
+			query.Del("peer_id")
+			if !reflect.DeepEqual(query, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, query)
+			}
+		})
+	}
+}
+
+func TestHTTPAnnounceResponse(t *testing.T) {
+	peerID := "-XX0001-000000000000"
+	var id [20]byte
+	copy(id[:], peerID)
+
+	tests := []struct {
+		name     string
+		response map[string]interface{}
+		expected *AnnounceResponse
+	}{
+		{
+			name: "compact",
+			response: map[string]interface{}{
+				"interval":     1800,
+				"min interval": 900,
+				"complete":     5,
+				"incomplete":   7,
+				"tracker id":   "abc",
+				"peers":        "\x0a\x00\x00\x01\x1a\xe1\xc0\xa8\x01\x02\x00\x50",
+			},
+			expected: &AnnounceResponse{
+				Interval:    30 * time.Minute,
+				MinInterval: 15 * time.Minute,
+				TrackerID:   "abc",
+				Seeders:     5,
+				Leechers:    7,
+				Peers: []Peer{
+					{Addr: netip.MustParseAddrPort("10.0.0.1:6881")},
+					{Addr: netip.MustParseAddrPort("192.168.1.2:80")},
+				},
+			},
+		},
+		{
+			name: "dictionaries",
+			response: map[string]interface{}{
+				"interval":        60,
+				"warning message": "slow down",
+				"peers": []interface{}{
+					map[string]interface{}{"peer id": peerID, "ip": "10.0.0.1", "port": 6881},
+					map[string]interface{}{"peer id": peerID, "ip": "peer.example.com", "port": 6881},
+					map[string]interface{}{"ip": "2001:db8::1", "port": 443},
+				},
+			},
+			expected: &AnnounceResponse{
+				Interval:       time.Minute,
+				WarningMessage: "slow down",
+				Peers: []Peer{
+					{Addr: netip.MustParseAddrPort("10.0.0.1:6881"), ID: id},
+					{Addr: netip.MustParseAddrPort("[2001:db8::1]:443")},
+				},
+			},
+		},
+		{
+			name: "peers6",
+			response: map[string]interface{}{
+				"interval": 60,
+				"peers":    "\x0a\x00\x00\x01\x1a\xe1",
+				"peers6":   "\x20\x01\x0d\xb8\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01\x1a\xe1",
+			},
+			expected: &AnnounceResponse{
+				Interval: time.Minute,
+				Peers: []Peer{
+					{Addr: netip.MustParseAddrPort("10.0.0.1:6881")},
+					{Addr: netip.MustParseAddrPort("[2001:db8::1]:6881")},
+				},
+			},
+		},
+		{
+			name:     "only peers6",
+			response: map[string]interface{}{"interval": 60, "peers6": "\x20\x01\x0d\xb8\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x01\x1a\xe1"},
+			expected: &AnnounceResponse{Interval: time.Minute, Peers: []Peer{{Addr: netip.MustParseAddrPort("[2001:db8::1]:6881")}}},
+		},
+		{
+			name:     "no peers",
+			response: map[string]interface{}{"interval": 60, "peers": ""},
+			expected: &AnnounceResponse{Interval: time.Minute, Peers: []Peer{}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, _ := stubTracker(t, http.StatusOK, test.response)
+			resp, err := (&HTTPTracker{URL: server.URL}).Announce(context.Background(), testRequest())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(resp, test.expected) {
+				t.Errorf("expected %+v, got %+v", test.expected, resp)
+			}
+		})
+	}
+}
+
+func TestHTTPAnnounceErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		response interface{}
+		expected error
+	}{
+		{"bad status", http.StatusNotFound, "not found", ErrBadStatus},
+		{"not a dictionary", http.StatusOK, "oops", ErrInvalidResponse},
+		{"truncated compact peers", http.StatusOK, map[string]interface{}{"interval": 60, "peers": "\x0a\x00\x00\x01\x1a"}, ErrInvalidResponse},
+		{"peers of the wrong type", http.StatusOK, map[string]interface{}{"interval": 60, "peers": 5}, ErrInvalidResponse},
+		{"truncated peers6", http.StatusOK, map[string]interface{}{"interval": 60, "peers6": "\x20\x01\x0d\xb8\x00\x00"}, ErrInvalidResponse},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, _ := stubTracker(t, test.status, test.response)
+			_, err := (&HTTPTracker{URL: server.URL}).Announce(context.Background(), testRequest())
+			if !errors.Is(err, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, err)
+			}
+		})
+	}
+}
+
+func TestHTTPAnnounceFailure(t *testing.T) {
+	for _, status := range []int{http.StatusOK, http.StatusForbidden} {
+		server, _ := stubTracker(t, status, map[string]interface{}{"failure reason": "unregistered torrent"})
+		_, err := (&HTTPTracker{URL: server.URL}).Announce(context.Background(), testRequest())
+		var failure *FailureError
+		if !errors.As(err, &failure) || failure.Reason != "unregistered torrent" {
+			t.Errorf("status %d: expected a failure error, got %v", status, err)
+		}
+	}
+}
+
+func TestEscapeBytes(t *testing.T) {
+	input := []byte("aZ9-._~ /%\x00\xff")
+	expected := "aZ9-._~%20%2F%25%00%FF"
+	if got := escapeBytes(input); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+	if unescaped, err := url.QueryUnescape(expected); err != nil || unescaped != string(input) {
+		t.Errorf("expected %q to unescape to the input, got %q, %v", expected, unescaped, err)
+	}
+}
+
+func TestScrapeURL(t *testing.T) {
+	tests := []struct {
+		announce string
+		expected string
+		err      error
+	}{
+		{"http://example.com/announce", "http://example.com/scrape", nil},
+		{"http://example.com/x/announce", "http://example.com/x/scrape", nil},
+		{"http://example.com/announce.php", "http://example.com/scrape.php", nil},
+		{"http://example.com/announce?x2%0644", "http://example.com/scrape?x2%0644", nil},
+		{"http://example.com/announce?x=2/4", "http://example.com/scrape?x=2/4", nil},
+		{"https://example.com/0123abcd/announce", "https://example.com/0123abcd/scrape", nil},
+		{"http://example.com/a%20b/announce", "http://example.com/a%20b/scrape", nil},
+		{"http://example.com/a", "", ErrNoScrape},
+		{"http://example.com/announce/x", "", ErrNoScrape},
+		{"http://example.com/x%064announce", "", ErrNoScrape},
+		{"http://example.com/%zz", "", ErrInvalidURL},
+	}
+
+	for _, test := range tests {
+		t.Run(test.announce, func(t *testing.T) {
+			result, err := ScrapeURL(test.announce)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+			if result != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestHTTPScrape(t *testing.T) {
+	var requests []url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/key/scrape" {
+			http.NotFound(w, r)
+			return
+		}
+		query := r.URL.Query()
+		requests = append(requests, query)
+		// Every torrent is known but the first, and one more is thrown in.
+		files := map[string]interface{}{
+			string(make([]byte, 20)): map[string]interface{}{"complete": 1},
+		}
+		for _, hash := range query["info_hash"] {
+			if hash[0] == 0 {
+				continue
+			}
+			files[hash] = map[string]interface{}{"complete": int(hash[0]), "downloaded": int(hash[1]), "incomplete": 3, "name": "x"}
+		}
+		body, _ := bencode.Marshal(map[string]interface{}{"files": files})
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	hashes := make([][20]byte, 120)
+	for i := range hashes {
+		hashes[i] = [20]byte{byte(i), byte(2 * i), '&', '%'}
+	}
+	results, err := (&HTTPTracker{URL: server.URL + "/key/announce?passkey=secret"}).Scrape(context.Background(), hashes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != len(hashes)-1 {
+		t.Fatalf("expected %d results, got %d", len(hashes)-1, len(results))
+	}
+	for i, hash := range hashes[1:] {
+		if expected := (ScrapeResult{Seeders: i + 1, Completed: 2 * (i + 1), Leechers: 3}); results[hash] != expected {
+			t.Errorf("%x: expected %+v, got %+v", hash, expected, results[hash])
+		}
+	}
+
+	var sizes []int
+	for _, query := range requests {
+		sizes = append(sizes, len(query["info_hash"]))
+		if query.Get("passkey") != "secret" {
+			t.Errorf("expected the passkey to be kept, got %v", query)
+		}
+	}
+	if expected := []int{50, 50, 20}; !reflect.DeepEqual(sizes, expected) {
+		t.Errorf("expected batches of %v, got %v", expected, sizes)
+	}
+}
+
+func TestHTTPScrapeErrors(t *testing.T) {
+	server, _ := stubTracker(t, http.StatusOK, map[string]interface{}{"failure reason": "scrape disabled"})
+	_, err := (&HTTPTracker{URL: server.URL + "/announce"}).Scrape(context.Background(), [][20]byte{{1}})
+	var failure *FailureError
+	if !errors.As(err, &failure) || failure.Reason != "scrape disabled" {
+		t.Errorf("expected a failure error, got %v", err)
+	}
+
+	if _, err := (&HTTPTracker{URL: server.URL + "/tracker"}).Scrape(context.Background(), [][20]byte{{1}}); !errors.Is(err, ErrNoScrape) {
+		t.Errorf("expected %v, got %v", ErrNoScrape, err)
+	}
+}
+
+func TestHTTPNetwork(t *testing.T) {
+	server, _ := stubTracker(t, http.StatusOK, map[string]interface{}{"interval": 60})
+	if _, err := (&HTTPTracker{URL: server.URL, Network: "tcp4"}).Announce(context.Background(), testRequest()); err != nil {
+		t.Errorf("expected announcing over tcp4 to succeed, got %v", err)
+	}
+	if _, err := (&HTTPTracker{URL: server.URL, Network: "tcp6"}).Announce(context.Background(), testRequest()); err == nil {
+		t.Error("expected announcing to an IPv4 address over tcp6 to fail")
+	}
+}
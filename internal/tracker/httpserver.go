@@ -0,0 +1,208 @@
+package tracker
+
+import (
+	"encoding/binary"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/stupoid/torrent/internal/bencode"
+)
+
+// ServeHTTP serves announces and scrapes to HTTP clients. Announces are
+// answered with compact peer lists unless the client asks for compact=0.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	action, err := s.route(r.URL.Path)
+	if err != nil {
+		writeBencode(w, map[string]interface{}{"failure reason": failureReason(err)})
+		return
+	}
+	query, err := url.ParseQuery(r.URL.RawQuery)
+	if err != nil {
+		writeBencode(w, map[string]interface{}{"failure reason": "invalid query"})
+		return
+	}
+
+	switch action {
+	case "announce":
+		s.serveAnnounce(w, r, query)
+	case "scrape":
+		s.serveScrape(w, query)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) serveAnnounce(w http.ResponseWriter, r *http.Request, query url.Values) {
+	req, err := parseAnnounceQuery(query)
+	if err != nil {
+		writeBencode(w, map[string]interface{}{"failure reason": failureReason(err)})
+		return
+	}
+	source, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		writeBencode(w, map[string]interface{}{"failure reason": "unknown client address"})
+		return
+	}
+	resp, err := s.announce(req, source.Addr())
+	if err != nil {
+		writeBencode(w, map[string]interface{}{"failure reason": failureReason(err)})
+		return
+	}
+
+	dict := map[string]interface{}{
+		"interval":   int64(resp.Interval / time.Second),
+		"complete":   resp.Seeders,
+		"incomplete": resp.Leechers,
+	}
+	if resp.MinInterval > 0 {
+		dict["min interval"] = int64(resp.MinInterval / time.Second)
+	}
+	if query.Get("compact") == "0" {
+		peers := make([]interface{}, 0, len(resp.Peers))
+		for _, peer := range resp.Peers {
+			entry := map[string]interface{}{"ip": peer.Addr.Addr().String(), "port": peer.Addr.Port()}
+			if query.Get("no_peer_id") != "1" {
+				entry["peer id"] = string(peer.ID[:])
+			}
+			peers = append(peers, entry)
+		}
+		dict["peers"] = peers
+	} else {
+		var peers, peers6 []byte
+		for _, peer := range resp.Peers {
+			if peer.Addr.Addr().Is4() {
+				peers = appendCompactPeer(peers, peer.Addr)
+			} else {
+				peers6 = appendCompactPeer(peers6, peer.Addr)
+			}
+		}
+		dict["peers"] = string(peers)
+		if len(peers6) > 0 {
+			dict["peers6"] = string(peers6)
+		}
+	}
+	writeBencode(w, dict)
+}
+
+func (s *Server) serveScrape(w http.ResponseWriter, query url.Values) {
+	var infoHashes [][20]byte
+	for _, hash := range query["info_hash"] {
+		if len(hash) != 20 {
+			writeBencode(w, map[string]interface{}{"failure reason": "invalid info_hash"})
+			return
+		}
+		infoHashes = append(infoHashes, [20]byte([]byte(hash)))
+	}
+	if len(infoHashes) == 0 {
+		writeBencode(w, map[string]interface{}{"failure reason": "full scrapes are not supported"})
+		return
+	}
+
+	results, err := s.scrape(infoHashes)
+	if err != nil {
+		writeBencode(w, map[string]interface{}{"failure reason": failureReason(err)})
+		return
+	}
+	files := make(map[string]interface{}, len(results))
+	for hash, result := range results {
+		files[string(hash[:])] = map[string]interface{}{
+			"complete":   result.Seeders,
+			"downloaded": result.Completed,
+			"incomplete": result.Leechers,
+		}
+	}
+	writeBencode(w, map[string]interface{}{"files": files})
+}
+
+// parseAnnounceQuery parses the query of an HTTP announce.
+func parseAnnounceQuery(query url.Values) (AnnounceRequest, error) {
+	var req AnnounceRequest
+	infoHash, peerID := query.Get("info_hash"), query.Get("peer_id")
+	if len(infoHash) != 20 {
+		return req, &FailureError{Reason: "invalid info_hash"}
+	}
+	if len(peerID) != 20 {
+		return req, &FailureError{Reason: "invalid peer_id"}
+	}
+	copy(req.InfoHash[:], infoHash)
+	copy(req.PeerID[:], peerID)
+
+	port, err := strconv.ParseUint(query.Get("port"), 10, 16)
+	if err != nil || port == 0 {
+		return req, &FailureError{Reason: "invalid port"}
+	}
+	req.Port = uint16(port)
+
+	for _, field := range []struct {
+		name  string
+		value *int64
+	}{
+		{"uploaded", &req.Uploaded},
+		{"downloaded", &req.Downloaded},
+		{"left", &req.Left},
+	} {
+		n, err := strconv.ParseInt(query.Get(field.name), 10, 64)
+		if err != nil || n < 0 {
+			return req, &FailureError{Reason: "invalid " + field.name}
+		}
+		*field.value = n
+	}
+
+	switch query.Get("event") {
+	case "", "empty":
+	case "started":
+		req.Event = EventStarted
+	case "completed":
+		req.Event = EventCompleted
+	case "stopped":
+		req.Event = EventStopped
+	default:
+		return req, &FailureError{Reason: "invalid event"}
+	}
+
+	if numWant := query.Get("numwant"); numWant != "" {
+		n, err := strconv.Atoi(numWant)
+		if err != nil {
+			return req, &FailureError{Reason: "invalid numwant"}
+		}
+		req.NumWant = n
+	}
+	// Clients choose the format of their key, and most send 8 hex digits.
+	if key, err := strconv.ParseUint(query.Get("key"), 16, 32); err == nil {
+		req.Key = uint32(key)
+	}
+	req.IPv4 = parseQueryAddr(query.Get("ipv4"))
+	req.IPv6 = parseQueryAddr(query.Get("ipv6"))
+	return req, nil
+}
+
+// parseQueryAddr parses the ipv4 or ipv6 parameter of an announce, which is
+// an address optionally with a port. It returns the zero Addr if the
+// parameter is missing or invalid.
+func parseQueryAddr(s string) netip.Addr {
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return addr
+	}
+	if addrPort, err := netip.ParseAddrPort(s); err == nil {
+		return addrPort.Addr()
+	}
+	return netip.Addr{}
+}
+
+func appendCompactPeer(b []byte, addr netip.AddrPort) []byte {
+	b = append(b, addr.Addr().AsSlice()...)
+	return binary.BigEndian.AppendUint16(b, addr.Port())
+}
+
+func writeBencode(w http.ResponseWriter, v interface{}) {
+	body, err := bencode.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write(body)
+}
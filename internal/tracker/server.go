@@ -0,0 +1,340 @@
+package tracker
+
+import (
+	"errors"
+	"log"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stupoid/torrent/internal/metainfo"
+)
+
+const (
+	defaultInterval = 30 * time.Minute
+	defaultMaxPeers = 50
+
+	// expireEvery is how often a Server removes expired peers from its
+	// store.
+	expireEvery = time.Minute
+)
+
+var (
+	errUnregistered   = &FailureError{Reason: "unregistered torrent"}
+	errInvalidPasskey = &FailureError{Reason: "invalid passkey"}
+	errInternal       = &FailureError{Reason: "internal server error"}
+)
+
+// Server is a tracker serving announces and scrapes over HTTP, as an
+// http.Handler, and over UDP with ServeUDP. The zero value tracks any torrent
+// in a MemoryStore.
+type Server struct {
+	// Store holds the swarms, or a MemoryStore when nil.
+	Store Store
+
+	// Interval is the announce interval given to clients, 30 minutes when
+	// zero, and MinInterval the minimum one, not given when zero.
+	Interval    time.Duration
+	MinInterval time.Duration
+
+	// PeerTTL is how long peers are kept after their last announce, twice
+	// Interval when zero.
+	PeerTTL time.Duration
+
+	// MaxPeers is the largest number of peers in a response, 50 when zero.
+	MaxPeers int
+
+	// IgnoreClaimedAddrs makes the server record peers only at the address
+	// their announce came from. Otherwise a peer announcing over IPv4 is
+	// also recorded at the IPv6 address it gives, and the other way round
+	// (BEP 7). Those addresses cannot be checked, so a client can have them
+	// handed out to other peers, which then connect to a host that never
+	// announced.
+	IgnoreClaimedAddrs bool
+
+	// Allowlist restricts the server to the torrents it holds when not nil.
+	Allowlist *Allowlist
+
+	// Passkeys restricts the server to the users it holds when not nil.
+	// They put their passkey at the start of the URL path, announcing to
+	// /<passkey>/announce.
+	Passkeys *Passkeys
+
+	// ErrorLog logs the errors of Store, or the standard logger when nil.
+	ErrorLog *log.Logger
+
+	once       sync.Once
+	store      Store
+	secret     [32]byte
+	mu         sync.Mutex
+	lastExpire time.Time
+
+	now func() time.Time
+}
+
+func (s *Server) init() {
+	s.once.Do(func() {
+		s.store = s.Store
+		if s.store == nil {
+			s.store = &MemoryStore{}
+		}
+		s.initSecret()
+		if s.now == nil {
+			s.now = time.Now
+		}
+	})
+}
+
+func (s *Server) interval() time.Duration {
+	if s.Interval <= 0 {
+		return defaultInterval
+	}
+	return s.Interval
+}
+
+// announce records the announcing peer, whose packets come from source, and
+// returns the response for it. Errors to be sent to the client are
+// *FailureErrors.
+func (s *Server) announce(req AnnounceRequest, source netip.Addr) (*AnnounceResponse, error) {
+	s.init()
+	if s.Allowlist != nil && !s.Allowlist.Contains(req.InfoHash) {
+		return nil, errUnregistered
+	}
+	now := s.now()
+	if err := s.expire(now); err != nil {
+		return nil, s.internal(err)
+	}
+
+	source = source.Unmap()
+	resp := &AnnounceResponse{Interval: s.interval(), MinInterval: s.MinInterval, Peers: []Peer{}}
+	if req.Event == EventStopped {
+		if err := s.store.Delete(req.InfoHash, req.PeerID, source, req.Key); err != nil {
+			return nil, s.internal(err)
+		}
+		return resp, nil
+	}
+
+	// A peer announcing over one address family may tell its address in the
+	// other one (BEP 7). Stopped announces have returned above, so a claimed
+	// address can only add a peer, never remove one.
+	addrs := []netip.Addr{source}
+	if !s.IgnoreClaimedAddrs {
+		if source.Is4() && req.IPv6.Is6() && !req.IPv6.Is4In6() {
+			addrs = append(addrs, req.IPv6)
+		}
+		if source.Is6() && req.IPv4.Is4() {
+			addrs = append(addrs, req.IPv4)
+		}
+	}
+	for _, addr := range addrs {
+		peer := SwarmPeer{
+			ID:       req.PeerID,
+			Addr:     netip.AddrPortFrom(addr, req.Port),
+			Seeder:   req.Left == 0,
+			LastSeen: now,
+			Source:   source,
+			Key:      req.Key,
+		}
+		if err := s.store.Put(req.InfoHash, peer); err != nil {
+			return nil, s.internal(err)
+		}
+	}
+	if req.Event == EventCompleted {
+		if err := s.store.Complete(req.InfoHash); err != nil {
+			return nil, s.internal(err)
+		}
+	}
+
+	numWant := s.MaxPeers
+	if numWant <= 0 {
+		numWant = defaultMaxPeers
+	}
+	if req.NumWant > 0 {
+		numWant = min(numWant, req.NumWant)
+	}
+	peers, err := s.store.Peers(req.InfoHash, numWant+len(addrs))
+	if err != nil {
+		return nil, s.internal(err)
+	}
+	for _, peer := range peers {
+		if peer.ID != req.PeerID && len(resp.Peers) < numWant {
+			resp.Peers = append(resp.Peers, Peer{Addr: peer.Addr, ID: peer.ID})
+		}
+	}
+
+	result, err := s.store.Scrape(req.InfoHash)
+	if err != nil {
+		return nil, s.internal(err)
+	}
+	resp.Seeders, resp.Leechers = result.Seeders, result.Leechers
+	return resp, nil
+}
+
+// scrape returns the state of the swarms of infoHashes, leaving out the
+// torrents not in the allowlist.
+func (s *Server) scrape(infoHashes [][20]byte) (map[[20]byte]ScrapeResult, error) {
+	s.init()
+	if err := s.expire(s.now()); err != nil {
+		return nil, s.internal(err)
+	}
+	results := make(map[[20]byte]ScrapeResult, len(infoHashes))
+	for _, hash := range infoHashes {
+		if s.Allowlist != nil && !s.Allowlist.Contains(hash) {
+			continue
+		}
+		result, err := s.store.Scrape(hash)
+		if err != nil {
+			return nil, s.internal(err)
+		}
+		results[hash] = result
+	}
+	return results, nil
+}
+
+// expire removes the expired peers from the store if it was not done in the
+// last expireEvery.
+func (s *Server) expire(now time.Time) error {
+	s.mu.Lock()
+	if now.Sub(s.lastExpire) < expireEvery {
+		s.mu.Unlock()
+		return nil
+	}
+	s.lastExpire = now
+	s.mu.Unlock()
+
+	ttl := s.PeerTTL
+	if ttl <= 0 {
+		ttl = 2 * s.interval()
+	}
+	return s.store.Expire(now.Add(-ttl))
+}
+
+// internal logs err and returns the failure sent to the client instead.
+func (s *Server) internal(err error) error {
+	if s.ErrorLog != nil {
+		s.ErrorLog.Printf("tracker: %v", err)
+	} else {
+		log.Printf("tracker: %v", err)
+	}
+	return errInternal
+}
+
+// route returns the action of a request for the given URL path, checking the
+// passkey at its start if the server has passkeys. It returns an empty action
+// for paths that are neither an announce nor a scrape.
+func (s *Server) route(path string) (string, error) {
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if s.Passkeys != nil {
+		if len(segments) != 2 {
+			return "", nil
+		}
+		if !s.Passkeys.Contains(segments[0]) {
+			return "", errInvalidPasskey
+		}
+		segments = segments[1:]
+	}
+	if len(segments) != 1 || segments[0] != "announce" && segments[0] != "scrape" {
+		return "", nil
+	}
+	return segments[0], nil
+}
+
+// failureReason returns the reason sent to the client for err.
+func failureReason(err error) string {
+	var failure *FailureError
+	if errors.As(err, &failure) {
+		return failure.Reason
+	}
+	return errInternal.Reason
+}
+
+// Allowlist is a set of the torrents a Server tracks. The zero value is
+// empty, and an Allowlist is safe for concurrent use.
+type Allowlist struct {
+	mu     sync.RWMutex
+	hashes map[[20]byte]bool
+}
+
+// Add adds the torrent described by m. Hybrid torrents are added under both
+// their v1 and truncated v2 info hashes, since v2 clients announce the
+// latter. It fails if the info hashes of m cannot be computed.
+func (a *Allowlist) Add(m metainfo.MetaInfo) error {
+	hashes, err := torrentHashes(m)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.hashes == nil {
+		a.hashes = make(map[[20]byte]bool)
+	}
+	for _, hash := range hashes {
+		a.hashes[hash] = true
+	}
+	return nil
+}
+
+// Remove removes the torrent described by m. A torrent whose info hashes
+// cannot be computed was never added, so there is nothing to remove.
+func (a *Allowlist) Remove(m metainfo.MetaInfo) {
+	hashes, _ := torrentHashes(m)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, hash := range hashes {
+		delete(a.hashes, hash)
+	}
+}
+
+// Contains reports whether the torrent with the given info hash is allowed.
+func (a *Allowlist) Contains(infoHash [20]byte) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.hashes[infoHash]
+}
+
+func torrentHashes(m metainfo.MetaInfo) ([][20]byte, error) {
+	v1, v2, err := m.InfoHashes()
+	if err != nil {
+		return nil, err
+	}
+	var hashes [][20]byte
+	if m.Info.HasV1() {
+		hashes = append(hashes, v1)
+	}
+	if m.Info.HasV2() {
+		hashes = append(hashes, [20]byte(v2[:20]))
+	}
+	return hashes, nil
+}
+
+// Passkeys is a set of the passkeys of a Server's users. The zero value is
+// empty, and Passkeys is safe for concurrent use.
+type Passkeys struct {
+	mu   sync.RWMutex
+	keys map[string]bool
+}
+
+// Add adds the given passkey.
+func (p *Passkeys) Add(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.keys == nil {
+		p.keys = make(map[string]bool)
+	}
+	p.keys[key] = true
+}
+
+// Remove revokes the given passkey.
+func (p *Passkeys) Remove(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.keys, key)
+}
+
+// Contains reports whether key is a valid passkey.
+func (p *Passkeys) Contains(key string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return key != "" && p.keys[key]
+}
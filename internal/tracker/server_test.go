@@ -0,0 +1,540 @@
+package tracker
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stupoid/torrent/internal/bencode"
+	"github.com/stupoid/torrent/internal/metainfo"
+)
+
+func serveHTTP(t *testing.T, s *Server) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func serveUDP(t *testing.T, s *Server) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		s.ServeUDP(conn)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		conn.Close()
+		<-done
+	})
+	return "udp://" + conn.LocalAddr().String()
+}
+
+func peerRequest(id byte, left int64) AnnounceRequest {
+	req := testRequest()
+	req.PeerID = [20]byte{id}
+	req.Port = 6880 + uint16(id)
+	req.Left = left
+	return req
+}
+
+func peerAddrs(peers []Peer) []string {
+	var addrs []string
+	for _, peer := range peers {
+		addrs = append(addrs, peer.Addr.String())
+	}
+	slices.Sort(addrs)
+	return addrs
+}
+
+func TestServerAnnounce(t *testing.T) {
+	trackers := map[string]func(*Server) Tracker{
+		"http": func(s *Server) Tracker { return &HTTPTracker{URL: serveHTTP(t, s).URL + "/announce"} },
+		"udp": func(s *Server) Tracker {
+			tracker := &UDPTracker{URL: serveUDP(t, s), Timeout: time.Second}
+			t.Cleanup(func() { tracker.Close() })
+			return tracker
+		},
+	}
+
+	for name, newTracker := range trackers {
+		t.Run(name, func(t *testing.T) {
+			tracker := newTracker(&Server{Interval: time.Hour, MinInterval: time.Minute})
+			ctx := context.Background()
+
+			resp, err := tracker.Announce(ctx, peerRequest(1, 0))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Interval != time.Hour || resp.Seeders != 1 || len(resp.Peers) != 0 {
+				t.Errorf("expected the first peer alone, got %+v", resp)
+			}
+			if _, err := tracker.Announce(ctx, peerRequest(2, 100)); err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err = tracker.Announce(ctx, peerRequest(3, 100))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Seeders != 1 || resp.Leechers != 2 {
+				t.Errorf("expected 1 seeder and 2 leechers, got %+v", resp)
+			}
+			if expected := []string{"127.0.0.1:6881", "127.0.0.1:6882"}; !reflect.DeepEqual(peerAddrs(resp.Peers), expected) {
+				t.Errorf("expected %v, got %v", expected, peerAddrs(resp.Peers))
+			}
+
+			req := peerRequest(3, 100)
+			req.NumWant = 1
+			if resp, err = tracker.Announce(ctx, req); err != nil || len(resp.Peers) != 1 {
+				t.Errorf("expected a single peer, got %+v, %v", resp, err)
+			}
+
+			req = peerRequest(2, 0)
+			req.Event = EventCompleted
+			if _, err := tracker.Announce(ctx, req); err != nil {
+				t.Fatal(err)
+			}
+			req = peerRequest(1, 0)
+			req.Event = EventStopped
+			if _, err := tracker.Announce(ctx, req); err != nil {
+				t.Fatal(err)
+			}
+
+			results, err := tracker.Scrape(ctx, [][20]byte{testRequest().InfoHash, {9}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := map[[20]byte]ScrapeResult{
+				testRequest().InfoHash: {Seeders: 1, Leechers: 1, Completed: 1},
+				{9}:                    {},
+			}
+			if !reflect.DeepEqual(results, expected) {
+				t.Errorf("expected %v, got %v", expected, results)
+			}
+		})
+	}
+}
+
+func TestServerStopped(t *testing.T) {
+	s := &Server{}
+	source := netip.MustParseAddr("10.0.0.1")
+	other := netip.MustParseAddr("10.0.0.2")
+	scrape := func() ScrapeResult {
+		t.Helper()
+		results, err := s.scrape([][20]byte{testRequest().InfoHash})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return results[testRequest().InfoHash]
+	}
+
+	req := peerRequest(1, 100)
+	req.IPv6 = netip.MustParseAddr("2001:db8::1")
+	req.Key = 5
+	if _, err := s.announce(req, source); err != nil {
+		t.Fatal(err)
+	}
+
+	// Anyone may learn the peer id, but only the peer may remove itself.
+	req.Event = EventStopped
+	req.Key = 6
+	if _, err := s.announce(req, other); err != nil {
+		t.Fatal(err)
+	}
+	if result := scrape(); result.Leechers != 1 {
+		t.Fatalf("expected a stopped announce from another address to keep the peer, got %+v", result)
+	}
+	if peers, _ := s.store.Peers(req.InfoHash, 10); len(peers) != 2 {
+		t.Fatalf("expected both addresses of the peer to be kept, got %v", peers)
+	}
+
+	// Nor can it take the place of the peer with a regular announce first.
+	req.Event = EventNone
+	if _, err := s.announce(req, other); err != nil {
+		t.Fatal(err)
+	}
+	req.Event = EventStopped
+	if _, err := s.announce(req, other); err != nil {
+		t.Fatal(err)
+	}
+	if result := scrape(); result.Leechers != 1 {
+		t.Fatalf("expected an announce from another address not to replace the peer, got %+v", result)
+	}
+	if peers, _ := s.store.Peers(req.InfoHash, 10); len(peers) != 2 || peers[0].Source != source || peers[1].Source != source {
+		t.Fatalf("expected the peer to keep its source, got %v", peers)
+	}
+
+	// A peer whose address changed is recognised by its key.
+	req.Key = 5
+	if _, err := s.announce(req, other); err != nil {
+		t.Fatal(err)
+	}
+	if peers, _ := s.store.Peers(req.InfoHash, 10); len(peers) != 0 {
+		t.Errorf("expected the peer to be removed with its key, got %v", peers)
+	}
+
+	req = peerRequest(2, 100)
+	if _, err := s.announce(req, source); err != nil {
+		t.Fatal(err)
+	}
+	req.Event = EventStopped
+	if _, err := s.announce(req, source); err != nil {
+		t.Fatal(err)
+	}
+	if result := scrape(); result.Leechers != 0 {
+		t.Errorf("expected the peer to be removed from its own address, got %+v", result)
+	}
+}
+
+func TestServerClaimedAddrs(t *testing.T) {
+	for _, ignore := range []bool{false, true} {
+		s := &Server{IgnoreClaimedAddrs: ignore}
+		req := peerRequest(1, 100)
+		req.IPv6 = netip.MustParseAddr("2001:db8::1")
+		if _, err := s.announce(req, netip.MustParseAddr("10.0.0.1")); err != nil {
+			t.Fatal(err)
+		}
+		req = peerRequest(2, 100)
+		req.IPv4 = netip.MustParseAddr("10.0.0.2")
+		if _, err := s.announce(req, netip.MustParseAddr("2001:db8::2")); err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []string{"10.0.0.1:6881", "10.0.0.2:6882", "[2001:db8::1]:6881", "[2001:db8::2]:6882"}
+		if ignore {
+			expected = []string{"10.0.0.1:6881", "[2001:db8::2]:6882"}
+		}
+		var addrs []string
+		peers, _ := s.store.Peers(req.InfoHash, 10)
+		for _, peer := range peers {
+			addrs = append(addrs, peer.Addr.String())
+		}
+		slices.Sort(addrs)
+		if !slices.Equal(addrs, expected) {
+			t.Errorf("IgnoreClaimedAddrs %v: expected %v, got %v", ignore, expected, addrs)
+		}
+	}
+}
+
+func TestServerHTTPResponses(t *testing.T) {
+	s := &Server{}
+	server := serveHTTP(t, s)
+	tracker := &HTTPTracker{URL: server.URL + "/announce"}
+
+	req := peerRequest(1, 100)
+	req.IPv6 = netip.MustParseAddr("2001:db8::1")
+	if _, err := tracker.Announce(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+
+	// The IPv6 address given by the first peer comes in peers6.
+	resp, err := tracker.Announce(context.Background(), peerRequest(2, 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"127.0.0.1:6881", "[2001:db8::1]:6881"}; !reflect.DeepEqual(peerAddrs(resp.Peers), expected) {
+		t.Errorf("expected %v, got %v", expected, peerAddrs(resp.Peers))
+	}
+	if resp.Leechers != 2 {
+		t.Errorf("expected the dual-stack peer to count once, got %d leechers", resp.Leechers)
+	}
+
+	// Non-compact responses list the peers as dictionaries.
+	get := func(rawURL string) map[string]interface{} {
+		t.Helper()
+		r, err := http.Get(rawURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Body.Close()
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var dict map[string]interface{}
+		if err := bencode.Unmarshal(body, &dict); err != nil {
+			t.Fatal(err)
+		}
+		return dict
+	}
+	query := url.Values{
+		"info_hash":  {string(req.InfoHash[:])},
+		"peer_id":    {"-XX0001-000000000003"},
+		"port":       {"7000"},
+		"uploaded":   {"0"},
+		"downloaded": {"0"},
+		"left":       {"5"},
+		"compact":    {"0"},
+	}
+	dict := get(server.URL + "/announce?" + query.Encode())
+	peers, ok := dict["peers"].([]interface{})
+	if !ok || len(peers) != 3 {
+		t.Fatalf("expected a list of 3 peers, got %v", dict)
+	}
+	for _, peer := range peers {
+		if id := peer.(map[string]interface{})["peer id"]; id == nil {
+			t.Errorf("expected a peer id in %v", peer)
+		}
+	}
+	query.Set("no_peer_id", "1")
+	for _, peer := range get(server.URL + "/announce?" + query.Encode())["peers"].([]interface{}) {
+		if id := peer.(map[string]interface{})["peer id"]; id != nil {
+			t.Errorf("expected no peer id in %v", peer)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		modify   func(url.Values)
+		expected string
+	}{
+		{"short info hash", func(q url.Values) { q.Set("info_hash", "abc") }, "invalid info_hash"},
+		{"missing peer id", func(q url.Values) { q.Del("peer_id") }, "invalid peer_id"},
+		{"port out of range", func(q url.Values) { q.Set("port", "70000") }, "invalid port"},
+		{"negative left", func(q url.Values) { q.Set("left", "-1") }, "invalid left"},
+		{"unknown event", func(q url.Values) { q.Set("event", "paused") }, "invalid event"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			q := url.Values{}
+			for key, values := range query {
+				q[key] = values
+			}
+			test.modify(q)
+			if reason := get(server.URL + "/announce?" + q.Encode())["failure reason"]; reason != test.expected {
+				t.Errorf("expected failure %q, got %v", test.expected, reason)
+			}
+		})
+	}
+
+	if reason := get(server.URL + "/scrape")["failure reason"]; reason == nil {
+		t.Error("expected full scrapes to fail")
+	}
+	if r, err := http.Get(server.URL + "/elsewhere"); err != nil || r.StatusCode != http.StatusNotFound {
+		t.Errorf("expected %d for an unknown path, got %v", http.StatusNotFound, err)
+	}
+}
+
+func TestServerAllowlist(t *testing.T) {
+	files := metainfo.Info{Name: "a", PieceLength: 16384, Length: 1, Pieces: [][20]byte{{1}}}
+	allowed := metainfo.MetaInfo{Info: files}
+	hybrid := metainfo.MetaInfo{Info: files}
+	hybrid.Info.Name = "b"
+	hybrid.Info.MetaVersion = 2
+	hybrid.Info.FileTree = []metainfo.File{{Path: []string{"b"}, Length: 1, PiecesRoot: [32]byte{1}}}
+
+	s := &Server{Allowlist: &Allowlist{}}
+	for _, m := range []metainfo.MetaInfo{allowed, hybrid} {
+		if err := s.Allowlist.Add(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	unencodable := allowed
+	unencodable.Info.Extra = map[string]interface{}{"x": 1.5}
+	if err := s.Allowlist.Add(unencodable); !errors.Is(err, metainfo.ErrInvalidField) {
+		t.Errorf("expected ErrInvalidField for an unencodable info dictionary, got %v", err)
+	}
+	server := serveHTTP(t, s)
+	trackers := map[string]Tracker{
+		"http": &HTTPTracker{URL: server.URL + "/announce"},
+		"udp":  &UDPTracker{URL: serveUDP(t, s), Timeout: time.Second},
+	}
+
+	for name, tracker := range trackers {
+		t.Run(name, func(t *testing.T) {
+			for _, hash := range [][20]byte{allowed.InfoHash(), hybrid.InfoHash(), hybrid.TruncatedInfoHashV2()} {
+				req := peerRequest(1, 0)
+				req.InfoHash = hash
+				if _, err := tracker.Announce(context.Background(), req); err != nil {
+					t.Errorf("%x: expected the torrent to be allowed, got %v", hash, err)
+				}
+			}
+
+			var failure *FailureError
+			if _, err := tracker.Announce(context.Background(), peerRequest(1, 0)); !errors.As(err, &failure) || failure.Reason != "unregistered torrent" {
+				t.Errorf("expected an unregistered torrent, got %v", err)
+			}
+
+			results, err := tracker.Scrape(context.Background(), [][20]byte{allowed.InfoHash(), testRequest().InfoHash})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := results[allowed.InfoHash()]; !ok {
+				t.Errorf("expected the allowed torrent to be scraped, got %v", results)
+			}
+			// UDP scrapes answer for every hash, unknown torrents as empty.
+			if result := results[testRequest().InfoHash]; result != (ScrapeResult{}) {
+				t.Errorf("expected nothing about the unregistered torrent, got %+v", result)
+			}
+		})
+	}
+
+	s.Allowlist.Remove(allowed)
+	if s.Allowlist.Contains(allowed.InfoHash()) || !s.Allowlist.Contains(hybrid.TruncatedInfoHashV2()) {
+		t.Error("expected only the removed torrent to be disallowed")
+	}
+}
+
+func TestServerPasskeys(t *testing.T) {
+	s := &Server{Passkeys: &Passkeys{}}
+	s.Passkeys.Add("alice")
+	s.Passkeys.Add("bob")
+	s.Passkeys.Remove("bob")
+	server := serveHTTP(t, s)
+	udpURL := serveUDP(t, s)
+
+	tests := []struct {
+		name    string
+		tracker Tracker
+		err     string
+	}{
+		{"http passkey", &HTTPTracker{URL: server.URL + "/alice/announce"}, ""},
+		{"http revoked passkey", &HTTPTracker{URL: server.URL + "/bob/announce"}, "invalid passkey"},
+		{"http without passkey", &HTTPTracker{URL: server.URL + "/x/announce"}, "invalid passkey"},
+		{"udp passkey", &UDPTracker{URL: udpURL + "/alice/announce", Timeout: time.Second}, ""},
+		{"udp revoked passkey", &UDPTracker{URL: udpURL + "/bob/announce", Timeout: time.Second}, "invalid passkey"},
+		{"udp without passkey", &UDPTracker{URL: udpURL, Timeout: time.Second}, "invalid passkey"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := test.tracker.Announce(context.Background(), peerRequest(1, 0))
+			var failure *FailureError
+			switch {
+			case test.err == "" && err != nil:
+				t.Errorf("expected the announce to succeed, got %v", err)
+			case test.err != "" && (!errors.As(err, &failure) || failure.Reason != test.err):
+				t.Errorf("expected failure %q, got %v", test.err, err)
+			}
+		})
+	}
+
+	if _, err := (&HTTPTracker{URL: server.URL + "/alice/announce"}).Scrape(context.Background(), [][20]byte{{1}}); err != nil {
+		t.Errorf("expected scrapes with a passkey to succeed, got %v", err)
+	}
+	if r, err := http.Get(server.URL + "/announce"); err != nil || r.StatusCode != http.StatusNotFound {
+		t.Errorf("expected %d for a path without passkey, got %v", http.StatusNotFound, err)
+	}
+}
+
+func TestServerExpiry(t *testing.T) {
+	now := time.Now()
+	var mu sync.Mutex
+	s := &Server{Interval: time.Minute, PeerTTL: 5 * time.Minute}
+	s.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	advance := func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(d)
+	}
+	tracker := &HTTPTracker{URL: serveHTTP(t, s).URL + "/announce"}
+	announce := func(id byte) *AnnounceResponse {
+		t.Helper()
+		resp, err := tracker.Announce(context.Background(), peerRequest(id, 100))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	announce(1)
+	advance(3 * time.Minute)
+	announce(2)
+	if resp := announce(3); resp.Leechers != 3 {
+		t.Errorf("expected 3 leechers, got %d", resp.Leechers)
+	}
+
+	// Peer 1 was last seen 6 minutes ago.
+	advance(3 * time.Minute)
+	if resp := announce(3); resp.Leechers != 2 || len(resp.Peers) != 1 {
+		t.Errorf("expected the first peer to expire, got %+v", resp)
+	}
+
+	// Scrapes expire peers too, without any announce.
+	advance(6 * time.Minute)
+	hash := testRequest().InfoHash
+	results, err := tracker.Scrape(context.Background(), [][20]byte{hash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[hash].Leechers != 0 {
+		t.Errorf("expected all peers to expire, got %+v", results[hash])
+	}
+}
+
+func TestServerUDPRequests(t *testing.T) {
+	s := &Server{}
+	s.init()
+	from := netip.MustParseAddrPort("10.0.0.1:5000")
+	txID := []byte{1, 2, 3, 4}
+	header := func(connID uint64, action uint32) []byte {
+		b := binary.BigEndian.AppendUint64(nil, connID)
+		b = binary.BigEndian.AppendUint32(b, action)
+		return append(b, txID...)
+	}
+
+	resp := s.handleUDP(header(protocolID, actionConnect), from)
+	if len(resp) != 16 || binary.BigEndian.Uint32(resp) != actionConnect {
+		t.Fatalf("expected a connect response, got %x", resp)
+	}
+	connID := binary.BigEndian.Uint64(resp[8:])
+	if other := s.connectionID(netip.MustParseAddrPort("10.0.0.1:5001"), s.now(), 0); other == connID {
+		t.Error("expected connection ids to differ between addresses")
+	}
+
+	tests := []struct {
+		name     string
+		packet   []byte
+		expected string
+	}{
+		{"stale connection id", append(header(connID+1, actionScrape), make([]byte, 20)...), "invalid connection id"},
+		{"short announce", append(header(connID, actionAnnounce), make([]byte, 20)...), "invalid announce"},
+		{"truncated scrape", append(header(connID, actionScrape), make([]byte, 19)...), "invalid scrape"},
+		{"too many hashes", append(header(connID, actionScrape), make([]byte, 20*(MaxScrapeHashes+1))...), "invalid scrape"},
+		{"unknown action", header(connID, 7), "unknown action"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := s.handleUDP(test.packet, from)
+			if len(resp) < 8 || binary.BigEndian.Uint32(resp) != actionError || string(resp[4:8]) != string(txID) {
+				t.Fatalf("expected an error response, got %x", resp)
+			}
+			if message := string(resp[8:]); message != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, message)
+			}
+		})
+	}
+
+	// Connection ids stay valid through the next minute.
+	s.now = func() time.Time { return time.Now().Add(time.Minute) }
+	if resp := s.handleUDP(append(header(connID, actionScrape), make([]byte, 20)...), from); binary.BigEndian.Uint32(resp) != actionScrape {
+		t.Errorf("expected the connection id to be valid a minute later, got %x", resp)
+	}
+	s.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	if resp := s.handleUDP(append(header(connID, actionScrape), make([]byte, 20)...), from); binary.BigEndian.Uint32(resp) != actionError {
+		t.Errorf("expected the connection id to expire, got %x", resp)
+	}
+
+	if resp := s.handleUDP(header(connID, actionConnect), from); resp != nil {
+		t.Errorf("expected connects without the protocol id to be ignored, got %x", resp)
+	}
+}
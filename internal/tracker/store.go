@@ -0,0 +1,182 @@
+package tracker
+
+import (
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// SwarmPeer is a peer of a swarm as recorded by a Store. A peer reachable
+// over both address families is recorded once for each.
+type SwarmPeer struct {
+	ID       [20]byte
+	Addr     netip.AddrPort
+	Seeder   bool
+	LastSeen time.Time
+
+	// Source is the address the announce came from, which differs from Addr
+	// for the address a peer gives in the other family, and Key the key of
+	// the announce, zero if none was sent. They tell which announces may
+	// remove the peer.
+	Source netip.Addr
+	Key    uint32
+}
+
+// announcedBy reports whether an announce from source with the given key
+// comes from p: from the same address, or with the same key if it is not
+// zero.
+func (p SwarmPeer) announcedBy(source netip.Addr, key uint32) bool {
+	return p.Source == source || key != 0 && p.Key == key
+}
+
+// Store holds the swarms of a Server. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	// Put records peer in the swarm of infoHash, replacing the peer with the
+	// same ID and address family if any. A peer is only replaced by one with
+	// the same Source or non-zero Key; other announces with its ID are
+	// ignored, since they could be used to take its place and then remove
+	// it.
+	Put(infoHash [20]byte, peer SwarmPeer) error
+
+	// Delete removes the peers with the given ID from the swarm of infoHash
+	// that were announced from source, or with the same key if it is not
+	// zero. Peer IDs are public, so matching the ID alone would let anyone
+	// remove a peer.
+	Delete(infoHash [20]byte, id [20]byte, source netip.Addr, key uint32) error
+
+	// Complete counts a completed download in the swarm of infoHash.
+	Complete(infoHash [20]byte) error
+
+	// Peers returns up to n peers of the swarm of infoHash.
+	Peers(infoHash [20]byte, n int) ([]SwarmPeer, error)
+
+	// Scrape returns the number of seeders, leechers and completed
+	// downloads of the swarm of infoHash, counting each peer ID once.
+	Scrape(infoHash [20]byte) (ScrapeResult, error)
+
+	// Expire removes the peers last seen before the given time.
+	Expire(before time.Time) error
+}
+
+// MemoryStore is a Store keeping the swarms in memory. The zero value is an
+// empty store.
+type MemoryStore struct {
+	mu     sync.Mutex
+	swarms map[[20]byte]*memorySwarm
+}
+
+type memorySwarm struct {
+	peers     map[swarmKey]SwarmPeer
+	completed int
+}
+
+type swarmKey struct {
+	id   [20]byte
+	ipv6 bool
+}
+
+func (s *MemoryStore) swarm(infoHash [20]byte) *memorySwarm {
+	if s.swarms == nil {
+		s.swarms = make(map[[20]byte]*memorySwarm)
+	}
+	swarm, ok := s.swarms[infoHash]
+	if !ok {
+		swarm = &memorySwarm{peers: make(map[swarmKey]SwarmPeer)}
+		s.swarms[infoHash] = swarm
+	}
+	return swarm
+}
+
+// drop forgets the swarm of infoHash if nothing is left of it.
+func (s *MemoryStore) drop(infoHash [20]byte) {
+	if swarm := s.swarms[infoHash]; swarm != nil && len(swarm.peers) == 0 && swarm.completed == 0 {
+		delete(s.swarms, infoHash)
+	}
+}
+
+func (s *MemoryStore) Put(infoHash [20]byte, peer SwarmPeer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	swarm := s.swarm(infoHash)
+	key := swarmKey{peer.ID, peer.Addr.Addr().Is6()}
+	if old, ok := swarm.peers[key]; ok && !old.announcedBy(peer.Source, peer.Key) {
+		return nil
+	}
+	swarm.peers[key] = peer
+	return nil
+}
+
+func (s *MemoryStore) Delete(infoHash [20]byte, id [20]byte, source netip.Addr, key uint32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if swarm := s.swarms[infoHash]; swarm != nil {
+		for _, ipv6 := range []bool{false, true} {
+			k := swarmKey{id, ipv6}
+			if peer, ok := swarm.peers[k]; ok && peer.announcedBy(source, key) {
+				delete(swarm.peers, k)
+			}
+		}
+		s.drop(infoHash)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Complete(infoHash [20]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.swarm(infoHash).completed++
+	return nil
+}
+
+func (s *MemoryStore) Peers(infoHash [20]byte, n int) ([]SwarmPeer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	swarm := s.swarms[infoHash]
+	if swarm == nil {
+		return nil, nil
+	}
+	peers := make([]SwarmPeer, 0, min(n, len(swarm.peers)))
+	for _, peer := range swarm.peers {
+		if len(peers) == n {
+			break
+		}
+		peers = append(peers, peer)
+	}
+	return peers, nil
+}
+
+func (s *MemoryStore) Scrape(infoHash [20]byte) (ScrapeResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	swarm := s.swarms[infoHash]
+	if swarm == nil {
+		return ScrapeResult{}, nil
+	}
+	result := ScrapeResult{Completed: swarm.completed}
+	for key, peer := range swarm.peers {
+		if _, ok := swarm.peers[swarmKey{key.id, false}]; key.ipv6 && ok {
+			continue
+		}
+		if peer.Seeder {
+			result.Seeders++
+		} else {
+			result.Leechers++
+		}
+	}
+	return result, nil
+}
+
+func (s *MemoryStore) Expire(before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for infoHash, swarm := range s.swarms {
+		for key, peer := range swarm.peers {
+			if peer.LastSeen.Before(before) {
+				delete(swarm.peers, key)
+			}
+		}
+		s.drop(infoHash)
+	}
+	return nil
+}
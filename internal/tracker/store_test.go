@@ -0,0 +1,73 @@
+package tracker
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	var s MemoryStore
+	hash := [20]byte{1}
+	now := time.Now()
+	peers := []SwarmPeer{
+		{ID: [20]byte{1}, Addr: netip.MustParseAddrPort("10.0.0.1:1"), Seeder: true, LastSeen: now, Source: netip.MustParseAddr("10.0.0.1")},
+		{ID: [20]byte{1}, Addr: netip.MustParseAddrPort("[2001:db8::1]:1"), Seeder: true, LastSeen: now, Source: netip.MustParseAddr("10.0.0.1")},
+		{ID: [20]byte{2}, Addr: netip.MustParseAddrPort("10.0.0.2:2"), LastSeen: now.Add(-time.Hour)},
+		{ID: [20]byte{3}, Addr: netip.MustParseAddrPort("[2001:db8::3]:3"), LastSeen: now, Source: netip.MustParseAddr("2001:db8::3"), Key: 7},
+	}
+	for _, peer := range peers {
+		if err := s.Put(hash, peer); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Complete(hash)
+
+	check := func(expected ScrapeResult, numPeers int) {
+		t.Helper()
+		result, _ := s.Scrape(hash)
+		if result != expected {
+			t.Errorf("expected %+v, got %+v", expected, result)
+		}
+		all, _ := s.Peers(hash, 100)
+		if len(all) != numPeers {
+			t.Errorf("expected %d peers, got %d", numPeers, len(all))
+		}
+	}
+
+	// The dual-stack seeder counts once.
+	check(ScrapeResult{Seeders: 1, Leechers: 2, Completed: 1}, 4)
+	if some, _ := s.Peers(hash, 2); len(some) != 2 {
+		t.Errorf("expected 2 peers, got %d", len(some))
+	}
+
+	// Announcing again replaces the peer.
+	peers[3].Seeder = true
+	s.Put(hash, peers[3])
+	check(ScrapeResult{Seeders: 2, Leechers: 1, Completed: 1}, 4)
+
+	s.Expire(now.Add(-time.Minute))
+	check(ScrapeResult{Seeders: 2, Completed: 1}, 3)
+
+	// Peers are only deleted from the address they announced from, or with
+	// their key.
+	s.Delete(hash, [20]byte{1}, netip.MustParseAddr("10.0.0.9"), 0)
+	s.Delete(hash, [20]byte{3}, netip.MustParseAddr("10.0.0.9"), 8)
+	check(ScrapeResult{Seeders: 2, Completed: 1}, 3)
+	s.Delete(hash, [20]byte{1}, netip.MustParseAddr("10.0.0.1"), 0)
+	check(ScrapeResult{Seeders: 1, Completed: 1}, 1)
+	s.Delete(hash, [20]byte{3}, netip.MustParseAddr("10.0.0.9"), 7)
+	check(ScrapeResult{Completed: 1}, 0)
+
+	// Swarms are forgotten once empty, unless they have completed downloads.
+	other := [20]byte{2}
+	s.Put(other, peers[0])
+	s.Delete(other, peers[0].ID, peers[0].Source, 0)
+	s.Expire(now.Add(time.Minute))
+	if len(s.swarms) != 1 || s.swarms[hash] == nil {
+		t.Errorf("expected only the swarm with a completed download to be kept, got %d swarms", len(s.swarms))
+	}
+	if result, err := s.Scrape([20]byte{9}); err != nil || result != (ScrapeResult{}) {
+		t.Errorf("expected an empty result for an unknown swarm, got %+v, %v", result, err)
+	}
+}
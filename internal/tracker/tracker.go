@@ -0,0 +1,184 @@
+// Package tracker implements clients and a server for BitTorrent trackers,
+// which hand out the addresses of the peers sharing a torrent.
+package tracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"time"
+
+	"github.com/stupoid/torrent/internal/metainfo"
+)
+
+var (
+	ErrBadStatus       = errors.New("unexpected HTTP status")
+	ErrInvalidResponse = errors.New("invalid tracker response")
+	ErrInvalidURL      = errors.New("invalid tracker URL")
+	ErrTimeout         = errors.New("tracker did not respond")
+	ErrNoScrape        = errors.New("tracker does not support scrape")
+)
+
+// Tracker is a client for a single tracker.
+type Tracker interface {
+	// Announce sends req to the tracker. A failure reported by the tracker
+	// is returned as a *FailureError.
+	Announce(ctx context.Context, req AnnounceRequest) (*AnnounceResponse, error)
+
+	// Scrape asks the tracker about the swarms of the torrents with the
+	// given info hashes, as many at once as the protocol allows. Torrents
+	// the tracker does not know are missing from the results.
+	Scrape(ctx context.Context, infoHashes [][20]byte) (map[[20]byte]ScrapeResult, error)
+}
+
+// New returns a client for the tracker with the given announce URL, an
+// *HTTPTracker or a *UDPTracker depending on its scheme.
+func New(announceURL string) (Tracker, error) {
+	u, err := url.Parse(announceURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidURL, err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return &HTTPTracker{URL: announceURL}, nil
+	case "udp":
+		return &UDPTracker{URL: announceURL}, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported scheme %q", ErrInvalidURL, u.Scheme)
+	}
+}
+
+// InfoHash returns the info hash identifying m to trackers: its v1 info hash,
+// or the truncated v2 one for v2-only torrents. It returns the error of
+// metainfo.MetaInfo.InfoHashes if the info dictionary of m cannot be encoded.
+func InfoHash(m metainfo.MetaInfo) ([20]byte, error) {
+	v1, v2, err := m.InfoHashes()
+	if err != nil {
+		return [20]byte{}, err
+	}
+	if !m.Info.HasV1() {
+		return [20]byte(v2[:20]), nil
+	}
+	return v1, nil
+}
+
+// FailureError is a failure reported by a tracker, such as an unregistered
+// torrent or a bad passkey.
+type FailureError struct {
+	Reason string
+}
+
+func (e *FailureError) Error() string {
+	return "tracker failure: " + e.Reason
+}
+
+// Event is the announce event telling a tracker about a change in the
+// client's state. Its values are the ones of the UDP protocol (BEP 15).
+type Event int32
+
+const (
+	EventNone Event = iota
+	EventCompleted
+	EventStarted
+	EventStopped
+)
+
+func (e Event) String() string {
+	switch e {
+	case EventNone:
+		return ""
+	case EventCompleted:
+		return "completed"
+	case EventStarted:
+		return "started"
+	case EventStopped:
+		return "stopped"
+	default:
+		return "unknown"
+	}
+}
+
+// AnnounceRequest holds the parameters of an announce.
+type AnnounceRequest struct {
+	InfoHash   [20]byte
+	PeerID     [20]byte
+	Port       uint16
+	Uploaded   int64
+	Downloaded int64
+	Left       int64
+	Event      Event
+
+	// NumWant is the number of peers asked for, leaving the choice to the
+	// tracker when zero.
+	NumWant int
+
+	// Key identifies the client across IP address changes, and is not sent
+	// when zero.
+	Key uint32
+
+	// TrackerID is the tracker id returned by a previous announce to the
+	// same tracker, if any.
+	TrackerID string
+
+	// IPv4 and IPv6 are addresses of the client, sent when valid so that a
+	// tracker reached over one address family learns the address of the
+	// other one (BEP 7). UDP trackers are only sent IPv4.
+	IPv4 netip.Addr
+	IPv6 netip.Addr
+}
+
+// AnnounceResponse is the answer of a tracker to an announce.
+type AnnounceResponse struct {
+	// Interval is how long to wait before the next regular announce, and
+	// MinInterval how long the client must wait at least. They are zero when
+	// the tracker did not say.
+	Interval    time.Duration
+	MinInterval time.Duration
+
+	// TrackerID is to be sent back in later announces when not empty.
+	TrackerID string
+
+	// WarningMessage is a message from the tracker that does not keep the
+	// announce from succeeding.
+	WarningMessage string
+
+	Seeders  int
+	Leechers int
+	Peers    []Peer
+}
+
+// Peer is a peer returned by a tracker.
+type Peer struct {
+	Addr netip.AddrPort
+
+	// ID is the peer id, which only trackers answering with a list of
+	// dictionaries include. It is zero otherwise.
+	ID [20]byte
+}
+
+// ScrapeResult is the state of a torrent's swarm as reported by a scrape.
+type ScrapeResult struct {
+	Seeders   int
+	Completed int
+	Leechers  int
+}
+
+// scrapeBatches scrapes infoHashes in batches of at most size with scrape,
+// merging the results.
+func scrapeBatches(ctx context.Context, infoHashes [][20]byte, size int, scrape func(context.Context, [][20]byte) (map[[20]byte]ScrapeResult, error)) (map[[20]byte]ScrapeResult, error) {
+	results := make(map[[20]byte]ScrapeResult, len(infoHashes))
+	for len(infoHashes) > 0 {
+		batch := infoHashes[:min(size, len(infoHashes))]
+		infoHashes = infoHashes[len(batch):]
+		batchResults, err := scrape(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		for hash, result := range batchResults {
+			results[hash] = result
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,64 @@
+package tracker
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stupoid/torrent/internal/metainfo"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected Tracker
+		err      error
+	}{
+		{"http://example.com/announce", &HTTPTracker{URL: "http://example.com/announce"}, nil},
+		{"https://example.com/announce", &HTTPTracker{URL: "https://example.com/announce"}, nil},
+		{"udp://example.com:6969", &UDPTracker{URL: "udp://example.com:6969"}, nil},
+		{"wss://example.com/announce", nil, ErrInvalidURL},
+		{"http://%zz", nil, ErrInvalidURL},
+	}
+
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			result, err := New(test.url)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("expected error %v, got %v", test.err, err)
+			}
+			if !reflect.DeepEqual(result, test.expected) {
+				t.Errorf("expected %#v, got %#v", test.expected, result)
+			}
+		})
+	}
+}
+
+func TestInfoHash(t *testing.T) {
+	v1 := metainfo.MetaInfo{Info: metainfo.Info{Name: "a", PieceLength: 16384, Length: 1, Pieces: [][20]byte{{1}}}}
+	if hash, err := InfoHash(v1); err != nil || hash != v1.InfoHash() {
+		t.Errorf("expected the v1 info hash for a v1 torrent")
+	}
+
+	v2 := metainfo.MetaInfo{Info: metainfo.Info{
+		Name:        "a",
+		PieceLength: 16384,
+		MetaVersion: 2,
+		FileTree:    []metainfo.File{{Path: []string{"a"}, Length: 1, PiecesRoot: [32]byte{1}}},
+	}}
+	if hash, err := InfoHash(v2); err != nil || hash != v2.TruncatedInfoHashV2() {
+		t.Errorf("expected the truncated v2 info hash for a v2 torrent")
+	}
+
+	hybrid := v2
+	hybrid.Info.Length = 1
+	hybrid.Info.Pieces = [][20]byte{{1}}
+	if hash, err := InfoHash(hybrid); err != nil || hash != hybrid.InfoHash() {
+		t.Errorf("expected the v1 info hash for a hybrid torrent")
+	}
+
+	v1.Info.Extra = map[string]interface{}{"x": 1.5}
+	if _, err := InfoHash(v1); !errors.Is(err, metainfo.ErrInvalidField) {
+		t.Errorf("expected ErrInvalidField for an unencodable info dictionary, got %v", err)
+	}
+}
@@ -0,0 +1,324 @@
+package tracker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// protocolID is the magic connection id of connect requests.
+const protocolID = 0x41727101980
+
+const (
+	actionConnect uint32 = iota
+	actionAnnounce
+	actionScrape
+	actionError
+)
+
+// Options following the fixed part of an announce request (BEP 41).
+const (
+	optionEnd byte = iota
+	optionNOP
+	optionURLData
+)
+
+const (
+	// connectionTTL is how long a connection id may be used after it was
+	// received.
+	connectionTTL = time.Minute
+
+	// MaxScrapeHashes is the largest number of info hashes a UDP scrape
+	// request can hold.
+	MaxScrapeHashes = 74
+
+	defaultUDPTimeout = 15 * time.Second
+	defaultUDPRetries = 8
+	maxUDPPacket      = 65507
+)
+
+// UDPTracker is a client for a UDP tracker (BEP 15). Its requests are sent
+// one at a time over a single socket, which stays open until Close.
+type UDPTracker struct {
+	// URL is the announce URL, of the form udp://host:port.
+	URL string
+
+	// Timeout is how long to wait for the first response to a request, 15
+	// seconds when zero. It doubles after every retransmission.
+	Timeout time.Duration
+
+	// Retries is the number of retransmissions before giving up, 8 when
+	// zero.
+	Retries int
+
+	// Network is "udp4" or "udp6" to restrict the tracker's addresses to one
+	// family, or "udp" when empty.
+	Network string
+
+	mu       sync.Mutex
+	conn     net.Conn
+	ipv6     bool
+	connID   uint64
+	connTime time.Time
+}
+
+// Announce sends req to the tracker. A failure reported by the tracker is
+// returned as a *FailureError.
+func (t *UDPTracker) Announce(ctx context.Context, req AnnounceRequest) (*AnnounceResponse, error) {
+	payload := make([]byte, 82)
+	copy(payload[0:20], req.InfoHash[:])
+	copy(payload[20:40], req.PeerID[:])
+	binary.BigEndian.PutUint64(payload[40:48], uint64(req.Downloaded))
+	binary.BigEndian.PutUint64(payload[48:56], uint64(req.Left))
+	binary.BigEndian.PutUint64(payload[56:64], uint64(req.Uploaded))
+	binary.BigEndian.PutUint32(payload[64:68], uint32(req.Event))
+	// The IP address is left zero for the packet's source unless given.
+	if req.IPv4.Is4() {
+		ip := req.IPv4.As4()
+		copy(payload[68:72], ip[:])
+	}
+	binary.BigEndian.PutUint32(payload[72:76], req.Key)
+	numWant := int32(-1)
+	if req.NumWant > 0 {
+		numWant = int32(req.NumWant)
+	}
+	binary.BigEndian.PutUint32(payload[76:80], uint32(numWant))
+	binary.BigEndian.PutUint16(payload[80:82], req.Port)
+	payload = append(payload, urlDataOptions(t.URL)...)
+
+	resp, err := t.do(ctx, actionAnnounce, payload)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < 12 {
+		return nil, fmt.Errorf("%w: announce response of %d bytes", ErrInvalidResponse, len(resp)+8)
+	}
+	// Responses to announces over IPv6 hold IPv6 peers.
+	addrLen := 4
+	t.mu.Lock()
+	if t.ipv6 {
+		addrLen = 16
+	}
+	t.mu.Unlock()
+	peers, err := parseCompactPeers(resp[12:], addrLen)
+	if err != nil {
+		return nil, err
+	}
+	return &AnnounceResponse{
+		Interval: time.Duration(binary.BigEndian.Uint32(resp[0:4])) * time.Second,
+		Leechers: int(binary.BigEndian.Uint32(resp[4:8])),
+		Seeders:  int(binary.BigEndian.Uint32(resp[8:12])),
+		Peers:    peers,
+	}, nil
+}
+
+// Scrape asks the tracker about the swarms of the torrents with the given
+// info hashes, in requests of up to MaxScrapeHashes hashes.
+func (t *UDPTracker) Scrape(ctx context.Context, infoHashes [][20]byte) (map[[20]byte]ScrapeResult, error) {
+	return scrapeBatches(ctx, infoHashes, MaxScrapeHashes, t.scrape)
+}
+
+func (t *UDPTracker) scrape(ctx context.Context, infoHashes [][20]byte) (map[[20]byte]ScrapeResult, error) {
+	payload := make([]byte, 0, 20*len(infoHashes))
+	for _, hash := range infoHashes {
+		payload = append(payload, hash[:]...)
+	}
+
+	resp, err := t.do(ctx, actionScrape, payload)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < 12*len(infoHashes) {
+		return nil, fmt.Errorf("%w: scrape response of %d bytes for %d hashes", ErrInvalidResponse, len(resp)+8, len(infoHashes))
+	}
+	results := make(map[[20]byte]ScrapeResult, len(infoHashes))
+	for i, hash := range infoHashes {
+		entry := resp[12*i:]
+		results[hash] = ScrapeResult{
+			Seeders:   int(binary.BigEndian.Uint32(entry[0:4])),
+			Completed: int(binary.BigEndian.Uint32(entry[4:8])),
+			Leechers:  int(binary.BigEndian.Uint32(entry[8:12])),
+		}
+	}
+	return results, nil
+}
+
+// Close closes the tracker's socket. The tracker can still be used
+// afterwards, with a new socket and connection id.
+func (t *UDPTracker) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conn == nil {
+		return nil
+	}
+	err := t.conn.Close()
+	t.conn = nil
+	t.connTime = time.Time{}
+	return err
+}
+
+// do sends a request with the given action and payload, connecting first if
+// the connection id expired, and returns the response past its action and
+// transaction id. Requests that go unanswered are sent again after waiting
+// Timeout * 2^n, n being the number of earlier attempts.
+func (t *UDPTracker) do(ctx context.Context, action uint32, payload []byte) ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.dial(); err != nil {
+		return nil, err
+	}
+
+	timeout, retries := t.Timeout, t.Retries
+	if timeout <= 0 {
+		timeout = defaultUDPTimeout
+	}
+	if retries <= 0 {
+		retries = defaultUDPRetries
+	}
+	for n := 0; n <= retries; n++ {
+		wait := timeout << n
+		if time.Since(t.connTime) >= connectionTTL {
+			resp, err := t.exchange(ctx, protocolID, actionConnect, nil, wait)
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			if len(resp) < 8 {
+				return nil, fmt.Errorf("%w: connect response of %d bytes", ErrInvalidResponse, len(resp)+8)
+			}
+			t.connID = binary.BigEndian.Uint64(resp)
+			t.connTime = time.Now()
+		}
+
+		resp, err := t.exchange(ctx, t.connID, action, payload, wait)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			continue
+		}
+		// BEP 15 has no error code for a bad connection id, so any failure
+		// mentioning the connection gets a fresh one for the next request.
+		var failure *FailureError
+		if errors.As(err, &failure) && strings.Contains(strings.ToLower(failure.Reason), "connection") {
+			t.connTime = time.Time{}
+		}
+		return resp, err
+	}
+	return nil, fmt.Errorf("%w: %s after %d attempts", ErrTimeout, t.URL, retries+1)
+}
+
+func (t *UDPTracker) dial() error {
+	if t.conn != nil {
+		return nil
+	}
+	u, err := url.Parse(t.URL)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidURL, err)
+	}
+	if u.Scheme != "udp" || u.Port() == "" {
+		return fmt.Errorf("%w: %s", ErrInvalidURL, t.URL)
+	}
+	network := t.Network
+	if network == "" {
+		network = "udp"
+	}
+	conn, err := net.Dial(network, u.Host)
+	if err != nil {
+		return err
+	}
+	t.conn = conn
+	t.ipv6 = conn.RemoteAddr().(*net.UDPAddr).AddrPort().Addr().Unmap().Is6()
+	t.connTime = time.Time{}
+	return nil
+}
+
+// exchange sends a single request and waits up to wait for its response,
+// ignoring packets with another transaction id. It returns an error wrapping
+// os.ErrDeadlineExceeded if none comes.
+func (t *UDPTracker) exchange(ctx context.Context, connID uint64, action uint32, payload []byte, wait time.Duration) ([]byte, error) {
+	var txID [4]byte
+	if _, err := rand.Read(txID[:]); err != nil {
+		return nil, err
+	}
+	packet := make([]byte, 16, 16+len(payload))
+	binary.BigEndian.PutUint64(packet[0:8], connID)
+	binary.BigEndian.PutUint32(packet[8:12], action)
+	copy(packet[12:16], txID[:])
+	packet = append(packet, payload...)
+
+	deadline := time.Now().Add(wait)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := t.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	stop := context.AfterFunc(ctx, func() { t.conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	if _, err := t.conn.Write(packet); err != nil {
+		return nil, contextErr(ctx, err)
+	}
+	buf := make([]byte, maxUDPPacket)
+	for {
+		n, err := t.conn.Read(buf)
+		if err != nil {
+			return nil, contextErr(ctx, err)
+		}
+		resp := buf[:n]
+		if len(resp) < 8 || [4]byte(resp[4:8]) != txID {
+			continue
+		}
+		switch got := binary.BigEndian.Uint32(resp[0:4]); got {
+		case action:
+			return resp[8:], nil
+		case actionError:
+			return nil, &FailureError{Reason: string(resp[8:])}
+		default:
+			return nil, fmt.Errorf("%w: action %d in response to action %d", ErrInvalidResponse, got, action)
+		}
+	}
+}
+
+// urlDataOptions returns the URL data options (BEP 41) carrying the path and
+// query of rawURL, which let trackers tell apart announce URLs such as those
+// of different passkeys.
+func urlDataOptions(rawURL string) []byte {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	data := u.EscapedPath()
+	if u.RawQuery != "" {
+		data += "?" + u.RawQuery
+	}
+	var options []byte
+	for len(data) > 0 {
+		n := min(len(data), 255)
+		options = append(options, optionURLData, byte(n))
+		options = append(options, data[:n]...)
+		data = data[n:]
+	}
+	return options
+}
+
+// contextErr returns the error of ctx if it ended, or err otherwise. A
+// deadline of ctx that just passed counts as ended even if ctx was not told
+// yet.
+func contextErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if d, ok := ctx.Deadline(); ok && !time.Now().Before(d) {
+		return context.DeadlineExceeded
+	}
+	return err
+}
@@ -0,0 +1,456 @@
+package tracker
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/netip"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// udpStub is a stand-in UDP tracker on localhost. It answers connects with
+// connID and hands every other request to handle, whose response is sent
+// unless it is nil.
+type udpStub struct {
+	conn   net.PacketConn
+	connID uint64
+	handle func(action uint32, payload []byte) []byte
+
+	mu       sync.Mutex
+	drop     int // requests left to ignore
+	requests []uint32
+	payload  []byte // of the last request past the connect
+	failure  string // sent in reply to requests past the connect if set
+}
+
+func newUDPStub(t *testing.T, handle func(action uint32, payload []byte) []byte) (*udpStub, *UDPTracker) {
+	t.Helper()
+	return newUDPStubAt(t, "127.0.0.1:0", handle)
+}
+
+func newUDPStubAt(t *testing.T, addr string, handle func(action uint32, payload []byte) []byte) (*udpStub, *UDPTracker) {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		t.Skipf("cannot listen on %s: %v", addr, err)
+	}
+	stub := &udpStub{conn: conn, connID: 0x1122334455667788, handle: handle}
+	go stub.serve()
+	tracker := &UDPTracker{URL: "udp://" + conn.LocalAddr().String() + "/announce", Timeout: 20 * time.Millisecond, Retries: 3}
+	t.Cleanup(func() {
+		conn.Close()
+		tracker.Close()
+	})
+	return stub, tracker
+}
+
+func (s *udpStub) serve() {
+	buf := make([]byte, 2048)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		packet := buf[:n]
+		if len(packet) < 16 {
+			continue
+		}
+		connID := binary.BigEndian.Uint64(packet[0:8])
+		action := binary.BigEndian.Uint32(packet[8:12])
+		txID := packet[12:16]
+
+		s.mu.Lock()
+		s.requests = append(s.requests, action)
+		current := s.connID
+		failure := s.failure
+		drop := s.drop > 0
+		if drop {
+			s.drop--
+		}
+		s.mu.Unlock()
+		if drop {
+			continue
+		}
+
+		var body []byte
+		switch {
+		case action == actionConnect && connID == protocolID:
+			body = binary.BigEndian.AppendUint64(nil, current)
+		case connID != current:
+			action, body = actionError, []byte("connection id mismatch")
+		case failure != "":
+			action, body = actionError, []byte(failure)
+		default:
+			s.mu.Lock()
+			s.payload = append([]byte(nil), packet[16:]...)
+			s.mu.Unlock()
+			body = s.handle(action, packet[16:])
+			if body == nil {
+				continue
+			}
+		}
+		resp := binary.BigEndian.AppendUint32(nil, action)
+		resp = append(resp, txID...)
+		s.conn.WriteTo(append(resp, body...), addr)
+	}
+}
+
+func (s *udpStub) setDrop(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drop = n
+}
+
+func (s *udpStub) lastPayload() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.payload
+}
+
+func (s *udpStub) actions() []uint32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]uint32(nil), s.requests...)
+}
+
+func announceBody(interval, leechers, seeders uint32, peers ...byte) []byte {
+	body := binary.BigEndian.AppendUint32(nil, interval)
+	body = binary.BigEndian.AppendUint32(body, leechers)
+	body = binary.BigEndian.AppendUint32(body, seeders)
+	return append(body, peers...)
+}
+
+func TestUDPAnnounce(t *testing.T) {
+	req := testRequest()
+	req.Event = EventStarted
+	req.Key = 0xdeadbeef
+	req.IPv4 = netip.MustParseAddr("203.0.113.5")
+	req.IPv6 = netip.MustParseAddr("2001:db8::5")
+
+	stub, tracker := newUDPStub(t, func(action uint32, p []byte) []byte {
+		return announceBody(1800, 3, 4, 10, 0, 0, 1, 0x1a, 0xe1)
+	})
+
+	resp, err := tracker.Announce(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &AnnounceResponse{
+		Interval: 30 * time.Minute,
+		Leechers: 3,
+		Seeders:  4,
+		Peers:    []Peer{{Addr: netip.MustParseAddrPort("10.0.0.1:6881")}},
+	}
+	if !reflect.DeepEqual(resp, expected) {
+		t.Errorf("expected %+v, got %+v", expected, resp)
+	}
+
+	payload := stub.lastPayload()
+	if len(payload) != 93 {
+		t.Fatalf("expected a 93 byte payload, got %d", len(payload))
+	}
+	if options := string(payload[82:]); options != "\x02\x09/announce" {
+		t.Errorf("expected the URL data of /announce, got %q", options)
+	}
+	if [20]byte(payload[0:20]) != req.InfoHash || [20]byte(payload[20:40]) != req.PeerID {
+		t.Errorf("wrong info hash or peer id in %x", payload)
+	}
+	fields := []struct {
+		name     string
+		got      uint64
+		expected uint64
+	}{
+		{"downloaded", binary.BigEndian.Uint64(payload[40:48]), 200},
+		{"left", binary.BigEndian.Uint64(payload[48:56]), 300},
+		{"uploaded", binary.BigEndian.Uint64(payload[56:64]), 100},
+		{"event", uint64(binary.BigEndian.Uint32(payload[64:68])), 2},
+		{"ip", uint64(binary.BigEndian.Uint32(payload[68:72])), 0xcb007105},
+		{"key", uint64(binary.BigEndian.Uint32(payload[72:76])), 0xdeadbeef},
+		{"num want", uint64(binary.BigEndian.Uint32(payload[76:80])), 0xffffffff},
+		{"port", uint64(binary.BigEndian.Uint16(payload[80:82])), 6881},
+	}
+	for _, field := range fields {
+		if field.got != field.expected {
+			t.Errorf("%s: expected %d, got %d", field.name, field.expected, field.got)
+		}
+	}
+}
+
+func TestUDPAnnounceIPv6(t *testing.T) {
+	_, tracker := newUDPStubAt(t, "[::1]:0", func(action uint32, p []byte) []byte {
+		peer := netip.MustParseAddr("2001:db8::1").As16()
+		return announceBody(60, 1, 2, append(peer[:], 0x1a, 0xe1)...)
+	})
+
+	resp, err := tracker.Announce(context.Background(), testRequest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Peer{{Addr: netip.MustParseAddrPort("[2001:db8::1]:6881")}}
+	if !reflect.DeepEqual(resp.Peers, expected) {
+		t.Errorf("expected %v, got %v", expected, resp.Peers)
+	}
+
+	// A tracker listening on IPv6 only is unreachable over IPv4.
+	ipv4 := &UDPTracker{URL: tracker.URL, Network: "udp4"}
+	if _, err := ipv4.Announce(context.Background(), testRequest()); err == nil {
+		t.Error("expected announcing to an IPv6 address over udp4 to fail")
+	}
+}
+
+func TestURLDataOptions(t *testing.T) {
+	long := "/" + strings.Repeat("a", 299)
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"udp://example.com:6969", ""},
+		{"udp://example.com:6969/announce?passkey=x", "\x02\x13/announce?passkey=x"},
+		{"udp://example.com:6969" + long, "\x02\xff" + long[:255] + "\x02\x2d" + long[255:]},
+	}
+	for _, test := range tests {
+		options := urlDataOptions(test.url)
+		if string(options) != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.url, test.expected, options)
+		}
+		if data := parseURLData(options); data != strings.TrimPrefix(test.url, "udp://example.com:6969") {
+			t.Errorf("%s: expected the options to parse back, got %q", test.url, data)
+		}
+	}
+}
+
+func TestUDPConnectionID(t *testing.T) {
+	stub, tracker := newUDPStub(t, func(action uint32, p []byte) []byte {
+		return announceBody(60, 0, 0)
+	})
+	announce := func() {
+		t.Helper()
+		if _, err := tracker.Announce(context.Background(), testRequest()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	announce()
+	announce()
+	expected := []uint32{actionConnect, actionAnnounce, actionAnnounce}
+	if actions := stub.actions(); !reflect.DeepEqual(actions, expected) {
+		t.Errorf("expected the connection id to be reused: %v, got %v", expected, actions)
+	}
+
+	// The connection id expires a minute after it was received.
+	tracker.connTime = time.Now().Add(-connectionTTL)
+	announce()
+	expected = append(expected, actionConnect, actionAnnounce)
+	if actions := stub.actions(); !reflect.DeepEqual(actions, expected) {
+		t.Errorf("expected a new connect after expiry: %v, got %v", expected, actions)
+	}
+
+	// A rejected connection id is replaced on the next request.
+	stub.mu.Lock()
+	stub.connID++
+	stub.mu.Unlock()
+	if _, err := tracker.Announce(context.Background(), testRequest()); err == nil {
+		t.Fatal("expected the stale connection id to be rejected")
+	}
+	announce()
+	expected = append(expected, actionAnnounce, actionConnect, actionAnnounce)
+	if actions := stub.actions(); !reflect.DeepEqual(actions, expected) {
+		t.Errorf("expected a new connect after a failure: %v, got %v", expected, actions)
+	}
+
+	// Other failures keep the connection id.
+	stub.mu.Lock()
+	stub.failure = "unregistered torrent"
+	stub.mu.Unlock()
+	if _, err := tracker.Announce(context.Background(), testRequest()); err == nil {
+		t.Fatal("expected the announce to fail")
+	}
+	stub.mu.Lock()
+	stub.failure = ""
+	stub.mu.Unlock()
+	announce()
+	expected = append(expected, actionAnnounce, actionAnnounce)
+	if actions := stub.actions(); !reflect.DeepEqual(actions, expected) {
+		t.Errorf("expected the connection id to be kept after an unrelated failure: %v, got %v", expected, actions)
+	}
+}
+
+func TestUDPRetransmission(t *testing.T) {
+	stub, tracker := newUDPStub(t, func(action uint32, p []byte) []byte {
+		return announceBody(60, 0, 0)
+	})
+	stub.setDrop(2)
+
+	start := time.Now()
+	if _, err := tracker.Announce(context.Background(), testRequest()); err != nil {
+		t.Fatal(err)
+	}
+	// The dropped connects waited 20ms and 40ms.
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("expected the backoff to take at least 60ms, took %v", elapsed)
+	}
+	expected := []uint32{actionConnect, actionConnect, actionConnect, actionAnnounce}
+	if actions := stub.actions(); !reflect.DeepEqual(actions, expected) {
+		t.Errorf("expected %v, got %v", expected, actions)
+	}
+
+	stub.setDrop(100)
+	if _, err := tracker.Announce(context.Background(), testRequest()); !errors.Is(err, ErrTimeout) {
+		t.Errorf("expected %v, got %v", ErrTimeout, err)
+	}
+	if n := len(stub.actions()) - len(expected); n != 4 {
+		t.Errorf("expected 4 attempts, got %d", n)
+	}
+}
+
+func TestUDPScrape(t *testing.T) {
+	hashes := [][20]byte{{1}, {2}}
+	stub, tracker := newUDPStub(t, func(action uint32, p []byte) []byte {
+		if action != actionScrape {
+			return nil
+		}
+		var body []byte
+		for _, n := range []uint32{5, 10, 2, 0, 1, 7} {
+			body = binary.BigEndian.AppendUint32(body, n)
+		}
+		return body
+	})
+
+	results, err := tracker.Scrape(context.Background(), hashes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[[20]byte]ScrapeResult{
+		{1}: {Seeders: 5, Completed: 10, Leechers: 2},
+		{2}: {Seeders: 0, Completed: 1, Leechers: 7},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("expected %v, got %v", expected, results)
+	}
+	if payload := stub.lastPayload(); len(payload) != 40 || [20]byte(payload[20:]) != hashes[1] {
+		t.Errorf("wrong scrape payload %x", payload)
+	}
+}
+
+func TestUDPScrapeBatches(t *testing.T) {
+	stub, tracker := newUDPStub(t, func(action uint32, p []byte) []byte {
+		var body []byte
+		for ; len(p) >= 20; p = p[20:] {
+			body = binary.BigEndian.AppendUint32(body, uint32(p[0]))
+			body = binary.BigEndian.AppendUint32(body, uint32(p[1]))
+			body = binary.BigEndian.AppendUint32(body, 0)
+		}
+		return body
+	})
+
+	hashes := make([][20]byte, 100)
+	for i := range hashes {
+		hashes[i] = [20]byte{byte(i), byte(2 * i)}
+	}
+	results, err := tracker.Scrape(context.Background(), hashes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(hashes) {
+		t.Fatalf("expected %d results, got %d", len(hashes), len(results))
+	}
+	for i, hash := range hashes {
+		if expected := (ScrapeResult{Seeders: i, Completed: 2 * i}); results[hash] != expected {
+			t.Errorf("%x: expected %+v, got %+v", hash, expected, results[hash])
+		}
+	}
+
+	expected := []uint32{actionConnect, actionScrape, actionScrape}
+	if actions := stub.actions(); !reflect.DeepEqual(actions, expected) {
+		t.Errorf("expected %v, got %v", expected, actions)
+	}
+	if payload := stub.lastPayload(); len(payload) != 20*(len(hashes)-MaxScrapeHashes) {
+		t.Errorf("expected the last batch to hold %d hashes, got %d bytes", len(hashes)-MaxScrapeHashes, len(payload))
+	}
+}
+
+func TestUDPErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		response func(action uint32, p []byte) []byte
+		expected error
+	}{
+		{"truncated announce", func(uint32, []byte) []byte { return []byte{0, 0} }, ErrInvalidResponse},
+		{"truncated peers", func(uint32, []byte) []byte { return announceBody(60, 0, 0, 1, 2, 3) }, ErrInvalidResponse},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, tracker := newUDPStub(t, test.response)
+			if _, err := tracker.Announce(context.Background(), testRequest()); !errors.Is(err, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, err)
+			}
+		})
+	}
+
+	for _, url := range []string{"http://127.0.0.1:80", "udp://127.0.0.1", "udp://%zz"} {
+		tracker := &UDPTracker{URL: url}
+		if _, err := tracker.Announce(context.Background(), testRequest()); !errors.Is(err, ErrInvalidURL) {
+			t.Errorf("%s: expected %v, got %v", url, ErrInvalidURL, err)
+		}
+	}
+}
+
+func TestUDPFailure(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	go func() {
+		buf := make([]byte, 2048)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < 16 {
+				continue
+			}
+			txID := buf[12:16]
+			// A stray packet with another transaction id comes first.
+			stray := binary.BigEndian.AppendUint32(nil, actionError)
+			stray = append(stray, txID[0]+1, txID[1], txID[2], txID[3])
+			conn.WriteTo(append(stray, "not yours"...), addr)
+
+			resp := binary.BigEndian.AppendUint32(nil, actionError)
+			resp = append(resp, txID...)
+			conn.WriteTo(append(resp, "banned"...), addr)
+		}
+	}()
+
+	tracker := &UDPTracker{URL: "udp://" + conn.LocalAddr().String(), Timeout: 50 * time.Millisecond}
+	defer tracker.Close()
+	_, err = tracker.Announce(context.Background(), testRequest())
+	var failure *FailureError
+	if !errors.As(err, &failure) || failure.Reason != "banned" {
+		t.Errorf("expected the failure %q, got %v", "banned", err)
+	}
+}
+
+func TestUDPCanceled(t *testing.T) {
+	stub, tracker := newUDPStub(t, nil)
+	stub.setDrop(100)
+	tracker.Timeout = time.Minute
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if _, err := tracker.Announce(ctx, testRequest()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(30*time.Millisecond, cancel)
+	if _, err := tracker.Announce(ctx, testRequest()); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+}
@@ -0,0 +1,192 @@
+package tracker
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// ServeUDP serves announces and scrapes to UDP clients (BEP 15) on conn until
+// reading from it fails, returning the error. With passkeys, clients give
+// theirs in the URL data of their announces (BEP 41), and cannot scrape.
+func (s *Server) ServeUDP(conn net.PacketConn) error {
+	s.init()
+	buf := make([]byte, 2048)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		udpAddr, ok := addr.(*net.UDPAddr)
+		if !ok {
+			continue
+		}
+		if resp := s.handleUDP(buf[:n], udpAddr.AddrPort()); resp != nil {
+			conn.WriteTo(resp, addr)
+		}
+	}
+}
+
+// handleUDP returns the response to a UDP request from the given address, or
+// nil if it should go unanswered.
+func (s *Server) handleUDP(packet []byte, from netip.AddrPort) []byte {
+	if len(packet) < 16 {
+		return nil
+	}
+	connID := binary.BigEndian.Uint64(packet[0:8])
+	action := binary.BigEndian.Uint32(packet[8:12])
+	txID := packet[12:16]
+	now := s.now()
+
+	if action == actionConnect {
+		if connID != protocolID {
+			return nil
+		}
+		return binary.BigEndian.AppendUint64(udpHeader(actionConnect, txID), s.connectionID(from, now, 0))
+	}
+	if connID != s.connectionID(from, now, 0) && connID != s.connectionID(from, now, -1) {
+		return udpError(txID, "invalid connection id")
+	}
+
+	switch action {
+	case actionAnnounce:
+		req, path, err := parseUDPAnnounce(packet[16:])
+		if err == nil && s.Passkeys != nil {
+			var action string
+			action, err = s.route(path)
+			if err == nil && action != "announce" {
+				err = errInvalidPasskey
+			}
+		}
+		if err != nil {
+			return udpError(txID, failureReason(err))
+		}
+		resp, err := s.announce(req, from.Addr())
+		if err != nil {
+			return udpError(txID, failureReason(err))
+		}
+
+		b := udpHeader(actionAnnounce, txID)
+		b = binary.BigEndian.AppendUint32(b, uint32(resp.Interval/time.Second))
+		b = binary.BigEndian.AppendUint32(b, uint32(resp.Leechers))
+		b = binary.BigEndian.AppendUint32(b, uint32(resp.Seeders))
+		// Only peers of the request's address family fit in the response.
+		ipv6 := from.Addr().Unmap().Is6()
+		for _, peer := range resp.Peers {
+			if peer.Addr.Addr().Is6() == ipv6 {
+				b = appendCompactPeer(b, peer.Addr)
+			}
+		}
+		return b
+
+	case actionScrape:
+		if s.Passkeys != nil {
+			return udpError(txID, errInvalidPasskey.Reason)
+		}
+		hashes := packet[16:]
+		if len(hashes)%20 != 0 || len(hashes)/20 > MaxScrapeHashes {
+			return udpError(txID, "invalid scrape")
+		}
+		infoHashes := make([][20]byte, len(hashes)/20)
+		for i := range infoHashes {
+			infoHashes[i] = [20]byte(hashes[20*i:])
+		}
+		results, err := s.scrape(infoHashes)
+		if err != nil {
+			return udpError(txID, failureReason(err))
+		}
+
+		b := udpHeader(actionScrape, txID)
+		for _, hash := range infoHashes {
+			result := results[hash]
+			b = binary.BigEndian.AppendUint32(b, uint32(result.Seeders))
+			b = binary.BigEndian.AppendUint32(b, uint32(result.Completed))
+			b = binary.BigEndian.AppendUint32(b, uint32(result.Leechers))
+		}
+		return b
+
+	default:
+		return udpError(txID, "unknown action")
+	}
+}
+
+// parseUDPAnnounce parses the body of a UDP announce, past its header, and
+// returns the path of its URL data.
+func parseUDPAnnounce(b []byte) (AnnounceRequest, string, error) {
+	var req AnnounceRequest
+	if len(b) < 82 {
+		return req, "", &FailureError{Reason: "invalid announce"}
+	}
+	copy(req.InfoHash[:], b[0:20])
+	copy(req.PeerID[:], b[20:40])
+	req.Downloaded = int64(binary.BigEndian.Uint64(b[40:48]))
+	req.Left = int64(binary.BigEndian.Uint64(b[48:56]))
+	req.Uploaded = int64(binary.BigEndian.Uint64(b[56:64]))
+	req.Event = Event(binary.BigEndian.Uint32(b[64:68]))
+	if req.Event < EventNone || req.Event > EventStopped {
+		return req, "", &FailureError{Reason: "invalid event"}
+	}
+	// The IP address at 68 is ignored, peers being recorded at the address
+	// their packets come from.
+	req.Key = binary.BigEndian.Uint32(b[72:76])
+	if numWant := int32(binary.BigEndian.Uint32(b[76:80])); numWant > 0 {
+		req.NumWant = int(numWant)
+	}
+	req.Port = binary.BigEndian.Uint16(b[80:82])
+
+	path, _, _ := strings.Cut(parseURLData(b[82:]), "?")
+	return req, path, nil
+}
+
+// parseURLData returns the concatenated URL data options (BEP 41), stopping at
+// the end option or at an unknown or truncated one.
+func parseURLData(options []byte) string {
+	var data []byte
+	for len(options) > 0 {
+		switch options[0] {
+		case optionNOP:
+			options = options[1:]
+		case optionURLData:
+			if len(options) < 2 || len(options) < 2+int(options[1]) {
+				return string(data)
+			}
+			n := int(options[1])
+			data = append(data, options[2:2+n]...)
+			options = options[2+n:]
+		default:
+			return string(data)
+		}
+	}
+	return string(data)
+}
+
+// initSecret picks the secret connection ids are derived from.
+func (s *Server) initSecret() {
+	rand.Read(s.secret[:])
+}
+
+// connectionID returns the connection id of the given address for the minute
+// of now shifted by offset minutes. Ids being derived rather than stored, the
+// server accepts those of the current and the previous minute, so that they
+// are valid for at least a minute as BEP 15 asks.
+func (s *Server) connectionID(addr netip.AddrPort, now time.Time, offset int64) uint64 {
+	b := make([]byte, 0, len(s.secret)+8+18)
+	b = append(b, s.secret[:]...)
+	b = binary.BigEndian.AppendUint64(b, uint64(now.Unix()/60+offset))
+	b = append(b, addr.Addr().Unmap().AsSlice()...)
+	b = binary.BigEndian.AppendUint16(b, addr.Port())
+	sum := sha256.Sum256(b)
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+func udpHeader(action uint32, txID []byte) []byte {
+	return append(binary.BigEndian.AppendUint32(nil, action), txID...)
+}
+
+func udpError(txID []byte, message string) []byte {
+	return append(udpHeader(actionError, txID), message...)
+}
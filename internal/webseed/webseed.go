@@ -0,0 +1,211 @@
+// Package webseed downloads torrents from BEP 19 web seeds: plain HTTP servers
+// hosting the torrent's files, listed in a torrent's url-list.
+package webseed
+
+import (
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/stupoid/torrent/internal/metainfo"
+)
+
+var (
+	ErrPieceHash     = errors.New("piece hash mismatch")
+	ErrPieceIndex    = errors.New("piece index out of range")
+	ErrBadStatus     = errors.New("unexpected HTTP status")
+	ErrNoPieceHashes = errors.New("torrent has no v1 piece hashes")
+)
+
+// Seed fetches the pieces of a torrent from a single web seed with HTTP range
+// requests, verifying each piece against Info.Pieces. Padding files are never
+// requested, since they hold nothing but zeros.
+type Seed struct {
+	URL  string
+	Info metainfo.Info
+
+	// Client is used for the requests, or http.DefaultClient when nil.
+	Client *http.Client
+}
+
+// FileURL returns the URL of the file at index in Info.FileList. For single
+// file torrents it is URL itself, with the torrent's name appended if URL ends
+// in a slash. For multi-file torrents the name and the file's path are always
+// appended, as BEP 19 asks. An index outside Info.FileList fails with
+// ErrPieceIndex.
+func (s *Seed) FileURL(index int) (string, error) {
+	if s.Info.Files == nil {
+		if index != 0 {
+			return "", fmt.Errorf("%w: file %d", ErrPieceIndex, index)
+		}
+		if strings.HasSuffix(s.URL, "/") {
+			return s.URL + url.PathEscape(s.Info.Name), nil
+		}
+		return s.URL, nil
+	}
+	if index < 0 || index >= len(s.Info.Files) {
+		return "", fmt.Errorf("%w: file %d", ErrPieceIndex, index)
+	}
+	var b strings.Builder
+	b.WriteString(s.URL)
+	if !strings.HasSuffix(s.URL, "/") {
+		b.WriteByte('/')
+	}
+	b.WriteString(url.PathEscape(s.Info.Name))
+	for _, component := range s.Info.Files[index].Path {
+		b.WriteByte('/')
+		b.WriteString(url.PathEscape(component))
+	}
+	return b.String(), nil
+}
+
+// Piece downloads the piece at index and checks it against its hash,
+// returning an error wrapping ErrPieceHash if they differ.
+func (s *Seed) Piece(ctx context.Context, index int) ([]byte, error) {
+	if !s.Info.HasV1() {
+		return nil, ErrNoPieceHashes
+	}
+	extents := s.Info.PieceExtents(index)
+	if extents == nil || index >= len(s.Info.Pieces) {
+		return nil, fmt.Errorf("%w: %d", ErrPieceIndex, index)
+	}
+	files := s.Info.FileList()
+
+	var piece []byte
+	for _, extent := range extents {
+		start := len(piece)
+		piece = append(piece, make([]byte, extent.Length)...)
+		if files[extent.File].IsPadding() {
+			continue
+		}
+		fileURL, err := s.FileURL(extent.File)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.fetch(ctx, fileURL, extent.Offset, piece[start:]); err != nil {
+			return nil, err
+		}
+	}
+	if sha1.Sum(piece) != s.Info.Pieces[index] {
+		return nil, fmt.Errorf("%w: piece %d from %s", ErrPieceHash, index, s.URL)
+	}
+	return piece, nil
+}
+
+// fetch fills p with the bytes of the file at rawURL starting at offset.
+func (s *Seed) fetch(ctx context.Context, rawURL string, offset int64, p []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+int64(len(p))-1))
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		if !strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)) {
+			return fmt.Errorf("%w: %s: Content-Range %q for offset %d", ErrBadStatus, rawURL, resp.Header.Get("Content-Range"), offset)
+		}
+	case http.StatusOK:
+		// The server ignored the range and sends the whole file.
+		if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+			return fmt.Errorf("%s: %w", rawURL, err)
+		}
+	default:
+		return fmt.Errorf("%w: %s: %s", ErrBadStatus, rawURL, resp.Status)
+	}
+	if _, err := io.ReadFull(resp.Body, p); err != nil {
+		return fmt.Errorf("%s: %w", rawURL, err)
+	}
+	return nil
+}
+
+// Download downloads every piece in order and writes the torrent's files below
+// dir, under a directory named after the torrent for multi-file torrents.
+// Padding files are not written.
+func (s *Seed) Download(ctx context.Context, dir string) error {
+	files := s.Info.FileList()
+	out := make([]*os.File, len(files))
+	defer func() {
+		for _, f := range out {
+			if f != nil {
+				f.Close()
+			}
+		}
+	}()
+	for i, file := range files {
+		if file.IsPadding() {
+			continue
+		}
+		f, err := s.create(dir, file)
+		if err != nil {
+			return err
+		}
+		out[i] = f
+	}
+
+	for index := 0; index < s.Info.NumPieces(); index++ {
+		piece, err := s.Piece(ctx, index)
+		if err != nil {
+			return err
+		}
+		for _, extent := range s.Info.PieceExtents(index) {
+			data := piece[:extent.Length]
+			piece = piece[extent.Length:]
+			if out[extent.File] == nil {
+				continue
+			}
+			if _, err := out[extent.File].WriteAt(data, extent.Offset); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i, f := range out {
+		if f == nil {
+			continue
+		}
+		out[i] = nil
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// create creates the file below dir that file is written to, along with its
+// parent directories.
+func (s *Seed) create(dir string, file metainfo.File) (*os.File, error) {
+	path, err := file.OSPath()
+	if err != nil {
+		return nil, err
+	}
+	if s.Info.Files != nil {
+		name, err := metainfo.File{Path: []string{s.Info.Name}}.OSPath()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(name, path)
+	}
+	path = filepath.Join(dir, path)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	return os.Create(path)
+}
@@ -0,0 +1,216 @@
+package webseed
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stupoid/torrent/internal/metainfo"
+)
+
+func testData(seed, n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte((i*7 + seed) % 251)
+	}
+	return data
+}
+
+// fixture writes files below dir/name, builds a torrent of them and serves
+// dir over HTTP.
+func fixture(t *testing.T, name string, files map[string][]byte, version metainfo.Version) (*metainfo.MetaInfo, *httptest.Server) {
+	t.Helper()
+	dir := t.TempDir()
+	root := filepath.Join(dir, name)
+	for path, data := range files {
+		path = filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if files == nil {
+		if err := os.WriteFile(root, testData(9, 40000), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b := metainfo.Builder{AnnounceList: [][]string{{"http://tracker/announce"}}, PieceLength: 16 << 10, Version: version}
+	m, err := b.Build(io.Discard, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	t.Cleanup(server.Close)
+	return m, server
+}
+
+func checkDownload(t *testing.T, seed *Seed, expected map[string][]byte) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := seed.Download(context.Background(), dir); err != nil {
+		t.Fatal(err)
+	}
+	for path, data := range expected {
+		got, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("%s: downloaded data does not match", path)
+		}
+	}
+}
+
+func TestSeedMultiFile(t *testing.T) {
+	files := map[string][]byte{
+		"a.bin":           testData(1, 40000),
+		"dir/my file.txt": testData(2, 100),
+		"dir/empty":       nil,
+		"z.bin":           testData(3, 20000),
+	}
+	m, server := fixture(t, "multi", files, metainfo.V1)
+
+	for _, url := range []string{server.URL, server.URL + "/"} {
+		seed := &Seed{URL: url, Info: m.Info}
+		if fileURL, err := seed.FileURL(2); err != nil || fileURL != server.URL+"/multi/dir/my%20file.txt" {
+			t.Errorf("expected %s, got %s (%v)", server.URL+"/multi/dir/my%20file.txt", fileURL, err)
+		}
+		for _, index := range []int{-1, 4} {
+			if _, err := seed.FileURL(index); !errors.Is(err, ErrPieceIndex) {
+				t.Errorf("file %d: expected %v, got %v", index, ErrPieceIndex, err)
+			}
+		}
+
+		// The second piece spans a.bin, the text file and z.bin.
+		piece, err := seed.Piece(context.Background(), 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := append(append(append([]byte(nil), files["a.bin"][2*16384:]...), files["dir/my file.txt"]...), files["z.bin"][:9052]...)
+		if !bytes.Equal(piece, expected) {
+			t.Errorf("piece 2 does not match the files")
+		}
+	}
+
+	expected := make(map[string][]byte)
+	for path, data := range files {
+		expected["multi/"+path] = data
+	}
+	checkDownload(t, &Seed{URL: server.URL, Info: m.Info}, expected)
+}
+
+func TestSeedSingleFile(t *testing.T) {
+	m, server := fixture(t, "single.bin", nil, metainfo.V1)
+
+	for _, url := range []string{server.URL + "/", server.URL + "/single.bin"} {
+		seed := &Seed{URL: url, Info: m.Info}
+		if fileURL, err := seed.FileURL(0); err != nil || fileURL != server.URL+"/single.bin" {
+			t.Errorf("expected %s, got %s (%v)", server.URL+"/single.bin", fileURL, err)
+		}
+		if _, err := seed.FileURL(1); !errors.Is(err, ErrPieceIndex) {
+			t.Errorf("file 1: expected %v, got %v", ErrPieceIndex, err)
+		}
+		checkDownload(t, seed, map[string][]byte{"single.bin": testData(9, 40000)})
+	}
+}
+
+func TestSeedPaddingFiles(t *testing.T) {
+	files := map[string][]byte{
+		"a.bin": testData(1, 20000),
+		"b.bin": testData(2, 5000),
+	}
+	m, server := fixture(t, "hybrid", files, metainfo.Hybrid)
+
+	var requests []string
+	server.Config.Handler = logRequests(server.Config.Handler, &requests)
+	checkDownload(t, &Seed{URL: server.URL, Info: m.Info}, map[string][]byte{
+		"hybrid/a.bin": files["a.bin"],
+		"hybrid/b.bin": files["b.bin"],
+	})
+	for _, path := range requests {
+		if path != "/hybrid/a.bin" && path != "/hybrid/b.bin" {
+			t.Errorf("expected only the real files to be requested, got %s", path)
+		}
+	}
+}
+
+func logRequests(next http.Handler, paths *[]string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*paths = append(*paths, r.URL.Path)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func TestSeedIgnoredRange(t *testing.T) {
+	files := map[string][]byte{"a.bin": testData(1, 40000), "b.bin": testData(2, 10)}
+	m, server := fixture(t, "multi", files, metainfo.V1)
+
+	next := server.Config.Handler
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Del("Range")
+		next.ServeHTTP(w, r)
+	})
+	checkDownload(t, &Seed{URL: server.URL, Info: m.Info}, map[string][]byte{"multi/a.bin": files["a.bin"], "multi/b.bin": files["b.bin"]})
+}
+
+func TestSeedErrors(t *testing.T) {
+	files := map[string][]byte{"a.bin": testData(1, 40000)}
+	m, server := fixture(t, "multi", files, metainfo.V1)
+
+	corrupt := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data := testData(1, 40000)
+		data[20000] ^= 1
+		http.ServeContent(w, r, "a.bin", m.CreationDate, bytes.NewReader(data))
+	}))
+	defer corrupt.Close()
+
+	v2, _ := fixture(t, "v2", files, metainfo.V2)
+	short := m.Info
+	short.Pieces = short.Pieces[:1]
+
+	tests := []struct {
+		name     string
+		seed     *Seed
+		index    int
+		expected error
+	}{
+		{"corrupt piece", &Seed{URL: corrupt.URL, Info: m.Info}, 1, ErrPieceHash},
+		{"missing file", &Seed{URL: server.URL + "/elsewhere/", Info: m.Info}, 0, ErrBadStatus},
+		{"negative index", &Seed{URL: server.URL, Info: m.Info}, -1, ErrPieceIndex},
+		{"index past end", &Seed{URL: server.URL, Info: m.Info}, 3, ErrPieceIndex},
+		{"index past hashes", &Seed{URL: server.URL, Info: short}, 1, ErrPieceIndex},
+		{"v2 only", &Seed{URL: server.URL, Info: v2.Info}, 0, ErrNoPieceHashes},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := test.seed.Piece(context.Background(), test.index)
+			if !errors.Is(err, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, err)
+			}
+		})
+	}
+
+	// The first piece is intact on the corrupt server.
+	if _, err := (&Seed{URL: corrupt.URL, Info: m.Info}).Piece(context.Background(), 0); err != nil {
+		t.Errorf("expected the first piece to verify, got %v", err)
+	}
+}
+
+func TestSeedCanceled(t *testing.T) {
+	m, server := fixture(t, "multi", map[string][]byte{"a.bin": testData(1, 100)}, metainfo.V1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := (&Seed{URL: server.URL, Info: m.Info}).Piece(ctx, 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+}